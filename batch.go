@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/hertz-contrib/swagger-generate/swagger2idl/converter"
+	"github.com/hertz-contrib/swagger-generate/swagger2idl/parser"
+	"github.com/urfave/cli/v2"
+)
+
+// specExtensions lists the file extensions of the specs picked up from directories
+var specExtensions = map[string]bool{".yaml": true, ".yml": true, ".json": true}
+
+// specInput is a spec file to convert and the location of its output
+type specInput struct {
+	path string // Path of the spec file
+	rel  string // Output path relative to the output directory, without extension
+}
+
+// expandInputs expands the directories and glob patterns of the command-line arguments into spec
+// files. batch reports whether the arguments name anything else than a single spec file.
+func expandInputs(args []string) (inputs []specInput, batch bool, err error) {
+	batch = len(args) > 1
+	for _, arg := range args {
+		if arg == parser.StdinPath && len(args) > 1 {
+			return nil, false, fmt.Errorf("stdin cannot be converted together with other specs")
+		}
+		if info, statErr := os.Stat(arg); statErr == nil && info.IsDir() {
+			batch = true
+			err = filepath.WalkDir(arg, func(path string, entry fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if entry.IsDir() || !specExtensions[strings.ToLower(filepath.Ext(path))] {
+					return nil
+				}
+				// Skip the documents holding schemas referenced by other specs
+				if data, err := os.ReadFile(path); err != nil || !parser.IsSpecDocument(data) {
+					return err
+				}
+				inputs = append(inputs, specInput{path: path, rel: outputRel(arg, path)})
+				return nil
+			})
+			if err != nil {
+				return nil, false, fmt.Errorf("failed to read directory %s: %w", arg, err)
+			}
+			continue
+		}
+
+		if strings.ContainsAny(arg, "*?[") {
+			batch = true
+			matches, err := filepath.Glob(arg)
+			if err != nil {
+				return nil, false, fmt.Errorf("invalid pattern %s: %w", arg, err)
+			}
+			if len(matches) == 0 {
+				return nil, false, fmt.Errorf("no spec matches %s", arg)
+			}
+			for _, match := range matches {
+				if info, err := os.Stat(match); err == nil && info.IsDir() {
+					continue
+				}
+				inputs = append(inputs, specInput{path: match, rel: outputRel(globBase(arg), match)})
+			}
+			continue
+		}
+
+		if arg == parser.StdinPath {
+			inputs = append(inputs, specInput{path: arg, rel: stdinRootName})
+			continue
+		}
+		path, err := filepath.Abs(arg)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to resolve %s: %w", arg, err)
+		}
+		cwd, err := os.Getwd()
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to resolve %s: %w", arg, err)
+		}
+		inputs = append(inputs, specInput{path: arg, rel: outputRel(cwd, path)})
+	}
+
+	// Two specs must not be converted into the same output
+	seen := make(map[string]string, len(inputs))
+	for _, input := range inputs {
+		if other, ok := seen[input.rel]; ok {
+			return nil, false, fmt.Errorf("%s and %s would be converted into the same output", other, input.path)
+		}
+		seen[input.rel] = input.path
+	}
+	if len(inputs) == 0 {
+		return nil, false, fmt.Errorf("no spec found in %s", strings.Join(args, ", "))
+	}
+	return inputs, batch, nil
+}
+
+// globBase returns the leading directories of a glob pattern that hold no wildcard
+func globBase(pattern string) string {
+	dir := filepath.Dir(pattern)
+	for strings.ContainsAny(dir, "*?[") {
+		dir = filepath.Dir(dir)
+	}
+	return dir
+}
+
+// outputRel returns the path of a spec relative to dir without its extension. A spec outside dir
+// keeps its base name only, so its output stays in the output directory.
+func outputRel(dir, path string) string {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		rel = filepath.Base(path)
+	}
+	return strings.TrimSuffix(rel, filepath.Ext(rel))
+}
+
+// runBatch converts the inputs concurrently into the output directory and reports the result of
+// every spec. It fails if any of the conversions failed.
+func runBatch(inputs []specInput) error {
+	if outputType == "" {
+		return cli.Exit("Please use --type to specify the output type when converting several specs.", 1)
+	}
+	if outputFile != "" {
+		return cli.Exit("--output cannot be used when converting several specs, use --out-dir instead.", 1)
+	}
+	if lockFile != "" {
+		return cli.Exit("--lock cannot be used when converting several specs.", 1)
+	}
+	dir := outDir
+	if dir == "" {
+		dir = "."
+	}
+
+	outputPaths := make([]string, len(inputs))
+	diagnostics := make([][]converter.Diagnostic, len(inputs))
+	errs := make([]error, len(inputs))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < runtime.NumCPU(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				outputPaths[i] = filepath.Join(dir, inputs[i].rel)
+				if !split && !splitRefs {
+					outputPaths[i] += "." + outputType
+				}
+				diagnostics[i], errs[i] = convertBatchInput(inputs[i].path, outputPaths[i])
+			}
+		}()
+	}
+	for i := range inputs {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	failed := 0
+	for i, input := range inputs {
+		printDiagnostics(input.path, diagnostics[i])
+		if errs[i] != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "FAIL %s: %v\n", input.path, errs[i])
+			continue
+		}
+		fmt.Printf("ok   %s -> %s\n", input.path, outputPaths[i])
+	}
+	if failed > 0 {
+		return cli.Exit(fmt.Sprintf("%d of %d specs failed to convert", failed, len(inputs)), 1)
+	}
+	return nil
+}
+
+// convertBatchInput converts a single spec of a batch, reporting a panic of the conversion as an error
+func convertBatchInput(openapiFile, outputFile string) (diagnostics []converter.Diagnostic, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("conversion panicked: %v", r)
+		}
+	}()
+
+	outputs, diagnostics, err := convertSpec(openapiFile, outputFile, "")
+	if err != nil {
+		return diagnostics, err
+	}
+	return diagnostics, writeOutputs(outputs)
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeSpecs writes a minimal spec to each of the paths relative to dir
+func writeSpecs(t *testing.T, dir string, paths ...string) {
+	t.Helper()
+	for _, path := range paths {
+		path = filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("openapi: 3.0.3\ninfo: {title: test, version: \"1\"}\npaths: {}\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestExpandInputs(t *testing.T) {
+	dir := t.TempDir()
+	writeSpecs(t, dir, "specs/pet.yaml", "specs/store/order.yml", "dup/pet.yaml", "dup/pet.json")
+	// A document without an openapi or swagger key is skipped
+	if err := os.WriteFile(filepath.Join(dir, "specs", "schemas.yaml"), []byte("Pet: {type: object}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	outside := t.TempDir()
+	writeSpecs(t, outside, "user.yaml")
+
+	tests := []struct {
+		name      string
+		args      []string
+		wantRels  []string
+		wantBatch bool
+		wantErr   string
+	}{
+		{
+			name:      "directory",
+			args:      []string{filepath.Join(dir, "specs")},
+			wantRels:  []string{"pet", filepath.Join("store", "order")},
+			wantBatch: true,
+		},
+		{
+			name:      "glob",
+			args:      []string{filepath.Join(dir, "specs", "*", "*.yml")},
+			wantRels:  []string{filepath.Join("store", "order")},
+			wantBatch: true,
+		},
+		{
+			name:     "spec outside the working directory",
+			args:     []string{filepath.Join(outside, "user.yaml")},
+			wantRels: []string{"user"},
+		},
+		{
+			name:     "stdin",
+			args:     []string{"-"},
+			wantRels: []string{stdinRootName},
+		},
+		{
+			name:    "stdin with other specs",
+			args:    []string{"-", filepath.Join(dir, "specs", "pet.yaml")},
+			wantErr: "stdin cannot be converted together with other specs",
+		},
+		{
+			name:    "duplicate outputs",
+			args:    []string{filepath.Join(dir, "dup")},
+			wantErr: "would be converted into the same output",
+		},
+		{
+			name:    "glob without matches",
+			args:    []string{filepath.Join(dir, "*.txt")},
+			wantErr: "no spec matches",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inputs, batch, err := expandInputs(tt.args)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expandInputs() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expandInputs() error = %v", err)
+			}
+			if batch != tt.wantBatch {
+				t.Errorf("batch = %v, want %v", batch, tt.wantBatch)
+			}
+			var rels []string
+			for _, input := range inputs {
+				rels = append(rels, input.rel)
+			}
+			if strings.Join(rels, ",") != strings.Join(tt.wantRels, ",") {
+				t.Errorf("outputs = %v, want %v", rels, tt.wantRels)
+			}
+		})
+	}
+}
+
+func TestOutputRel(t *testing.T) {
+	tests := []struct {
+		dir  string
+		path string
+		want string
+	}{
+		{dir: "specs", path: filepath.Join("specs", "pet.yaml"), want: "pet"},
+		{dir: "specs", path: filepath.Join("specs", "store", "order.json"), want: filepath.Join("store", "order")},
+		{dir: "specs", path: filepath.Join("other", "user.yaml"), want: "user"},
+		{dir: "specs", path: filepath.Join("specs", "..pet.yaml"), want: "..pet"},
+	}
+	for _, tt := range tests {
+		if got := outputRel(tt.dir, tt.path); got != tt.want {
+			t.Errorf("outputRel(%q, %q) = %q, want %q", tt.dir, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestGlobBase(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    string
+	}{
+		{pattern: "*.yaml", want: "."},
+		{pattern: filepath.Join("specs", "*.yaml"), want: "specs"},
+		{pattern: filepath.Join("specs", "v[12]", "*", "*.yaml"), want: "specs"},
+	}
+	for _, tt := range tests {
+		if got := globBase(tt.pattern); got != tt.want {
+			t.Errorf("globBase(%q) = %q, want %q", tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestRunBatchOptions(t *testing.T) {
+	inputs := []specInput{{path: "a.yaml", rel: "a"}, {path: "b.yaml", rel: "b"}}
+	tests := []struct {
+		name    string
+		setup   func()
+		wantErr string
+	}{
+		{name: "without type", setup: func() {}, wantErr: "--type"},
+		{name: "with output", setup: func() { outputType, outputFile = "proto", "out.proto" }, wantErr: "--out-dir"},
+		{name: "with lock", setup: func() { outputType, lockFile = "proto", "lock.json" }, wantErr: "--lock"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() { outputType, outputFile, lockFile = "", "", "" }()
+			tt.setup()
+			if err := runBatch(inputs); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("runBatch() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -1,5 +1,16 @@
 package converter
 
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/hertz-contrib/swagger-generate/swagger2idl/parser"
+	"github.com/hertz-contrib/swagger-generate/swagger2idl/utils"
+)
+
 type Converter interface {
 	Convert() error
 	GetIdl() interface{}
@@ -7,11 +18,70 @@ type Converter interface {
 
 // ConvertOption adds a struct for conversion options
 type ConvertOption struct {
-	OpenapiOption bool
-	ApiOption     bool
-	NamingOption  bool
+	OpenapiOption      bool
+	ApiOption          bool
+	NamingOption       bool
+	RequirednessOption string // Thrift requiredness policy, RequirednessLenient or RequirednessStrict
+	PresenceOption     string // Proto presence tracking, PresenceNone, PresenceOptional or PresenceWrapper
+	OrderOption        string // Declaration order of the output, OrderSorted or OrderSpec
+	FragmentOption     string // Use of referenced parameters and headers, FragmentEmbed or FragmentReference
+	DiscriminatorEnum  bool   // Declare an enum of the discriminator values of each discriminated oneOf and anyOf
+	AllOfOption        string // Conversion of allOf schemas, AllOfFlatten or AllOfEmbed
+	// TypeMappings map scalar schemas onto IDL types, taking precedence over the default mappings
+	TypeMappings []parser.TypeMapping
 }
 
+const (
+	// RequirednessLenient maps required fields to default requiredness and others to `optional`
+	RequirednessLenient = "lenient"
+	// RequirednessStrict maps required fields to `required` and others to `optional`
+	RequirednessStrict = "strict"
+)
+
+const (
+	// PresenceOptional marks non-required or nullable proto scalars as proto3 `optional`
+	PresenceOptional = "optional"
+	// PresenceWrapper maps non-required or nullable proto scalars to google.protobuf wrapper types
+	PresenceWrapper = "wrapper"
+	// PresenceNone keeps plain proto3 scalars without presence tracking, the default
+	PresenceNone = "none"
+)
+
+const (
+	// OrderSorted sorts declarations, fields and methods by name
+	OrderSorted = "sorted"
+	// OrderSpec keeps the declaration order of the spec document
+	OrderSpec = "spec"
+)
+
+const (
+	// FragmentEmbed embeds referenced parameters and headers field by field into each message
+	FragmentEmbed = "embed"
+	// FragmentReference declares a fragment message per group of parameters referenced by the same
+	// operations and per referenced header, and adds a field of the fragment type to each message
+	FragmentReference = "reference"
+)
+
+const (
+	// AllOfFlatten merges the parts of an allOf, referenced or inline, into a single message
+	AllOfFlatten = "flatten"
+	// AllOfEmbed merges the inline parts of an allOf and adds a field of the type of each
+	// referenced part
+	AllOfEmbed = "embed"
+)
+
+// componentsRefPrefix starts the references to the components of the root document
+const componentsRefPrefix = "#/components/"
+
+// multipartFormData is the media type of the request bodies carrying file parts
+const multipartFormData = "multipart/form-data"
+
+// formFileName names the message of the file parts of multipart/form-data request bodies
+const formFileName = "FormFile"
+
+// fieldIDExtensions lists the extensions that pin the ID of a field, in order of precedence
+var fieldIDExtensions = []string{"x-thrift-id", "x-field-id"}
+
 var (
 	MethodToOption = map[string]string{
 		"GET":     "api.get",
@@ -23,3 +93,360 @@ var (
 		"OPTIONS": "api.options",
 	}
 )
+
+// orderedKeys returns the keys of m in the declared order when the spec order is selected,
+// followed by any undeclared keys in ascending order
+func orderedKeys[V any](m map[string]V, declared []string, option *ConvertOption) []string {
+	if option.OrderOption != OrderSpec || len(declared) == 0 {
+		keys := make([]string, 0, len(m))
+		for key := range m {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		return keys
+	}
+
+	keys := make([]string, 0, len(m))
+	seen := make(map[string]bool, len(m))
+	for _, key := range declared {
+		if _, ok := m[key]; ok && !seen[key] {
+			keys = append(keys, key)
+			seen[key] = true
+		}
+	}
+	var rest []string
+	for key := range m {
+		if !seen[key] {
+			rest = append(rest, key)
+		}
+	}
+	sort.Strings(rest)
+	return append(keys, rest...)
+}
+
+// withPathParameters returns the operation with the parameters declared on its path item, which
+// apply to every operation of the path unless the operation overrides them
+func withPathParameters(pathItem *openapi3.PathItem, operation *openapi3.Operation) *openapi3.Operation {
+	if len(pathItem.Parameters) == 0 {
+		return operation
+	}
+
+	params := make(openapi3.Parameters, 0, len(pathItem.Parameters)+len(operation.Parameters))
+	for _, param := range pathItem.Parameters {
+		if param.Value != nil && operation.Parameters.GetByInAndName(param.Value.In, param.Value.Name) != nil {
+			continue
+		}
+		params = append(params, param)
+	}
+	merged := *operation
+	merged.Parameters = append(params, operation.Parameters...)
+	return &merged
+}
+
+// parameterGroups partitions the parameters referenced from components/parameters into groups of
+// parameters referenced by exactly the same operations, such as the page and page_size of every
+// paginated operation. Each referenced parameter maps onto the references of its group, in the order
+// they are first declared.
+func parameterGroups(paths *openapi3.Paths) map[*openapi3.Parameter][]*openapi3.ParameterRef {
+	var refs []*openapi3.ParameterRef
+	usage := make(map[*openapi3.Parameter][]string)
+	if paths != nil {
+		for _, path := range utils.SortedKeys(paths.Map()) {
+			pathItem := paths.Value(path)
+			operations := pathItem.Operations()
+			for _, method := range utils.SortedKeys(operations) {
+				operation := withPathParameters(pathItem, operations[method])
+				for _, param := range operation.Parameters {
+					if param.Ref == "" || param.Value == nil {
+						continue
+					}
+					if _, ok := usage[param.Value]; !ok {
+						refs = append(refs, param)
+					}
+					usage[param.Value] = append(usage[param.Value], method+" "+path)
+				}
+			}
+		}
+	}
+
+	byUsage := make(map[string][]*openapi3.ParameterRef)
+	for _, param := range refs {
+		key := strings.Join(usage[param.Value], "\n")
+		byUsage[key] = append(byUsage[key], param)
+	}
+	groups := make(map[*openapi3.Parameter][]*openapi3.ParameterRef, len(refs))
+	for _, param := range refs {
+		groups[param.Value] = byUsage[strings.Join(usage[param.Value], "\n")]
+	}
+	return groups
+}
+
+// discriminatorValues returns the discriminator value of each branch of a oneOf or anyOf schema,
+// taken from the mapping of the discriminator or, for referenced branches missing from the mapping,
+// from the name of the referenced schema. Inline branches take the value their discriminator
+// property is restricted to by a single enum value. Other inline branches and branches of a schema
+// without a discriminator have no value. Mapping keys matching no branch and branches not declaring
+// the discriminator property are reported to d.
+func discriminatorValues(d *diagnostics, schema *openapi3.Schema, branches []*openapi3.SchemaRef) []string {
+	values := make([]string, len(branches))
+	discriminator := schema.Discriminator
+	if discriminator == nil {
+		return values
+	}
+
+	for _, key := range utils.SortedKeys(discriminator.Mapping) {
+		target := discriminator.Mapping[key]
+		matched := false
+		for i, branch := range branches {
+			if branch.Ref == "" || (target != branch.Ref && target != utils.ExtractMessageNameFromRef(branch.Ref)) {
+				continue
+			}
+			matched = true
+			if values[i] == "" {
+				values[i] = key
+			}
+		}
+		if !matched {
+			d.warn(schema, "discriminator mapping %q matches none of the schemas", key)
+		}
+	}
+
+	for i, branch := range branches {
+		if branch.Value == nil {
+			if values[i] == "" && branch.Ref != "" {
+				values[i] = utils.ExtractMessageNameFromRef(branch.Ref)
+			}
+			continue
+		}
+		property := propertyOf(branch.Value, discriminator.PropertyName)
+		if property == nil {
+			d.warn(branch.Value, "discriminator property %q is not declared", discriminator.PropertyName)
+		}
+		if values[i] != "" {
+			continue
+		}
+		if branch.Ref != "" {
+			values[i] = utils.ExtractMessageNameFromRef(branch.Ref)
+		} else if property != nil && property.Value != nil && len(property.Value.Enum) == 1 {
+			values[i] = fmt.Sprint(property.Value.Enum[0])
+		}
+	}
+	return values
+}
+
+// propertyOf returns the property declared by the schema, or one of its allOf parts, under name,
+// or nil if there is none
+func propertyOf(schema *openapi3.Schema, name string) *openapi3.SchemaRef {
+	seen := make(map[*openapi3.Schema]bool)
+	var lookup func(s *openapi3.Schema) *openapi3.SchemaRef
+	lookup = func(s *openapi3.Schema) *openapi3.SchemaRef {
+		if seen[s] {
+			return nil
+		}
+		seen[s] = true
+		if property, ok := s.Properties[name]; ok {
+			return property
+		}
+		for _, part := range s.AllOf {
+			if part.Value == nil {
+				continue
+			}
+			if property := lookup(part.Value); property != nil {
+				return property
+			}
+		}
+		return nil
+	}
+	return lookup(schema)
+}
+
+// flattenAllOf merges the parts of an allOf schema and the properties declared next to the allOf
+// into a single schema with the combined properties and required lists. Parts declaring an allOf
+// themselves are merged recursively. In embed mode the referenced parts are returned as bases
+// instead of being merged. A property declared by several parts keeps its first declaration,
+// redeclarations of another type are reported to d.
+func flattenAllOf(d *diagnostics, order *parser.SpecOrder, schema *openapi3.Schema, embed bool) (merged *openapi3.Schema, bases []*openapi3.SchemaRef) {
+	var parts []*openapi3.Schema
+	seen := make(map[*openapi3.Schema]bool)
+	var collect func(s *openapi3.Schema)
+	collect = func(s *openapi3.Schema) {
+		if seen[s] {
+			return
+		}
+		seen[s] = true
+		for _, part := range s.AllOf {
+			if part.Value == nil {
+				continue
+			}
+			if embed && part.Ref != "" {
+				bases = append(bases, part)
+				continue
+			}
+			collect(part.Value)
+		}
+		parts = append(parts, s)
+	}
+	collect(schema)
+
+	// An allOf of scalar schemas constrains a single value of the type of its first typed part
+	objectLike := len(bases) > 0
+	var typed *openapi3.Schema
+	for _, part := range parts {
+		if part.Type.Includes(openapi3.TypeObject) || len(part.Properties) > 0 || part.AdditionalProperties.Schema != nil {
+			objectLike = true
+		}
+		if typed == nil && len(part.Type.Slice()) > 0 {
+			typed = part
+		}
+	}
+	if !objectLike && typed != nil {
+		scalar := *typed
+		scalar.AllOf = nil
+		scalar.Description = schema.Description
+		return &scalar, nil
+	}
+
+	merged = &openapi3.Schema{
+		Type:        &openapi3.Types{openapi3.TypeObject},
+		Description: schema.Description,
+		Properties:  make(openapi3.Schemas),
+	}
+	for _, part := range parts {
+		for _, name := range utils.SortedKeys(part.Properties) {
+			property := part.Properties[name]
+			existing, ok := merged.Properties[name]
+			if !ok {
+				merged.Properties[name] = property
+				continue
+			}
+			if shape, existingShape := schemaShape(property), schemaShape(existing); shape != "" && existingShape != "" && shape != existingShape {
+				d.warn(property.Value, "property %s of type %s conflicts with an earlier allOf part declaring it as %s, the earlier declaration is kept", name, shape, existingShape)
+			}
+		}
+		for _, name := range part.Required {
+			if !utils.Contains(merged.Required, name) {
+				merged.Required = append(merged.Required, name)
+			}
+		}
+		if merged.AdditionalProperties.Schema == nil && merged.AdditionalProperties.Has == nil {
+			merged.AdditionalProperties = part.AdditionalProperties
+		}
+	}
+	order.MergePropertyOrder(merged, parts...)
+	return merged, bases
+}
+
+// discriminatorEnumValue returns the enum value of a discriminator value. Proto enum values are
+// scoped to the package, the enum name keeps them apart from the values of other discriminators,
+// and Thrift values are named the same way.
+func discriminatorEnumValue(enumName, value string) string {
+	return utils.ToUpperSnakeCase(enumName) + "_" + value
+}
+
+// schemaShape describes the IDL type a property schema converts into, or returns "" if the schema
+// leaves the type open
+func schemaShape(schemaRef *openapi3.SchemaRef) string {
+	var shape strings.Builder
+	seen := make(map[*openapi3.Schema]bool)
+	for schemaRef != nil {
+		if schemaRef.Ref != "" {
+			shape.WriteString(utils.ExtractMessageNameFromRef(schemaRef.Ref))
+			break
+		}
+		schema := schemaRef.Value
+		if schema == nil || len(schema.Type.Slice()) == 0 || seen[schema] {
+			break
+		}
+		seen[schema] = true
+		shape.WriteString(strings.Join(schema.Type.Slice(), "|"))
+		if schema.Format != "" {
+			shape.WriteString("(" + schema.Format + ")")
+		}
+		if !schema.Type.Includes(openapi3.TypeArray) {
+			break
+		}
+		// Arrays are described by the shape of their items
+		shape.WriteString("[]")
+		schemaRef = schema.Items
+	}
+	return shape.String()
+}
+
+// schemaTypeOrder lists the schema types in the order the converters try them
+var schemaTypeOrder = []string{
+	openapi3.TypeString, openapi3.TypeInteger, openapi3.TypeNumber,
+	openapi3.TypeBoolean, openapi3.TypeArray, openapi3.TypeObject,
+}
+
+// isNullable reports whether a schema accepts null, through the nullable flag of OpenAPI 3.0 or
+// the null type of OpenAPI 3.1
+func isNullable(schemaRef *openapi3.SchemaRef) bool {
+	return schemaRef != nil && schemaRef.Value != nil && schemaRef.Value.PermitsNull()
+}
+
+// isScalarSchema reports whether a schema is converted as a single value, i.e. it is neither an
+// object nor an array. Schemas without a type fall back to a scalar as well.
+func isScalarSchema(schemaRef *openapi3.SchemaRef) bool {
+	if schemaRef == nil || schemaRef.Value == nil || len(schemaRef.Value.Properties) != 0 {
+		return false
+	}
+	schemaType := schemaRef.Value.Type
+	return !schemaType.Includes(openapi3.TypeObject) && !schemaType.Includes(openapi3.TypeArray)
+}
+
+// isComponentRef reports whether a reference names a component of the root document, rather than
+// a schema nested in a component such as one of its $defs
+func isComponentRef(ref string) bool {
+	return strings.HasPrefix(ref, componentsRefPrefix) && strings.Count(strings.TrimPrefix(ref, componentsRefPrefix), "/") == 1
+}
+
+// externalRefKey identifies the schema of an external reference by its document and JSON pointer,
+// as the same pointer names different schemas in different documents
+func externalRefKey(source, ref string) string {
+	_, pointer, _ := strings.Cut(ref, "#")
+	return source + "#" + pointer
+}
+
+// documentQualifier returns the name of a document without its directory and extension, in
+// pascal case, or an empty string for the root document
+func documentQualifier(source string) string {
+	base := path.Base(source)
+	if source == "" || base == "." {
+		return ""
+	}
+	return utils.ToPascaleCase(strings.TrimSuffix(base, path.Ext(base)))
+}
+
+// checkTypeList reports a schema allowing several types other than null, which is converted as
+// the first of them in schemaTypeOrder
+func checkTypeList(d *diagnostics, schema *openapi3.Schema) {
+	var types []string
+	for _, typ := range schema.Type.Slice() {
+		if typ != openapi3.TypeNull {
+			types = append(types, typ)
+		}
+	}
+	if len(types) < 2 {
+		return
+	}
+	for _, typ := range schemaTypeOrder {
+		if schema.Type.Includes(typ) {
+			d.warn(schema, "schema of types %s is converted as %s", strings.Join(types, ", "), typ)
+			return
+		}
+	}
+}
+
+// isFormFile reports whether a part of a multipart/form-data request body is a file, that is a
+// binary string or a string with a content media type, or a list of files
+func isFormFile(schemaRef *openapi3.SchemaRef) bool {
+	if schemaRef == nil || schemaRef.Value == nil {
+		return false
+	}
+	schema := schemaRef.Value
+	if schema.Type.Includes(openapi3.TypeArray) && schema.Items != nil && schema.Items.Value != nil {
+		schema = schema.Items.Value
+	}
+	_, hasMediaType := schema.Extensions["contentMediaType"]
+	return schema.Type.Includes(openapi3.TypeString) && (schema.Format == "binary" || hasMediaType)
+}
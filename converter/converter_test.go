@@ -0,0 +1,387 @@
+package converter
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/hertz-contrib/swagger-generate/swagger2idl/parser"
+)
+
+// fragmentSpec references shared parameters from several operations
+const fragmentSpec = `openapi: 3.0.3
+info: {title: test, version: "1"}
+paths:
+  /items:
+    parameters:
+      - $ref: '#/components/parameters/RequestId'
+    get:
+      operationId: listItems
+      parameters:
+        - $ref: '#/components/parameters/Page'
+        - $ref: '#/components/parameters/PageSize'
+      responses:
+        "200": {description: ok}
+  /users:
+    get:
+      operationId: listUsers
+      parameters:
+        - $ref: '#/components/parameters/PageSize'
+        - $ref: '#/components/parameters/Page'
+        - $ref: '#/components/parameters/RequestId'
+        - {name: q, in: query, schema: {type: string}}
+      responses:
+        "200": {description: ok}
+  /users/{id}:
+    get:
+      operationId: getUser
+      parameters:
+        - {name: id, in: path, required: true, schema: {type: string}}
+        - $ref: '#/components/parameters/RequestId'
+      responses:
+        "200": {description: ok}
+components:
+  parameters:
+    Page: {name: page, in: query, schema: {type: integer}}
+    PageSize: {name: page_size, in: query, schema: {type: integer}}
+    RequestId: {name: X-Request-Id, in: header, schema: {type: string}}
+`
+
+func TestParameterGroups(t *testing.T) {
+	spec := loadSpec(t, fragmentSpec)
+	groups := parameterGroups(spec.Paths)
+
+	names := func(group []*openapi3.ParameterRef) []string {
+		var names []string
+		for _, param := range group {
+			names = append(names, param.Value.Name)
+		}
+		return names
+	}
+	tests := []struct {
+		component string
+		want      []string
+	}{
+		{component: "Page", want: []string{"page", "page_size"}},
+		{component: "PageSize", want: []string{"page", "page_size"}},
+		{component: "RequestId", want: []string{"X-Request-Id"}},
+	}
+	for _, tt := range tests {
+		param := spec.Components.Parameters[tt.component].Value
+		if got := names(groups[param]); !equalStrings(got, tt.want) {
+			t.Errorf("group of %s = %v, want %v", tt.component, got, tt.want)
+		}
+	}
+	if len(groups) != 3 {
+		t.Errorf("groups = %d parameters, want 3", len(groups))
+	}
+}
+
+// sharedBodySpec references a request body and a response from several operations
+const sharedBodySpec = `openapi: 3.0.3
+info: {title: test, version: "1"}
+paths:
+  /pets:
+    post:
+      operationId: createPet
+      requestBody:
+        $ref: '#/components/requestBodies/PetBody'
+      responses:
+        "201":
+          $ref: '#/components/responses/PetResponse'
+  /pets/{id}:
+    put:
+      operationId: updatePet
+      parameters:
+        - {name: id, in: path, required: true, schema: {type: string}}
+      requestBody:
+        $ref: '#/components/requestBodies/PetBody'
+      responses:
+        "200":
+          $ref: '#/components/responses/PetResponse'
+        "204":
+          description: empty
+          content:
+            application/json:
+              schema: {type: object}
+components:
+  requestBodies:
+    PetBody:
+      content:
+        application/json:
+          schema:
+            type: object
+            properties:
+              name: {type: string}
+  responses:
+    PetResponse:
+      description: ok
+      content:
+        application/json:
+          schema:
+            type: object
+            properties:
+              id: {type: string}
+`
+
+// orderSpec declares paths, schemas and properties out of alphabetical order
+const orderSpec = `openapi: 3.0.3
+info: {title: test, version: "1"}
+paths:
+  /zoo:
+    get:
+      operationId: listZoo
+      responses:
+        "200":
+          description: ok
+          content:
+            application/json:
+              schema: {$ref: '#/components/schemas/Zebra'}
+  /ant:
+    get:
+      operationId: getAnt
+      responses:
+        "200":
+          description: ok
+          content:
+            application/json:
+              schema: {$ref: '#/components/schemas/Ant'}
+components:
+  schemas:
+    Zebra:
+      type: object
+      properties:
+        stripes: {type: integer}
+        age: {type: integer}
+    Ant:
+      type: object
+      properties:
+        legs: {type: integer}
+`
+
+func TestConverterOrder(t *testing.T) {
+	tests := []struct {
+		name         string
+		order        string
+		wantMessages []string
+		wantFields   []string
+		wantMethods  []string
+	}{
+		{
+			name:         "sorted",
+			order:        OrderSorted,
+			wantMessages: []string{"Ant", "GetAntResponse", "ListZooResponse", "Zebra"},
+			wantFields:   []string{"Age", "Stripes"},
+			wantMethods:  []string{"GetAnt", "ListZoo"},
+		},
+		{
+			name:         "spec",
+			order:        OrderSpec,
+			wantMessages: []string{"Zebra", "Ant", "ListZooResponse", "GetAntResponse"},
+			wantFields:   []string{"Stripes", "Age"},
+			wantMethods:  []string{"ListZoo", "GetAnt"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := loadSpec(t, orderSpec)
+			specOrder, err := parser.ParseSpecOrder([]byte(orderSpec), spec)
+			if err != nil {
+				t.Fatalf("ParseSpecOrder() error = %v", err)
+			}
+			option := &ConvertOption{NamingOption: true, OrderOption: tt.order}
+
+			protoConv := NewProtoConverter(spec, option)
+			protoConv.SetSpecOrder(specOrder)
+			if err := protoConv.Convert(); err != nil {
+				t.Fatalf("proto Convert() error = %v", err)
+			}
+			var messages, methods []string
+			for _, message := range protoConv.ProtoFile.Messages {
+				messages = append(messages, message.Name)
+			}
+			for _, method := range protoConv.ProtoFile.Services[0].Methods {
+				methods = append(methods, method.Name)
+			}
+			var fields []string
+			for _, field := range protoMessage(t, protoConv.ProtoFile, "Zebra").Fields {
+				fields = append(fields, field.Name)
+			}
+			if !equalStrings(messages, tt.wantMessages) || !equalStrings(fields, tt.wantFields) || !equalStrings(methods, tt.wantMethods) {
+				t.Errorf("proto order = %v %v %v, want %v %v %v", messages, fields, methods, tt.wantMessages, tt.wantFields, tt.wantMethods)
+			}
+
+			thriftConv := NewThriftConverter(spec, option)
+			thriftConv.SetSpecOrder(specOrder)
+			if err := thriftConv.Convert(); err != nil {
+				t.Fatalf("thrift Convert() error = %v", err)
+			}
+			messages, methods, fields = nil, nil, nil
+			for _, message := range thriftConv.ThriftFile.Structs {
+				messages = append(messages, message.Name)
+			}
+			for _, method := range thriftConv.ThriftFile.Services[0].Methods {
+				methods = append(methods, method.Name)
+			}
+			for _, field := range thriftStruct(t, thriftConv.ThriftFile, "Zebra").Fields {
+				fields = append(fields, field.Name)
+			}
+			if !equalStrings(messages, tt.wantMessages) || !equalStrings(fields, tt.wantFields) || !equalStrings(methods, tt.wantMethods) {
+				t.Errorf("thrift order = %v %v %v, want %v %v %v", messages, fields, methods, tt.wantMessages, tt.wantFields, tt.wantMethods)
+			}
+		})
+	}
+}
+
+func TestConverterRecursiveSchemas(t *testing.T) {
+	tests := []struct {
+		name       string
+		setup      func(spec *openapi3.T)
+		wantFields []string
+	}{
+		{
+			// A referenced property is named after the schema
+			name:       "referenced",
+			wantFields: []string{"Node Node", "children Node", "value string"},
+		},
+		{
+			// The property points back at its schema without a reference, as the loader resolves
+			// references of other documents
+			name: "inline",
+			setup: func(spec *openapi3.T) {
+				node := spec.Components.Schemas["Node"]
+				node.Value.Properties["parent"] = &openapi3.SchemaRef{Value: node.Value}
+			},
+			wantFields: []string{"children Node", "parent Node", "value string"},
+		},
+	}
+	for _, tt := range tests {
+		for _, target := range []string{"proto", "thrift"} {
+			t.Run(tt.name+" "+target, func(t *testing.T) {
+				spec := loadSpec(t, `openapi: 3.0.3
+info: {title: test, version: "1"}
+paths: {}
+components:
+  schemas:
+    Node:
+      type: object
+      properties:
+        value: {type: string}
+        children:
+          type: array
+          items: {$ref: '#/components/schemas/Node'}
+        parent: {$ref: '#/components/schemas/Node'}
+`)
+				if tt.setup != nil {
+					tt.setup(spec)
+				}
+
+				var fields []string
+				if target == "proto" {
+					c := NewProtoConverter(spec, &ConvertOption{})
+					if err := c.Convert(); err != nil {
+						t.Fatalf("Convert() error = %v", err)
+					}
+					fields = protoFields(protoMessage(t, c.ProtoFile, "Node").Fields)
+				} else {
+					c := NewThriftConverter(spec, &ConvertOption{})
+					if err := c.Convert(); err != nil {
+						t.Fatalf("Convert() error = %v", err)
+					}
+					fields = thriftFields(thriftStruct(t, c.ThriftFile, "Node").Fields)
+				}
+
+				// The recursive fields reference the message itself
+				if !equalStrings(fields, tt.wantFields) {
+					t.Errorf("fields of Node = %v, want %v", fields, tt.wantFields)
+				}
+			})
+		}
+	}
+}
+
+// uploadSpec declares a multipart/form-data body with a file, a list of files and a binary part
+const uploadSpec = `openapi: 3.0.3
+info: {title: test, version: "1"}
+paths:
+  /upload:
+    post:
+      operationId: upload
+      requestBody:
+        content:
+          multipart/form-data:
+            schema:
+              type: object
+              properties:
+                avatar: {type: string, format: binary}
+                photos:
+                  type: array
+                  items: {type: string, format: binary}
+                checksum: {type: string, format: byte}
+                note: {type: string}
+      responses:
+        "204": {description: uploaded}
+`
+
+func TestConverterFormFiles(t *testing.T) {
+	tests := []struct {
+		target         string
+		wantFields     []string
+		wantFileFields []string
+	}{
+		{
+			target:         "proto",
+			wantFields:     []string{"Avatar FormFile", "Checksum bytes", "Note string", "Photos FormFile"},
+			wantFileFields: []string{"Content bytes", "ContentType string", "Filename string"},
+		},
+		{
+			target:         "thrift",
+			wantFields:     []string{"Avatar FormFile", "Checksum binary", "Note string", "Photos FormFile"},
+			wantFileFields: []string{"Content binary", "ContentType string", "Filename string"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			option := &ConvertOption{ApiOption: true, NamingOption: true}
+			var fields, fileFields, forms []string
+			if tt.target == "proto" {
+				c := NewProtoConverter(loadSpec(t, uploadSpec), option)
+				if err := c.Convert(); err != nil {
+					t.Fatalf("Convert() error = %v", err)
+				}
+				request := protoMessage(t, c.ProtoFile, "UploadRequest")
+				fields = protoFields(request.Fields)
+				fileFields = protoFields(protoMessage(t, c.ProtoFile, formFileName).Fields)
+				for _, field := range request.Fields {
+					for _, option := range field.Options {
+						forms = append(forms, fmt.Sprintf("%s=%v", option.Name, option.Value))
+					}
+				}
+			} else {
+				c := NewThriftConverter(loadSpec(t, uploadSpec), option)
+				if err := c.Convert(); err != nil {
+					t.Fatalf("Convert() error = %v", err)
+				}
+				request := thriftStruct(t, c.ThriftFile, "UploadRequest")
+				fields = thriftFields(request.Fields)
+				fileFields = thriftFields(thriftStruct(t, c.ThriftFile, formFileName).Fields)
+				for _, field := range request.Fields {
+					for _, option := range field.Options {
+						forms = append(forms, fmt.Sprintf("%s=%v", option.Name, option.Value))
+					}
+				}
+			}
+
+			if !equalStrings(fields, tt.wantFields) {
+				t.Errorf("fields of UploadRequest = %v, want %v", fields, tt.wantFields)
+			}
+			if !equalStrings(fileFields, tt.wantFileFields) {
+				t.Errorf("fields of %s = %v, want %v", formFileName, fileFields, tt.wantFileFields)
+			}
+			wantForms := []string{`api.form="Avatar"`, `api.form="Checksum"`, `api.form="Note"`, `api.form="Photos"`}
+			if !equalStrings(forms, wantForms) {
+				t.Errorf("options of UploadRequest = %v, want %v", forms, wantForms)
+			}
+		})
+	}
+}
@@ -0,0 +1,373 @@
+package converter
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hertz-contrib/swagger-generate/swagger2idl/protobuf"
+	"github.com/hertz-contrib/swagger-generate/swagger2idl/thrift"
+	"github.com/hertz-contrib/swagger-generate/swagger2idl/utils"
+)
+
+// protoBuiltinTypes lists the type names that resolve in any scope of a proto file
+var protoBuiltinTypes = map[string]bool{
+	"double": true, "float": true, "int32": true, "int64": true, "uint32": true, "uint64": true,
+	"sint32": true, "sint64": true, "fixed32": true, "fixed64": true, "sfixed32": true,
+	"sfixed64": true, "bool": true, "string": true, "bytes": true, "map": true,
+}
+
+// uniqueName returns name, or name followed by the lowest number from 2 that is not declared
+func uniqueName(name string, declared map[string]bool) string {
+	if !declared[name] {
+		return name
+	}
+	for i := 2; ; i++ {
+		if candidate := name + strconv.Itoa(i); !declared[candidate] {
+			return candidate
+		}
+	}
+}
+
+// protoMessageKey returns the structural key of a message: its fields, oneofs, nested declarations
+// and options, leaving out its name and descriptions. Two messages with the same key convert into
+// the same IDL. Fields are taken in name order when sorted is set, as the output orders them so.
+func protoMessageKey(message *protobuf.ProtoMessage, sorted bool) string {
+	var sb strings.Builder
+	writeFields := func(fields []*protobuf.ProtoField) {
+		keys := make([]string, 0, len(fields))
+		for _, field := range fields {
+			keys = append(keys, fmt.Sprintf("%s %s %d %t %t %s", field.Name, field.Type, field.Number, field.Repeated, field.Optional, protoOptionsKey(field.Options)))
+		}
+		if sorted {
+			sort.Strings(keys)
+		}
+		sb.WriteString("{" + strings.Join(keys, ";") + "}")
+	}
+
+	writeFields(message.Fields)
+	for _, oneOf := range message.OneOfs {
+		sb.WriteString("oneof " + oneOf.Name)
+		writeFields(oneOf.Fields)
+	}
+	for _, nestedMessage := range message.Messages {
+		sb.WriteString("message " + nestedMessage.Name + protoMessageKey(nestedMessage, sorted))
+	}
+	for _, nestedEnum := range message.Enums {
+		sb.WriteString("enum " + nestedEnum.Name + protoEnumKey(nestedEnum))
+	}
+	sb.WriteString(protoOptionsKey(message.Options))
+	sb.WriteString(fmt.Sprint(message.Reserved, message.ReservedNames))
+	return sb.String()
+}
+
+// protoEnumKey returns the structural key of the values and options of an enum
+func protoEnumKey(enum *protobuf.ProtoEnum) string {
+	var sb strings.Builder
+	sb.WriteString("{")
+	for _, value := range enum.Values {
+		sb.WriteString(fmt.Sprintf("%d=%v;", value.Index, value.Value))
+	}
+	sb.WriteString("}")
+	sb.WriteString(protoOptionsKey(enum.Options))
+	return sb.String()
+}
+
+func protoOptionsKey(options []*protobuf.Option) string {
+	var sb strings.Builder
+	for _, option := range options {
+		sb.WriteString(fmt.Sprintf("[%s=%v]", option.Name, option.Value))
+	}
+	return sb.String()
+}
+
+// renameProtoMessage renames a message along with the references of its fields to itself
+func renameProtoMessage(message *protobuf.ProtoMessage, name string) {
+	retargetProtoFields(message, map[string]string{message.Name: name})
+	message.Name = name
+}
+
+// retargetProtoFields rewrites the field types of a message and of its nested messages
+func retargetProtoFields(message *protobuf.ProtoMessage, renames map[string]string) {
+	replace := func(name string) string {
+		if renamed, ok := renames[name]; ok {
+			return renamed
+		}
+		return name
+	}
+	for _, field := range message.Fields {
+		field.Type = utils.ReplaceTypeNames(field.Type, replace)
+	}
+	for _, oneOf := range message.OneOfs {
+		for _, field := range oneOf.Fields {
+			field.Type = utils.ReplaceTypeNames(field.Type, replace)
+		}
+	}
+	for _, nestedMessage := range message.Messages {
+		retargetProtoFields(nestedMessage, renames)
+	}
+}
+
+// nestedProtoType is a nested message or enum along with the message declaring it
+type nestedProtoType struct {
+	parent  *protobuf.ProtoMessage
+	message *protobuf.ProtoMessage
+	enum    *protobuf.ProtoEnum
+}
+
+// name returns the name of the nested declaration
+func (n nestedProtoType) name() string {
+	if n.enum != nil {
+		return n.enum.Name
+	}
+	return n.message.Name
+}
+
+// remove takes the nested declaration out of its parent
+func (n nestedProtoType) remove() {
+	if n.enum != nil {
+		for i, nestedEnum := range n.parent.Enums {
+			if nestedEnum == n.enum {
+				n.parent.Enums = append(n.parent.Enums[:i], n.parent.Enums[i+1:]...)
+				return
+			}
+		}
+		return
+	}
+	for i, nestedMessage := range n.parent.Messages {
+		if nestedMessage == n.message {
+			n.parent.Messages = append(n.parent.Messages[:i], n.parent.Messages[i+1:]...)
+			return
+		}
+	}
+}
+
+// dedupeNestedTypes hoists the nested messages and enums that are declared identically in several
+// places of the same document into a single top-level declaration, named after the first of them,
+// that all the places refer to
+func (c *ProtoConverter) dedupeNestedTypes() {
+	sorted := c.converterOption.OrderOption != OrderSpec
+	for {
+		declared := c.declaredTypes()
+		groups := make(map[string][]nestedProtoType)
+		var keys []string
+		group := func(key string, nested nestedProtoType) {
+			if _, ok := groups[key]; !ok {
+				keys = append(keys, key)
+			}
+			groups[key] = append(groups[key], nested)
+		}
+		var collect func(parent *protobuf.ProtoMessage, source string, enclosing map[string]bool)
+		collect = func(parent *protobuf.ProtoMessage, source string, enclosing map[string]bool) {
+			scope := make(map[string]bool, len(enclosing))
+			for name := range enclosing {
+				scope[name] = true
+			}
+			for _, nestedMessage := range parent.Messages {
+				scope[nestedMessage.Name] = true
+			}
+			for _, nestedEnum := range parent.Enums {
+				scope[nestedEnum.Name] = true
+			}
+			for _, nestedMessage := range parent.Messages {
+				collect(nestedMessage, source, scope)
+				if !protoMessageResolves(nestedMessage, declared, scope) {
+					continue
+				}
+				// Declarations of different documents are not shared, the documents would import each other
+				group(source+"#message"+protoMessageKey(nestedMessage, sorted), nestedProtoType{parent: parent, message: nestedMessage})
+			}
+			for _, nestedEnum := range parent.Enums {
+				group(source+"#enum"+protoEnumKey(nestedEnum), nestedProtoType{parent: parent, enum: nestedEnum})
+			}
+		}
+		for _, message := range c.ProtoFile.Messages {
+			collect(message, c.declSources[message.Name], nil)
+		}
+
+		hoisted := false
+		for _, key := range keys {
+			nested := groups[key]
+			if len(nested) < 2 {
+				continue
+			}
+			name := uniqueName(nested[0].name(), c.declaredTypes())
+			for _, n := range nested {
+				n.remove()
+				retargetProtoFields(n.parent, map[string]string{n.name(): name})
+			}
+			if shared := nested[0]; shared.enum != nil {
+				shared.enum.Name = name
+				c.ProtoFile.Enums = append(c.ProtoFile.Enums, shared.enum)
+			} else {
+				renameProtoMessage(shared.message, name)
+				c.ProtoFile.Messages = append(c.ProtoFile.Messages, shared.message)
+			}
+			if source, _, _ := strings.Cut(key, "#"); source != "" {
+				c.declSources[name] = source
+			}
+			hoisted = true
+		}
+		if !hoisted {
+			return
+		}
+	}
+}
+
+// protoMessageResolves reports whether every type used by a nested message resolves the same way
+// once the message is moved to the top level: builtin types, qualified types, the declarations of
+// the message itself and top-level declarations not shadowed by the enclosing messages
+func protoMessageResolves(message *protobuf.ProtoMessage, declared, enclosing map[string]bool) bool {
+	own := map[string]bool{message.Name: true}
+	var declare func(m *protobuf.ProtoMessage)
+	declare = func(m *protobuf.ProtoMessage) {
+		for _, nestedMessage := range m.Messages {
+			own[nestedMessage.Name] = true
+			declare(nestedMessage)
+		}
+		for _, nestedEnum := range m.Enums {
+			own[nestedEnum.Name] = true
+		}
+	}
+	declare(message)
+
+	for _, name := range protoMessageDeps(message, nil) {
+		if protoBuiltinTypes[name] || strings.Contains(name, ".") || own[name] {
+			continue
+		}
+		if !declared[name] || enclosing[name] {
+			return false
+		}
+	}
+	return true
+}
+
+// thriftStructKey returns the structural key of the fields of a struct, leaving out its name and
+// descriptions. Fields are taken in name order when sorted is set, as the output orders them so.
+func thriftStructKey(message *thrift.ThriftStruct, sorted bool) string {
+	return thriftFieldsKey(message.Fields, message.Options, message.Reserved, sorted)
+}
+
+// thriftUnionKey returns the structural key of the fields of a union, like thriftStructKey
+func thriftUnionKey(union *thrift.ThriftUnion, sorted bool) string {
+	return thriftFieldsKey(union.Fields, union.Options, union.Reserved, sorted)
+}
+
+func thriftFieldsKey(fields []*thrift.ThriftField, options []*thrift.Option, reserved []int, sorted bool) string {
+	keys := make([]string, 0, len(fields))
+	for _, field := range fields {
+		keys = append(keys, fmt.Sprintf("%d %s %s %t %t %t %s", field.ID, field.Name, field.Type, field.Required, field.Optional, field.Repeated, thriftOptionsKey(field.Options)))
+	}
+	if sorted {
+		sort.Strings(keys)
+	}
+	return "{" + strings.Join(keys, ";") + "}" + thriftOptionsKey(options) + fmt.Sprint(reserved)
+}
+
+// thriftEnumKey returns the structural key of the values and options of an enum
+func thriftEnumKey(enum *thrift.ThriftEnum) string {
+	var sb strings.Builder
+	sb.WriteString("{")
+	for _, value := range enum.Values {
+		sb.WriteString(fmt.Sprintf("%d=%v;", value.Index, value.Value))
+	}
+	sb.WriteString("}")
+	sb.WriteString(thriftOptionsKey(enum.Options))
+	return sb.String()
+}
+
+func thriftOptionsKey(options []*thrift.Option) string {
+	var sb strings.Builder
+	for _, option := range options {
+		sb.WriteString(fmt.Sprintf("[%s=%v]", option.Name, option.Value))
+	}
+	return sb.String()
+}
+
+// retargetThriftFields rewrites the field types of a list of fields
+func retargetThriftFields(fields []*thrift.ThriftField, renames map[string]string) {
+	replace := func(name string) string {
+		if renamed, ok := renames[name]; ok {
+			return renamed
+		}
+		return name
+	}
+	for _, field := range fields {
+		field.Type = utils.ReplaceTypeNames(field.Type, replace)
+	}
+}
+
+// dedupeInlineTypes replaces the structs, unions and enums of inline schemas that are identical to
+// an earlier declaration of the same kind of an inline schema with that declaration
+func (c *ThriftConverter) dedupeInlineTypes() {
+	sorted := c.converterOption.OrderOption != OrderSpec
+	for {
+		canonical := make(map[string]string)
+		renames := make(map[string]string)
+		merge := func(name, key string) {
+			if !c.inlineTypes[name] {
+				return
+			}
+			// Declarations of different documents are not shared, the documents would include each other
+			key = c.declSources[name] + "#" + key
+			if first, ok := canonical[key]; ok {
+				renames[name] = first
+			} else {
+				canonical[key] = name
+			}
+		}
+		for _, message := range c.ThriftFile.Structs {
+			merge(message.Name, "struct"+thriftStructKey(message, sorted))
+		}
+		for _, union := range c.ThriftFile.Unions {
+			merge(union.Name, "union"+thriftUnionKey(union, sorted))
+		}
+		for _, enum := range c.ThriftFile.Enums {
+			merge(enum.Name, "enum"+thriftEnumKey(enum))
+		}
+		if len(renames) == 0 {
+			return
+		}
+
+		kept := func(name string) bool {
+			if _, ok := renames[name]; !ok {
+				return true
+			}
+			delete(c.inlineTypes, name)
+			delete(c.declSources, name)
+			return false
+		}
+		structs := c.ThriftFile.Structs[:0]
+		for _, message := range c.ThriftFile.Structs {
+			if kept(message.Name) {
+				retargetThriftFields(message.Fields, renames)
+				structs = append(structs, message)
+			}
+		}
+		c.ThriftFile.Structs = structs
+		unions := c.ThriftFile.Unions[:0]
+		for _, union := range c.ThriftFile.Unions {
+			if kept(union.Name) {
+				retargetThriftFields(union.Fields, renames)
+				unions = append(unions, union)
+			}
+		}
+		c.ThriftFile.Unions = unions
+		enums := c.ThriftFile.Enums[:0]
+		for _, enum := range c.ThriftFile.Enums {
+			if kept(enum.Name) {
+				enums = append(enums, enum)
+			}
+		}
+		c.ThriftFile.Enums = enums
+		for _, typedef := range c.ThriftFile.Typedefs {
+			typedef.Type = utils.ReplaceTypeNames(typedef.Type, func(name string) string {
+				if renamed, ok := renames[name]; ok {
+					return renamed
+				}
+				return name
+			})
+		}
+	}
+}
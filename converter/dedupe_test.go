@@ -0,0 +1,362 @@
+package converter
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/hertz-contrib/swagger-generate/swagger2idl/protobuf"
+	"github.com/hertz-contrib/swagger-generate/swagger2idl/thrift"
+)
+
+// emptySpec declares nothing, for tests that build the IR themselves
+const emptySpec = `openapi: 3.0.3
+info: {title: test, version: "1"}
+paths: {}
+`
+
+func TestUniqueName(t *testing.T) {
+	tests := []struct {
+		name     string
+		declared map[string]bool
+		want     string
+	}{
+		{name: "Pet", declared: map[string]bool{}, want: "Pet"},
+		{name: "Pet", declared: map[string]bool{"Pet": true}, want: "Pet2"},
+		{name: "Pet", declared: map[string]bool{"Pet": true, "Pet2": true, "Pet3": true}, want: "Pet4"},
+		{name: "Pet", declared: map[string]bool{"Pet2": true}, want: "Pet"},
+	}
+	for _, tt := range tests {
+		if got := uniqueName(tt.name, tt.declared); got != tt.want {
+			t.Errorf("uniqueName(%q, %v) = %q, want %q", tt.name, tt.declared, got, tt.want)
+		}
+	}
+}
+
+func TestProtoMessageKey(t *testing.T) {
+	message := func(name, description string, fields ...string) *protobuf.ProtoMessage {
+		m := &protobuf.ProtoMessage{Name: name, Description: description}
+		for _, field := range fields {
+			m.Fields = append(m.Fields, &protobuf.ProtoField{Name: field, Type: "string"})
+		}
+		return m
+	}
+	tests := []struct {
+		name   string
+		a, b   *protobuf.ProtoMessage
+		sorted bool
+		same   bool
+	}{
+		{name: "names and descriptions are ignored", a: message("A", "a", "x"), b: message("B", "b", "x"), same: true},
+		{name: "different fields", a: message("A", "", "x"), b: message("A", "", "y"), same: false},
+		{name: "field order when sorted", a: message("A", "", "x", "y"), b: message("A", "", "y", "x"), sorted: true, same: true},
+		{name: "field order in spec order", a: message("A", "", "x", "y"), b: message("A", "", "y", "x"), same: false},
+		{
+			name: "nested enums",
+			a:    &protobuf.ProtoMessage{Enums: []*protobuf.ProtoEnum{{Name: "Kind", Values: []*protobuf.ProtoEnumValue{{Index: 0, Value: "DOG"}}}}},
+			b:    &protobuf.ProtoMessage{Enums: []*protobuf.ProtoEnum{{Name: "Kind", Values: []*protobuf.ProtoEnumValue{{Index: 0, Value: "CAT"}}}}},
+			same: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if same := protoMessageKey(tt.a, tt.sorted) == protoMessageKey(tt.b, tt.sorted); same != tt.same {
+				t.Errorf("keys equal = %v, want %v", same, tt.same)
+			}
+		})
+	}
+}
+
+// protoSummary describes the top-level declarations of a ProtoFile as name, field types and
+// nested declarations
+func protoSummary(file *protobuf.ProtoFile) []string {
+	var summary []string
+	for _, enum := range file.Enums {
+		summary = append(summary, "enum "+enum.Name)
+	}
+	for _, message := range file.Messages {
+		var parts []string
+		for _, field := range message.Fields {
+			parts = append(parts, field.Name+":"+field.Type)
+		}
+		for _, nestedMessage := range message.Messages {
+			parts = append(parts, "message "+nestedMessage.Name)
+		}
+		for _, nestedEnum := range message.Enums {
+			parts = append(parts, "enum "+nestedEnum.Name)
+		}
+		summary = append(summary, message.Name+"{"+strings.Join(parts, ",")+"}")
+	}
+	sort.Strings(summary)
+	return summary
+}
+
+func TestDedupeNestedTypes(t *testing.T) {
+	owner := func() *protobuf.ProtoMessage {
+		return &protobuf.ProtoMessage{Name: "Owner", Fields: []*protobuf.ProtoField{{Name: "Name", Type: "string"}}}
+	}
+	kind := func(value string) *protobuf.ProtoEnum {
+		return &protobuf.ProtoEnum{Name: "Kind", Values: []*protobuf.ProtoEnumValue{{Index: 0, Value: value}}}
+	}
+	tests := []struct {
+		name     string
+		messages func() []*protobuf.ProtoMessage
+		sources  map[string]string
+		want     []string
+	}{
+		{
+			name: "identical nested messages are hoisted",
+			messages: func() []*protobuf.ProtoMessage {
+				return []*protobuf.ProtoMessage{
+					{Name: "Pet", Fields: []*protobuf.ProtoField{{Name: "Owner", Type: "Owner"}}, Messages: []*protobuf.ProtoMessage{owner()}},
+					{Name: "Car", Fields: []*protobuf.ProtoField{{Name: "Owner", Type: "Owner"}}, Messages: []*protobuf.ProtoMessage{owner()}},
+				}
+			},
+			want: []string{"Car{Owner:Owner}", "Owner{Name:string}", "Pet{Owner:Owner}"},
+		},
+		{
+			name: "hoisted declarations avoid top-level names",
+			messages: func() []*protobuf.ProtoMessage {
+				return []*protobuf.ProtoMessage{
+					{Name: "Owner", Fields: []*protobuf.ProtoField{{Name: "Id", Type: "int64"}}},
+					{Name: "Pet", Fields: []*protobuf.ProtoField{{Name: "Owner", Type: "Owner"}}, Messages: []*protobuf.ProtoMessage{owner()}},
+					{Name: "Car", Fields: []*protobuf.ProtoField{{Name: "Owner", Type: "Owner"}}, Messages: []*protobuf.ProtoMessage{owner()}},
+				}
+			},
+			want: []string{"Car{Owner:Owner2}", "Owner2{Name:string}", "Owner{Id:int64}", "Pet{Owner:Owner2}"},
+		},
+		{
+			name: "identical nested enums are hoisted",
+			messages: func() []*protobuf.ProtoMessage {
+				return []*protobuf.ProtoMessage{
+					{Name: "Pet", Fields: []*protobuf.ProtoField{{Name: "Kind", Type: "Kind"}}, Enums: []*protobuf.ProtoEnum{kind("DOG")}},
+					{Name: "Toy", Fields: []*protobuf.ProtoField{{Name: "Kind", Type: "Kind"}}, Enums: []*protobuf.ProtoEnum{kind("DOG")}},
+				}
+			},
+			want: []string{"Pet{Kind:Kind}", "Toy{Kind:Kind}", "enum Kind"},
+		},
+		{
+			name: "different nested declarations stay nested",
+			messages: func() []*protobuf.ProtoMessage {
+				return []*protobuf.ProtoMessage{
+					{Name: "Pet", Fields: []*protobuf.ProtoField{{Name: "Kind", Type: "Kind"}}, Enums: []*protobuf.ProtoEnum{kind("DOG")}},
+					{Name: "Toy", Fields: []*protobuf.ProtoField{{Name: "Kind", Type: "Kind"}}, Enums: []*protobuf.ProtoEnum{kind("BALL")}},
+				}
+			},
+			want: []string{"Pet{Kind:Kind,enum Kind}", "Toy{Kind:Kind,enum Kind}"},
+		},
+		{
+			name: "nested messages using the enclosing scope stay nested",
+			messages: func() []*protobuf.ProtoMessage {
+				inner := func() *protobuf.ProtoMessage {
+					return &protobuf.ProtoMessage{Name: "Owner", Fields: []*protobuf.ProtoField{{Name: "Kind", Type: "Kind"}}}
+				}
+				return []*protobuf.ProtoMessage{
+					{Name: "Pet", Messages: []*protobuf.ProtoMessage{inner()}, Enums: []*protobuf.ProtoEnum{kind("DOG")}},
+					{Name: "Toy", Messages: []*protobuf.ProtoMessage{inner()}, Enums: []*protobuf.ProtoEnum{kind("BALL")}},
+				}
+			},
+			want: []string{"Pet{message Owner,enum Kind}", "Toy{message Owner,enum Kind}"},
+		},
+		{
+			name: "declarations of different documents are not shared",
+			messages: func() []*protobuf.ProtoMessage {
+				return []*protobuf.ProtoMessage{
+					{Name: "Pet", Messages: []*protobuf.ProtoMessage{owner()}},
+					{Name: "Car", Messages: []*protobuf.ProtoMessage{owner()}},
+				}
+			},
+			sources: map[string]string{"Car": "models/car.yaml"},
+			want:    []string{"Car{message Owner}", "Pet{message Owner}"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewProtoConverter(loadSpec(t, emptySpec), &ConvertOption{})
+			c.ProtoFile.Messages = tt.messages()
+			for name, source := range tt.sources {
+				c.declSources[name] = source
+			}
+			c.dedupeNestedTypes()
+			if got := protoSummary(c.ProtoFile); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("dedupeNestedTypes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// thriftSummary describes the declarations of a ThriftFile as name and field types
+func thriftSummary(file *thrift.ThriftFile) []string {
+	var summary []string
+	describe := func(kind, name string, fields []*thrift.ThriftField) {
+		var parts []string
+		for _, field := range fields {
+			parts = append(parts, field.Name+":"+field.Type)
+		}
+		summary = append(summary, kind+" "+name+"{"+strings.Join(parts, ",")+"}")
+	}
+	for _, typedef := range file.Typedefs {
+		summary = append(summary, "typedef "+typedef.Name+"="+typedef.Type)
+	}
+	for _, message := range file.Structs {
+		describe("struct", message.Name, message.Fields)
+	}
+	for _, union := range file.Unions {
+		describe("union", union.Name, union.Fields)
+	}
+	for _, enum := range file.Enums {
+		summary = append(summary, "enum "+enum.Name)
+	}
+	sort.Strings(summary)
+	return summary
+}
+
+func TestDedupeInlineTypes(t *testing.T) {
+	field := func(name, typ string) *thrift.ThriftField {
+		return &thrift.ThriftField{Name: name, Type: typ}
+	}
+	kind := func(name, value string) *thrift.ThriftEnum {
+		return &thrift.ThriftEnum{Name: name, Values: []*thrift.ThriftEnumValue{{Index: 1, Value: value}}}
+	}
+	tests := []struct {
+		name    string
+		file    func() *thrift.ThriftFile
+		inline  []string
+		sources map[string]string
+		want    []string
+	}{
+		{
+			name: "identical inline structs are merged",
+			file: func() *thrift.ThriftFile {
+				return &thrift.ThriftFile{
+					Typedefs: []*thrift.ThriftTypedef{{Name: "Owners", Type: "map<string, CarOwner>"}},
+					Structs: []*thrift.ThriftStruct{
+						{Name: "Pet", Fields: []*thrift.ThriftField{field("Owner", "PetOwner")}},
+						{Name: "PetOwner", Fields: []*thrift.ThriftField{field("Name", "string")}},
+						{Name: "Car", Fields: []*thrift.ThriftField{field("Owners", "list<CarOwner>")}},
+						{Name: "CarOwner", Fields: []*thrift.ThriftField{field("Name", "string")}},
+					},
+				}
+			},
+			inline: []string{"PetOwner", "CarOwner"},
+			want: []string{
+				"struct Car{Owners:list<PetOwner>}",
+				"struct PetOwner{Name:string}",
+				"struct Pet{Owner:PetOwner}",
+				"typedef Owners=map<string, PetOwner>",
+			},
+		},
+		{
+			name: "identical inline unions and enums are merged",
+			file: func() *thrift.ThriftFile {
+				return &thrift.ThriftFile{
+					Structs: []*thrift.ThriftStruct{
+						{Name: "Pet", Fields: []*thrift.ThriftField{field("Value", "PetValue"), field("Kind", "PetKind")}},
+						{Name: "Toy", Fields: []*thrift.ThriftField{field("Value", "ToyValue"), field("Kind", "ToyKind")}},
+					},
+					Unions: []*thrift.ThriftUnion{
+						{Name: "PetValue", Fields: []*thrift.ThriftField{field("Text", "string")}},
+						{Name: "ToyValue", Fields: []*thrift.ThriftField{field("Text", "string")}},
+					},
+					Enums: []*thrift.ThriftEnum{kind("PetKind", "SMALL"), kind("ToyKind", "SMALL")},
+				}
+			},
+			inline: []string{"PetValue", "ToyValue", "PetKind", "ToyKind"},
+			want: []string{
+				"enum PetKind",
+				"struct Pet{Value:PetValue,Kind:PetKind}",
+				"struct Toy{Value:PetValue,Kind:PetKind}",
+				"union PetValue{Text:string}",
+			},
+		},
+		{
+			name: "component declarations are kept",
+			file: func() *thrift.ThriftFile {
+				return &thrift.ThriftFile{
+					Structs: []*thrift.ThriftStruct{
+						{Name: "Owner", Fields: []*thrift.ThriftField{field("Name", "string")}},
+						{Name: "Person", Fields: []*thrift.ThriftField{field("Name", "string")}},
+					},
+				}
+			},
+			want: []string{"struct Owner{Name:string}", "struct Person{Name:string}"},
+		},
+		{
+			name: "declarations of different documents are not shared",
+			file: func() *thrift.ThriftFile {
+				return &thrift.ThriftFile{
+					Enums: []*thrift.ThriftEnum{kind("PetKind", "SMALL"), kind("CarKind", "SMALL")},
+				}
+			},
+			inline:  []string{"PetKind", "CarKind"},
+			sources: map[string]string{"CarKind": "models/car.yaml"},
+			want:    []string{"enum CarKind", "enum PetKind"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewThriftConverter(loadSpec(t, emptySpec), &ConvertOption{})
+			c.ThriftFile = tt.file()
+			for _, name := range tt.inline {
+				c.inlineTypes[name] = true
+			}
+			for name, source := range tt.sources {
+				c.declSources[name] = source
+			}
+			c.dedupeInlineTypes()
+			if got := thriftSummary(c.ThriftFile); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("dedupeInlineTypes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestThriftConvertInlineDeclarations(t *testing.T) {
+	spec := loadSpec(t, `openapi: 3.0.3
+info: {title: test, version: "1"}
+paths:
+  /a:
+    get:
+      operationId: getA
+      responses:
+        "200":
+          description: ok
+          content:
+            application/json:
+              schema:
+                type: object
+                properties:
+                  status: {type: string, enum: [on, off]}
+                  v: {oneOf: [{type: string}, {type: integer}]}
+  /b:
+    get:
+      operationId: getB
+      responses:
+        "200":
+          description: ok
+          content:
+            application/json:
+              schema:
+                type: object
+                properties:
+                  status: {type: string, enum: [red, green]}
+                  v: {oneOf: [{type: string}, {type: integer}]}
+`)
+	c := NewThriftConverter(spec, &ConvertOption{NamingOption: true})
+	if err := c.Convert(); err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+
+	// The colliding enums are renamed, the identical unions are shared
+	want := []string{
+		"enum StatusEnum",
+		"enum StatusEnum2",
+		"struct GetAresponse{Status:StatusEnum,V:vOneOf}",
+		"struct GetBresponse{Status:StatusEnum2,V:vOneOf}",
+		"union vOneOf{VOption1:string,VOption2:i64}",
+	}
+	if got := thriftSummary(c.ThriftFile); !reflect.DeepEqual(got, want) {
+		t.Errorf("Convert() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,64 @@
+package converter
+
+import (
+	"fmt"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/hertz-contrib/swagger-generate/swagger2idl/parser"
+)
+
+// Severity classifies a diagnostic
+type Severity string
+
+const (
+	// SeverityWarning reports a construct that was converted with a loss of information
+	SeverityWarning Severity = "warning"
+	// SeverityError reports a construct that was dropped from the output
+	SeverityError Severity = "error"
+)
+
+// Diagnostic reports a lossy decision of a converter at the spec node it was made for
+type Diagnostic struct {
+	Severity Severity
+	Pointer  string // JSON pointer of the spec node, e.g. #/components/schemas/Pet/properties/tags
+	Message  string
+}
+
+// String formats the diagnostic as "severity: pointer: message"
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s: %s: %s", d.Severity, d.Pointer, d.Message)
+}
+
+// diagnostics collects the diagnostics of a conversion, reporting each of them once
+type diagnostics struct {
+	pointers *parser.SpecPointers
+	list     []Diagnostic
+	seen     map[Diagnostic]bool
+}
+
+// newDiagnostics creates a collector locating the nodes of spec
+func newDiagnostics(spec *openapi3.T) *diagnostics {
+	return &diagnostics{
+		pointers: parser.ResolveSpecPointers(spec),
+		seen:     map[Diagnostic]bool{},
+	}
+}
+
+// warn records a lossy conversion of node
+func (d *diagnostics) warn(node interface{}, format string, args ...interface{}) {
+	d.add(SeverityWarning, d.pointers.Lookup(node), fmt.Sprintf(format, args...))
+}
+
+// drop records that node was left out of the output
+func (d *diagnostics) drop(node interface{}, format string, args ...interface{}) {
+	d.add(SeverityError, d.pointers.Lookup(node), fmt.Sprintf(format, args...))
+}
+
+func (d *diagnostics) add(severity Severity, pointer, message string) {
+	diagnostic := Diagnostic{Severity: severity, Pointer: pointer, Message: message}
+	if d.seen[diagnostic] {
+		return
+	}
+	d.seen[diagnostic] = true
+	d.list = append(d.list, diagnostic)
+}
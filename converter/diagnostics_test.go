@@ -0,0 +1,161 @@
+package converter
+
+import (
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+// diagnosticsSpec declares the constructs the converters report. Constructs the validation of a
+// loaded spec rejects are set up by the tests.
+const diagnosticsSpec = `openapi: 3.0.3
+info: {title: test, version: "1"}
+paths:
+  /items:
+    post:
+      parameters:
+        - name: id
+          in: query
+          content:
+            application/json:
+              schema: {type: string}
+      requestBody:
+        content:
+          application/json:
+            schema:
+              type: object
+              properties:
+                choice:
+                  oneOf: [{type: string}, {type: integer}]
+          application/xml:
+            schema:
+              type: object
+              properties:
+                choice:
+                  oneOf: [{type: boolean}, {type: number}]
+      responses:
+        "200": {description: ok}
+components:
+  schemas:
+    Item:
+      type: object
+      properties:
+        tags:
+          type: array
+          items: {type: string}
+        blob: {type: string}
+        choices:
+          type: array
+          items:
+            oneOf: [{type: string}, {type: integer}]
+        free:
+          type: object
+          additionalProperties: true
+`
+
+func TestConverterDiagnostics(t *testing.T) {
+	tests := []struct {
+		name         string
+		target       string
+		setup        func(spec *openapi3.T)
+		wantSeverity Severity
+		wantPointer  string
+	}{
+		{
+			name:   "array without items",
+			target: "proto",
+			setup: func(spec *openapi3.T) {
+				spec.Components.Schemas["Item"].Value.Properties["tags"].Value.Items = nil
+			},
+			wantSeverity: SeverityWarning,
+			wantPointer:  "#/components/schemas/Item/properties/tags",
+		},
+		{
+			name:   "array without items",
+			target: "thrift",
+			setup: func(spec *openapi3.T) {
+				spec.Components.Schemas["Item"].Value.Properties["tags"].Value.Items = nil
+			},
+			wantSeverity: SeverityWarning,
+			wantPointer:  "#/components/schemas/Item/properties/tags",
+		},
+		{
+			name:   "unknown type",
+			target: "proto",
+			setup: func(spec *openapi3.T) {
+				spec.Components.Schemas["Item"].Value.Properties["blob"].Value.Type = &openapi3.Types{"file"}
+			},
+			wantSeverity: SeverityWarning,
+			wantPointer:  "#/components/schemas/Item/properties/blob",
+		},
+		{
+			name:   "unknown type",
+			target: "thrift",
+			setup: func(spec *openapi3.T) {
+				spec.Components.Schemas["Item"].Value.Properties["blob"].Value.Type = &openapi3.Types{"file"}
+			},
+			wantSeverity: SeverityWarning,
+			wantPointer:  "#/components/schemas/Item/properties/blob",
+		},
+		{
+			name:         "clashing oneOf of another media type",
+			target:       "proto",
+			wantSeverity: SeverityError,
+			wantPointer:  "#/paths/~1items/post/requestBody/content/application~1xml/schema",
+		},
+		{
+			name:         "oneOf array items",
+			target:       "proto",
+			wantSeverity: SeverityWarning,
+			wantPointer:  "#/components/schemas/Item/properties/choices/items",
+		},
+		{
+			name:         "additionalProperties without a schema",
+			target:       "thrift",
+			wantSeverity: SeverityError,
+			wantPointer:  "#/components/schemas/Item/properties/free",
+		},
+		{
+			name:         "parameter without a schema",
+			target:       "proto",
+			wantSeverity: SeverityError,
+			wantPointer:  "#/paths/~1items/post/parameters/0",
+		},
+		{
+			name:         "parameter without a schema",
+			target:       "thrift",
+			wantSeverity: SeverityError,
+			wantPointer:  "#/paths/~1items/post/parameters/0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name+" "+tt.target, func(t *testing.T) {
+			spec := loadSpec(t, diagnosticsSpec)
+			if tt.setup != nil {
+				tt.setup(spec)
+			}
+
+			var diagnostics []Diagnostic
+			if tt.target == "proto" {
+				c := NewProtoConverter(spec, &ConvertOption{})
+				if err := c.Convert(); err != nil {
+					t.Fatalf("Convert() error = %v", err)
+				}
+				diagnostics = c.Diagnostics()
+			} else {
+				c := NewThriftConverter(spec, &ConvertOption{})
+				if err := c.Convert(); err != nil {
+					t.Fatalf("Convert() error = %v", err)
+				}
+				diagnostics = c.Diagnostics()
+			}
+
+			for _, d := range diagnostics {
+				if d.Pointer == tt.wantPointer && d.Severity == tt.wantSeverity {
+					return
+				}
+			}
+			t.Errorf("Diagnostics() = %v, want a %s at %s", diagnostics, tt.wantSeverity, tt.wantPointer)
+		})
+	}
+}
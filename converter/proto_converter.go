@@ -3,8 +3,12 @@ package converter
 import (
 	"errors"
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/hertz-contrib/swagger-generate/swagger2idl/parser"
 	"github.com/hertz-contrib/swagger-generate/swagger2idl/protobuf"
 	"github.com/hertz-contrib/swagger-generate/swagger2idl/utils"
 )
@@ -16,6 +20,9 @@ const (
 
 	EmptyMessage = "google.protobuf.Empty"
 
+	wrappersProtoFile  = "google/protobuf/wrappers.proto"
+	timestampProtoFile = "google/protobuf/timestamp.proto"
+
 	openapiDocumentOption  = "openapi.document"
 	openapiOperationOption = "openapi.operation"
 	openapiPropertyOption  = "openapi.property"
@@ -23,11 +30,46 @@ const (
 	openapiSchemaOption    = "openapi.schema"
 )
 
+// protoScalarWrappers maps proto scalar types to the google.protobuf wrapper message holding their
+// values
+var protoScalarWrappers = map[string]string{
+	"double":   "google.protobuf.DoubleValue",
+	"float":    "google.protobuf.FloatValue",
+	"int64":    "google.protobuf.Int64Value",
+	"sint64":   "google.protobuf.Int64Value",
+	"sfixed64": "google.protobuf.Int64Value",
+	"uint64":   "google.protobuf.UInt64Value",
+	"fixed64":  "google.protobuf.UInt64Value",
+	"int32":    "google.protobuf.Int32Value",
+	"sint32":   "google.protobuf.Int32Value",
+	"sfixed32": "google.protobuf.Int32Value",
+	"uint32":   "google.protobuf.UInt32Value",
+	"fixed32":  "google.protobuf.UInt32Value",
+	"bool":     "google.protobuf.BoolValue",
+	"string":   "google.protobuf.StringValue",
+	"bytes":    "google.protobuf.BytesValue",
+}
+
 // ProtoConverter struct, used to convert OpenAPI specifications into Proto files
 type ProtoConverter struct {
 	spec            *openapi3.T
 	ProtoFile       *protobuf.ProtoFile
 	converterOption *ConvertOption
+	specOrder       *parser.SpecOrder      // Declaration order of the spec, may be nil
+	sharedTypes     map[string]bool        // Top-level declarations produced by the component schemas
+	schemaSources   *parser.SchemaSources  // Declaring documents of external references, may be nil
+	externalTypes   map[string]string      // Declarations of the schemas of external references, by document and pointer
+	declSources     map[string]string      // Declaring document of each top-level declaration of another file
+	currentSource   string                 // Document of the external schema being converted
+	diagnostics     *diagnostics           // Lossy decisions of the conversion
+	refTypes        map[interface{}]string // Declarations of the referenced request bodies, responses and parameters
+	fragments       map[interface{}]string // Fragment declarations of the referenced parameters and headers
+	converting      map[interface{}]string // Declarations of the schemas being converted, which recursive schemas refer to
+	typeMappings    []parser.TypeMapping   // User type mappings followed by the default ones
+	formFile        string                 // Declared form file message, empty until a file part is met
+
+	// paramGroups maps each referenced parameter onto the parameters sharing its fragment, computed on first use
+	paramGroups map[*openapi3.Parameter][]*openapi3.ParameterRef
 }
 
 // NewProtoConverter creates and initializes a ProtoConverter
@@ -43,9 +85,31 @@ func NewProtoConverter(spec *openapi3.T, option *ConvertOption) *ProtoConverter
 			Options:     []*protobuf.Option{},
 		},
 		converterOption: option,
+		externalTypes:   map[string]string{},
+		declSources:     map[string]string{},
+		diagnostics:     newDiagnostics(spec),
+		refTypes:        map[interface{}]string{},
+		fragments:       map[interface{}]string{},
+		converting:      map[interface{}]string{},
+		typeMappings:    typeMappings(option),
 	}
 }
 
+// SetSchemaSources provides the declaring documents of the external references of a multi-document spec
+func (c *ProtoConverter) SetSchemaSources(sources *parser.SchemaSources) {
+	c.schemaSources = sources
+}
+
+// SetSpecOrder provides the declaration order used when the spec order option is selected
+func (c *ProtoConverter) SetSpecOrder(order *parser.SpecOrder) {
+	c.specOrder = order
+}
+
+// Diagnostics returns the lossy decisions made while converting the spec
+func (c *ProtoConverter) Diagnostics() []Diagnostic {
+	return c.diagnostics.list
+}
+
 // Convert converts the OpenAPI specification to a Proto file
 func (c *ProtoConverter) Convert() error {
 	// Convert the go Option to Proto
@@ -65,6 +129,7 @@ func (c *ProtoConverter) Convert() error {
 	if err != nil {
 		return fmt.Errorf("error converting components to proto messages: %w", err)
 	}
+	c.sharedTypes = c.declaredTypes()
 
 	// Convert paths into Proto services
 	err = c.convertPathsToProtoServices()
@@ -79,6 +144,12 @@ func (c *ProtoConverter) Convert() error {
 		}
 	}
 
+	c.dedupeNestedTypes()
+
+	if c.converterOption.OrderOption != OrderSpec {
+		c.sortDeclarations()
+	}
+
 	return nil
 }
 
@@ -104,10 +175,10 @@ func (c *ProtoConverter) addExtensionsToProtoOptions() error {
 	// Check for x-option in spec extensions
 	if xOption, ok := c.spec.Extensions["x-options"]; ok {
 		if optionMap, ok := xOption.(map[string]interface{}); ok {
-			for key, value := range optionMap {
+			for _, key := range utils.SortedKeys(optionMap) {
 				option := &protobuf.Option{
 					Name:  key,
-					Value: fmt.Sprintf("%q", value),
+					Value: fmt.Sprintf("%q", optionMap[key]),
 				}
 				c.ProtoFile.Options = append(c.ProtoFile.Options, option)
 			}
@@ -118,10 +189,10 @@ func (c *ProtoConverter) addExtensionsToProtoOptions() error {
 	if c.spec.Info != nil {
 		if xOption, ok := c.spec.Info.Extensions["x-options"]; ok {
 			if optionMap, ok := xOption.(map[string]interface{}); ok {
-				for key, value := range optionMap {
+				for _, key := range utils.SortedKeys(optionMap) {
 					option := &protobuf.Option{
 						Name:  key,
-						Value: fmt.Sprintf("%q", value),
+						Value: fmt.Sprintf("%q", optionMap[key]),
 					}
 					c.ProtoFile.Options = append(c.ProtoFile.Options, option)
 				}
@@ -158,64 +229,136 @@ func (c *ProtoConverter) convertComponentsToProtoMessages() error {
 	if components.Schemas == nil {
 		return nil
 	}
-	for name, schemaRef := range components.Schemas {
-		schema := schemaRef
+	for _, name := range orderedKeys(components.Schemas, c.specOrder.SchemaOrder(), c.converterOption) {
+		schema := components.Schemas[name]
 		if c.converterOption.NamingOption {
 			name = utils.ToPascaleCase(name)
 		}
-		protoType, err := c.ConvertSchemaToProtoType(schema, name, nil)
-		if err != nil {
+		if err := c.addSchemaDeclaration(name, schema); err != nil {
 			return fmt.Errorf("error converting schema %s: %w", name, err)
 		}
-		switch v := protoType.(type) {
-		case *protobuf.ProtoField:
-			message := &protobuf.ProtoMessage{
-				Name:   name,
-				Fields: []*protobuf.ProtoField{v},
-			}
+	}
+	return nil
+}
 
-			if c.converterOption.OpenapiOption {
-				optionStr := utils.StructToOption(schema.Value, "    ")
+// addSchemaDeclaration converts a named schema into a top-level declaration of the ProtoFile
+func (c *ProtoConverter) addSchemaDeclaration(name string, schema *openapi3.SchemaRef) error {
+	var protoType interface{}
+	var err error
+	if schema.Ref == "" && schema.Value != nil && len(schema.Value.OneOf) > 0 {
+		protoType, err = c.convertOneOfMessage(schema.Value, name)
+	} else {
+		protoType, err = c.ConvertSchemaToProtoType(schema, name, nil)
+	}
+	if err != nil {
+		return err
+	}
+	switch v := protoType.(type) {
+	case *protobuf.ProtoField:
+		message := &protobuf.ProtoMessage{
+			Name:   name,
+			Fields: []*protobuf.ProtoField{v},
+		}
 
-				schemaOption := &protobuf.Option{
-					Name:  openapiSchemaOption,
-					Value: optionStr,
-				}
-				message.Options = append(message.Options, schemaOption)
-				c.AddProtoImport(openapiProtoFile)
-			}
-			c.addMessageToProto(message)
-		case *protobuf.ProtoMessage:
-			if c.converterOption.OpenapiOption {
-				optionStr := utils.StructToOption(schema.Value, "    ")
+		if c.converterOption.OpenapiOption {
+			optionStr := utils.StructToOption(schema.Value, "    ")
 
-				schemaOption := &protobuf.Option{
-					Name:  openapiSchemaOption,
-					Value: optionStr,
-				}
-				v.Options = append(v.Options, schemaOption)
-				c.AddProtoImport(openapiProtoFile)
+			schemaOption := &protobuf.Option{
+				Name:  openapiSchemaOption,
+				Value: optionStr,
 			}
-			c.addMessageToProto(v)
-		case *protobuf.ProtoEnum:
-			if c.converterOption.OpenapiOption {
-				optionStr := utils.StructToOption(schema.Value, "    ")
+			message.Options = append(message.Options, schemaOption)
+			c.AddProtoImport(openapiProtoFile)
+		}
+		c.addMessageToProto(message)
+	case *protobuf.ProtoMessage:
+		if c.converterOption.OpenapiOption {
+			optionStr := utils.StructToOption(schema.Value, "    ")
 
-				schemaOption := &protobuf.Option{
-					Name:  openapiSchemaOption,
-					Value: optionStr,
-				}
-				v.Options = append(v.Options, schemaOption)
-				c.AddProtoImport(openapiProtoFile)
+			schemaOption := &protobuf.Option{
+				Name:  openapiSchemaOption,
+				Value: optionStr,
 			}
-			c.addEnumToProto(v)
-		case *protobuf.ProtoOneOf:
-			// todo
+			v.Options = append(v.Options, schemaOption)
+			c.AddProtoImport(openapiProtoFile)
 		}
+		c.addMessageToProto(v)
+	case *protobuf.ProtoEnum:
+		if c.converterOption.OpenapiOption {
+			optionStr := utils.StructToOption(schema.Value, "    ")
+
+			schemaOption := &protobuf.Option{
+				Name:  openapiSchemaOption,
+				Value: optionStr,
+			}
+			v.Options = append(v.Options, schemaOption)
+			c.AddProtoImport(openapiProtoFile)
+		}
+		c.addEnumToProto(v)
 	}
 	return nil
 }
 
+// convertOneOfMessage converts a top-level oneOf schema into a message wrapping a oneof with a
+// field per branch, as proto3 has no union type
+func (c *ProtoConverter) convertOneOfMessage(schema *openapi3.Schema, name string) (*protobuf.ProtoMessage, error) {
+	message := &protobuf.ProtoMessage{
+		Name:        name,
+		Description: schema.Description,
+	}
+	c.converting[schema] = name
+	defer delete(c.converting, schema)
+	if _, err := c.handleOneOf(schema, name, message); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+// addExternalSchema declares the schema of a reference to another document, or to a part of a
+// document outside of its components, the first time the reference is met
+func (c *ProtoConverter) addExternalSchema(schemaRef *openapi3.SchemaRef) (string, error) {
+	name := c.applyNamingOption(utils.ExtractMessageNameFromRef(schemaRef.Ref))
+	source := c.schemaSources.Lookup(schemaRef)
+	if source == "" && isComponentRef(schemaRef.Ref) {
+		return name, nil
+	}
+	key := externalRefKey(source, schemaRef.Ref)
+	if declared, ok := c.externalTypes[key]; ok {
+		return declared, nil
+	}
+	if schemaRef.Value == nil {
+		return name, nil
+	}
+
+	// Schemas of the same name in different documents are told apart by the name of their document
+	taken := c.declaredTypes()
+	for _, declared := range c.externalTypes {
+		taken[declared] = true
+	}
+	if c.spec.Components != nil {
+		for componentName := range c.spec.Components.Schemas {
+			taken[c.applyNamingOption(componentName)] = true
+		}
+	}
+	if taken[name] {
+		renamed := uniqueName(documentQualifier(source)+utils.ToUpperCase(name), taken)
+		c.diagnostics.warn(schemaRef.Value, "schema %s is declared as %s, %s is already declared", schemaRef.Ref, renamed, name)
+		name = renamed
+	}
+	c.externalTypes[key] = name
+
+	previous := c.currentSource
+	if source != "" {
+		c.currentSource = source
+	}
+	defer func() { c.currentSource = previous }()
+
+	if err := c.addSchemaDeclaration(name, &openapi3.SchemaRef{Value: schemaRef.Value}); err != nil {
+		return "", fmt.Errorf("error converting schema %s: %w", schemaRef.Ref, err)
+	}
+	return name, nil
+}
+
 // convertPathsToProtoServices converts OpenAPI path items into Proto services and stores them in the ProtoFile
 func (c *ProtoConverter) convertPathsToProtoServices() error {
 	paths := c.spec.Paths
@@ -232,8 +375,11 @@ func (c *ProtoConverter) convertPathsToProtoServices() error {
 func (c *ProtoConverter) ConvertPathsToProtoServices(paths *openapi3.Paths) ([]*protobuf.ProtoService, error) {
 	var services []*protobuf.ProtoService
 
-	for path, pathItem := range paths.Map() {
-		for method, operation := range pathItem.Operations() {
+	for _, path := range orderedKeys(paths.Map(), c.specOrder.PathOrder(), c.converterOption) {
+		pathItem := paths.Value(path)
+		operations := pathItem.Operations()
+		for _, method := range orderedKeys(operations, c.specOrder.OperationOrder(path), c.converterOption) {
+			operation := withPathParameters(pathItem, operations[method])
 			serviceName := utils.GetServiceName(operation)
 			methodName := utils.GetMethodName(operation, path, method)
 
@@ -252,6 +398,16 @@ func (c *ProtoConverter) ConvertPathsToProtoServices(paths *openapi3.Paths) ([]*
 				return nil, fmt.Errorf("error generating response message for %s: %w", methodName, err)
 			}
 
+			// Bodies converting into nothing are empty messages
+			if inputMessage == "" {
+				c.AddProtoImport(EmptyProtoFile)
+				inputMessage = EmptyMessage
+			}
+			if outputMessage == "" {
+				c.AddProtoImport(EmptyProtoFile)
+				outputMessage = EmptyMessage
+			}
+
 			service := c.findOrCreateService(serviceName)
 
 			if !c.methodExistsInService(service, methodName) {
@@ -307,207 +463,463 @@ func (c *ProtoConverter) generateRequestMessage(operation *openapi3.Operation, m
 	}
 
 	if operation.RequestBody != nil {
+		// A referenced request body is the request of the operations without parameters and a
+		// field of the requests of the others
 		if operation.RequestBody.Ref != "" {
-			//todo
-			return utils.ExtractMessageNameFromRef(operation.RequestBody.Ref), nil
+			bodyName, err := c.convertRequestBodyRef(operation.RequestBody)
+			if err != nil {
+				return "", err
+			}
+			if len(operation.Parameters) == 0 {
+				return bodyName, nil
+			}
+			message.Fields = append(message.Fields, &protobuf.ProtoField{
+				Name: bodyName,
+				Type: bodyName,
+			})
+		} else if operation.RequestBody.Value != nil {
+			if err := c.addRequestBodyFields(message, operation.RequestBody.Value); err != nil {
+				return "", err
+			}
 		}
+	}
 
-		if operation.RequestBody.Value != nil && len(operation.RequestBody.Value.Content) > 0 {
-			for mediaTypeStr, mediaType := range operation.RequestBody.Value.Content {
-				schema := mediaType.Schema
-				if schema != nil {
-					protoType, err := c.ConvertSchemaToProtoType(schema, utils.FormatStr(mediaTypeStr), message)
-					if err != nil {
-						return "", err
-					}
+	for _, param := range operation.Parameters {
+		// A referenced parameter is either embedded field by field or referenced as a fragment
+		if param.Ref != "" && c.converterOption.FragmentOption == FragmentReference {
+			field, err := c.parameterFragmentField(param)
+			if err != nil {
+				return "", err
+			}
+			c.addFieldIfNotExists(&message.Fields, field)
+			continue
+		}
+		if err := c.addParameterFields(message, param); err != nil {
+			return "", err
+		}
+	}
 
-					switch v := protoType.(type) {
-					case *protobuf.ProtoField:
-						if c.converterOption.ApiOption {
-							var optionName string
-							if mediaTypeStr == "application/json" {
-								optionName = "api.body"
-							} else if mediaTypeStr == "application/x-www-form-urlencoded" || mediaTypeStr == "multipart/form-data" {
-								optionName = "api.form"
-							}
-							if optionName != "" {
-								v.Options = append(v.Options, &protobuf.Option{
-									Name:  optionName,
-									Value: fmt.Sprintf("%q", v.Name),
-								})
-								c.AddProtoImport(apiProtoFile)
-							}
-						}
-						c.addFieldIfNotExists(&message.Fields, v)
-					case *protobuf.ProtoMessage:
-						for _, field := range v.Fields {
-							if c.converterOption.ApiOption {
-								var optionName string
-								if mediaTypeStr == "application/json" {
-									optionName = "api.body"
-								} else if mediaTypeStr == "application/x-www-form-urlencoded" || mediaTypeStr == "multipart/form-data" {
-									optionName = "api.form"
-								}
-								if optionName != "" {
-									field.Options = append(field.Options, &protobuf.Option{
-										Name:  optionName,
-										Value: fmt.Sprintf("%q", field.Name),
-									})
-									c.AddProtoImport(apiProtoFile)
-								}
-							}
-							c.addFieldIfNotExists(&message.Fields, field)
-						}
-						for _, enum := range v.Enums {
-							message.Enums = append(message.Enums, enum)
-						}
-						for _, nestedMessage := range v.Messages {
-							c.addMessageIfNotExists(&message.Messages, nestedMessage)
-						}
-					case *protobuf.ProtoEnum:
-						name := mediaTypeStr
-						if c.converterOption.NamingOption {
-							name = utils.ToSnakeCase(name)
-						} else {
-							name = utils.FormatStr(name)
-						}
-						newField := &protobuf.ProtoField{
-							Name: name + "_field",
-							Type: v.Name,
-						}
-						if c.converterOption.ApiOption {
-							var optionName string
-							if mediaTypeStr == "application/json" {
-								optionName = "api.body"
-							} else if mediaTypeStr == "application/x-www-form-urlencoded" || mediaTypeStr == "multipart/form-data" {
-								optionName = "api.form"
-							}
-							if optionName != "" {
-								newField.Options = append(newField.Options, &protobuf.Option{
-									Name:  optionName,
-									Value: fmt.Sprintf("%q", v.Name),
-								})
-								c.AddProtoImport(apiProtoFile)
-							}
-						}
-						if c.converterOption.OpenapiOption {
-							optionStr := utils.StructToOption(schema.Value, "     ")
-
-							schemaOption := &protobuf.Option{
-								Name:  openapiPropertyOption,
-								Value: optionStr,
-							}
-							newField.Options = append(newField.Options, schemaOption)
-							c.AddProtoImport(openapiProtoFile)
-						}
-						message.Enums = append(message.Enums, v)
-						message.Fields = append(message.Fields, newField)
-					case *protobuf.ProtoOneOf:
-						message.OneOfs = append(message.OneOfs, v)
+	// if there are no fields or messages, return an empty message
+	if len(message.Fields) > 0 || len(message.OneOfs) > 0 || len(message.Messages) > 0 || len(message.Enums) > 0 {
+		c.addMessageToProto(message)
+		return message.Name, nil
+	}
+
+	return "", nil
+}
+
+// addParameterFields converts a parameter into fields of message
+func (c *ProtoConverter) addParameterFields(message *protobuf.ProtoMessage, param *openapi3.ParameterRef) error {
+	if param.Value.Schema != nil {
+		fieldOrMessage, err := c.ConvertSchemaToProtoType(param.Value.Schema, param.Value.Name, message)
+		if err != nil {
+			return err
+		}
+		description := param.Value.Description
+		switch v := fieldOrMessage.(type) {
+		case *protobuf.ProtoField:
+			if c.converterOption.ApiOption {
+				v.Options = append(v.Options, &protobuf.Option{
+					Name:  "api." + param.Value.In,
+					Value: fmt.Sprintf("%q", param.Value.Name),
+				})
+				c.AddProtoImport(apiProtoFile)
+			}
+			if c.converterOption.OpenapiOption {
+				optionStr := utils.StructToOption(param.Value, "     ")
+
+				schemaOption := &protobuf.Option{
+					Name:  openapiParameterOption,
+					Value: optionStr,
+				}
+				v.Options = append(v.Options, schemaOption)
+				c.AddProtoImport(openapiProtoFile)
+			}
+			v.Description = description
+			c.applyPresence(v, param.Value.Schema, param.Value.Required, isNullable(param.Value.Schema))
+			c.addFieldIfNotExists(&message.Fields, v)
+		case *protobuf.ProtoMessage:
+			for _, field := range v.Fields {
+				if c.converterOption.ApiOption {
+					field.Options = append(field.Options, &protobuf.Option{
+						Name:  "api." + param.Value.In,
+						Value: fmt.Sprintf("%q", param.Value.Name),
+					})
+					c.AddProtoImport(apiProtoFile)
+				}
+				if c.converterOption.OpenapiOption {
+					optionStr := utils.StructToOption(param.Value, "     ")
+
+					schemaOption := &protobuf.Option{
+						Name:  openapiParameterOption,
+						Value: optionStr,
 					}
+					field.Options = append(field.Options, schemaOption)
+					c.AddProtoImport(openapiProtoFile)
+				}
+				c.addFieldIfNotExists(&message.Fields, field)
+			}
+			c.mergeDeclarations(message, v, param.Value, "parameter "+param.Value.Name)
+		case *protobuf.ProtoEnum:
+			// The enum of a referenced parameter is declared once at the top level
+			shared := param.Ref != ""
+			enumName := v.Name
+			if shared {
+				enumName = c.addParameterEnum(param, v)
+			}
+			name := param.Value.Name
+			if c.converterOption.NamingOption {
+				name = utils.ToPascaleCase(name)
+			}
+			newField := &protobuf.ProtoField{
+				Name: name + "_field",
+				Type: enumName,
+			}
+			if c.converterOption.ApiOption {
+				newField.Options = append(newField.Options, &protobuf.Option{
+					Name:  "api." + param.Value.In,
+					Value: fmt.Sprintf("%q", param.Value.Name),
+				})
+				c.AddProtoImport(apiProtoFile)
+			}
+			if c.converterOption.OpenapiOption {
+				optionStr := utils.StructToOption(param.Value, "     ")
+
+				schemaOption := &protobuf.Option{
+					Name:  openapiParameterOption,
+					Value: optionStr,
 				}
+				newField.Options = append(newField.Options, schemaOption)
+				c.AddProtoImport(openapiProtoFile)
+			}
+			if !shared {
+				message.Enums = append(message.Enums, v)
 			}
+			message.Fields = append(message.Fields, newField)
+		case *protobuf.ProtoOneOf:
+			c.addNestedOneOfToParent(message, v)
 		}
+	} else {
+		c.diagnostics.drop(param.Value, "parameter %s has no schema and is dropped", param.Value.Name)
 	}
 
-	if len(operation.Parameters) > 0 {
-		for _, param := range operation.Parameters {
-			if param.Value.Schema != nil {
-				fieldOrMessage, err := c.ConvertSchemaToProtoType(param.Value.Schema, param.Value.Name, message)
-				if err != nil {
-					return "", err
-				}
-				description := param.Value.Description
-				switch v := fieldOrMessage.(type) {
-				case *protobuf.ProtoField:
-					if c.converterOption.ApiOption {
+	return nil
+}
+
+// parameterFragmentField declares the fragment message of the group of a referenced parameter the
+// first time it is met and returns a field of the fragment type. The fields of the fragment keep
+// the annotations binding them to the request.
+func (c *ProtoConverter) parameterFragmentField(param *openapi3.ParameterRef) (*protobuf.ProtoField, error) {
+	if c.paramGroups == nil {
+		c.paramGroups = parameterGroups(c.spec.Paths)
+	}
+	group := c.paramGroups[param.Value]
+	if len(group) == 0 {
+		group = []*openapi3.ParameterRef{param}
+	}
+
+	name, ok := c.fragments[group[0].Value]
+	if !ok {
+		fragment := &protobuf.ProtoMessage{Name: c.fragmentName(group)}
+		c.addMessageToProto(fragment)
+		name = fragment.Name
+		for _, member := range group {
+			c.fragments[member.Value] = name
+		}
+		for _, member := range group {
+			if err := c.addParameterFields(fragment, member); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	fieldName := name
+	if len(group) == 1 {
+		fieldName = param.Value.Name
+	}
+	return &protobuf.ProtoField{
+		Name: c.applyNamingOption(fieldName),
+		Type: name,
+	}, nil
+}
+
+// fragmentName names the fragment message of a group of referenced parameters after the single
+// parameter, or after all of them
+func (c *ProtoConverter) fragmentName(group []*openapi3.ParameterRef) string {
+	if len(group) == 1 {
+		return c.refTypeName(group[0].Ref, "Parameter")
+	}
+	var sb strings.Builder
+	for _, param := range group {
+		sb.WriteString(utils.ToUpperCase(utils.ExtractMessageNameFromRef(param.Ref)))
+	}
+	name := c.applyNamingOption(sb.String() + "Parameters")
+	if declared := c.declaredTypes(); declared[name] {
+		name = uniqueName(name, declared)
+	}
+	return name
+}
+
+// addRequestBodyFields converts the content of a request body into fields of message
+func (c *ProtoConverter) addRequestBodyFields(message *protobuf.ProtoMessage, requestBody *openapi3.RequestBody) error {
+	for _, mediaTypeStr := range utils.SortedKeys(requestBody.Content) {
+		schema := requestBody.Content[mediaTypeStr].Schema
+		if schema != nil {
+			protoType, err := c.ConvertSchemaToProtoType(schema, utils.FormatStr(mediaTypeStr), message)
+			if err != nil {
+				return err
+			}
+
+			switch v := protoType.(type) {
+			case *protobuf.ProtoField:
+				if c.converterOption.ApiOption {
+					var optionName string
+					if mediaTypeStr == "application/json" {
+						optionName = "api.body"
+					} else if mediaTypeStr == "application/x-www-form-urlencoded" || mediaTypeStr == "multipart/form-data" {
+						optionName = "api.form"
+					}
+					if optionName != "" {
 						v.Options = append(v.Options, &protobuf.Option{
-							Name:  "api." + param.Value.In,
-							Value: fmt.Sprintf("%q", param.Value.Name),
+							Name:  optionName,
+							Value: fmt.Sprintf("%q", v.Name),
 						})
 						c.AddProtoImport(apiProtoFile)
 					}
-					if c.converterOption.OpenapiOption {
-						optionStr := utils.StructToOption(param.Value, "     ")
-
-						schemaOption := &protobuf.Option{
-							Name:  openapiParameterOption,
-							Value: optionStr,
+				}
+				c.applyPresence(v, schema, requestBody.Required, isNullable(schema))
+				c.addFieldIfNotExists(&message.Fields, v)
+			case *protobuf.ProtoMessage:
+				if mediaTypeStr == multipartFormData {
+					c.convertFormFiles(v, schema)
+				}
+				for _, field := range v.Fields {
+					if c.converterOption.ApiOption {
+						var optionName string
+						if mediaTypeStr == "application/json" {
+							optionName = "api.body"
+						} else if mediaTypeStr == "application/x-www-form-urlencoded" || mediaTypeStr == "multipart/form-data" {
+							optionName = "api.form"
 						}
-						v.Options = append(v.Options, schemaOption)
-						c.AddProtoImport(openapiProtoFile)
-					}
-					v.Description = description
-					c.addFieldIfNotExists(&message.Fields, v)
-				case *protobuf.ProtoMessage:
-					for _, field := range v.Fields {
-						if c.converterOption.ApiOption {
+						if optionName != "" {
 							field.Options = append(field.Options, &protobuf.Option{
-								Name:  "api." + param.Value.In,
-								Value: fmt.Sprintf("%q", param.Value.Name),
+								Name:  optionName,
+								Value: fmt.Sprintf("%q", field.Name),
 							})
 							c.AddProtoImport(apiProtoFile)
 						}
-						if c.converterOption.OpenapiOption {
-							optionStr := utils.StructToOption(param.Value, "     ")
-
-							schemaOption := &protobuf.Option{
-								Name:  openapiParameterOption,
-								Value: optionStr,
-							}
-							field.Options = append(field.Options, schemaOption)
-							c.AddProtoImport(openapiProtoFile)
-						}
-						c.addFieldIfNotExists(&message.Fields, field)
-					}
-					for _, enum := range v.Enums {
-						message.Enums = append(message.Enums, enum)
-					}
-					for _, nestedMessage := range v.Messages {
-						c.addMessageIfNotExists(&message.Messages, nestedMessage)
 					}
-				case *protobuf.ProtoEnum:
-					name := param.Value.Name
-					if c.converterOption.NamingOption {
-						name = utils.ToPascaleCase(name)
-					}
-					newField := &protobuf.ProtoField{
-						Name: name + "_field",
-						Type: v.Name,
+					c.addContentField(message, field, schema.Value, mediaTypeStr)
+				}
+				c.mergeDeclarations(message, v, schema.Value, mediaTypeStr)
+			case *protobuf.ProtoEnum:
+				name := mediaTypeStr
+				if c.converterOption.NamingOption {
+					name = utils.ToSnakeCase(name)
+				} else {
+					name = utils.FormatStr(name)
+				}
+				newField := &protobuf.ProtoField{
+					Name: name + "_field",
+					Type: v.Name,
+				}
+				if c.converterOption.ApiOption {
+					var optionName string
+					if mediaTypeStr == "application/json" {
+						optionName = "api.body"
+					} else if mediaTypeStr == "application/x-www-form-urlencoded" || mediaTypeStr == "multipart/form-data" {
+						optionName = "api.form"
 					}
-					if c.converterOption.ApiOption {
+					if optionName != "" {
 						newField.Options = append(newField.Options, &protobuf.Option{
-							Name:  "api." + param.Value.In,
-							Value: fmt.Sprintf("%q", param.Value.Name),
+							Name:  optionName,
+							Value: fmt.Sprintf("%q", v.Name),
 						})
 						c.AddProtoImport(apiProtoFile)
 					}
-					if c.converterOption.OpenapiOption {
-						optionStr := utils.StructToOption(param.Value, "     ")
+				}
+				if c.converterOption.OpenapiOption {
+					optionStr := utils.StructToOption(schema.Value, "     ")
 
-						schemaOption := &protobuf.Option{
-							Name:  openapiParameterOption,
-							Value: optionStr,
-						}
-						newField.Options = append(newField.Options, schemaOption)
-						c.AddProtoImport(openapiProtoFile)
+					schemaOption := &protobuf.Option{
+						Name:  openapiPropertyOption,
+						Value: optionStr,
 					}
-					message.Enums = append(message.Enums, v)
-					message.Fields = append(message.Fields, newField)
-				case *protobuf.ProtoOneOf:
-					message.OneOfs = append(message.OneOfs, v)
+					newField.Options = append(newField.Options, schemaOption)
+					c.AddProtoImport(openapiProtoFile)
 				}
+				message.Enums = append(message.Enums, v)
+				message.Fields = append(message.Fields, newField)
+			case *protobuf.ProtoOneOf:
+				c.addNestedOneOfToParent(message, v)
 			}
 		}
 	}
 
-	// if there are no fields or messages, return an empty message
-	if len(message.Fields) > 0 || len(message.Messages) > 0 || len(message.Enums) > 0 {
+	return nil
+}
+
+// protoValueType returns the type of a converted schema used as an array item or a map value,
+// declaring its nested message or enum in parentMessage. Lists and maps, which proto3 cannot nest,
+// are wrapped into a message.
+func (c *ProtoConverter) protoValueType(converted interface{}, parentMessage *protobuf.ProtoMessage) string {
+	switch v := converted.(type) {
+	case *protobuf.ProtoField:
+		if v.Repeated {
+			return c.wrapperType(protoTypeBaseName(v.Type)+"List", &protobuf.ProtoField{
+				Name:     c.applyNamingOption("items"),
+				Type:     v.Type,
+				Repeated: true,
+			}, parentMessage)
+		}
+		if valueType, ok := protoMapValueType(v.Type); ok {
+			return c.wrapperType(protoTypeBaseName(valueType)+"Map", &protobuf.ProtoField{
+				Name: "additional_properties",
+				Type: v.Type,
+			}, parentMessage)
+		}
+		return v.Type
+	case *protobuf.ProtoMessage:
+		c.addNestedMessageToParent(parentMessage, v)
+		return v.Name
+	case *protobuf.ProtoEnum:
+		c.addNestedEnumToParent(parentMessage, v)
+		return v.Name
+	}
+	return "string"
+}
+
+// mapValueType converts the additionalProperties schema of an object into the value type of a map
+// declared in parentMessage
+func (c *ProtoConverter) mapValueType(schema *openapi3.Schema, protoName string, parentMessage *protobuf.ProtoMessage) (string, error) {
+	converted, err := c.ConvertSchemaToProtoType(schema.AdditionalProperties.Schema, protoName+"AdditionalProperties", parentMessage)
+	if err != nil {
+		return "", err
+	}
+	if _, ok := converted.(*protobuf.ProtoOneOf); ok {
+		c.diagnostics.warn(schema.AdditionalProperties.Schema.Value, "oneOf additionalProperties are not supported and are converted to strings")
+	}
+	return c.protoValueType(converted, parentMessage), nil
+}
+
+// wrapperType declares a message holding the single list or map field in parentMessage, or at the
+// top level without one, and returns its name. An identical wrapper of the same name is reused.
+func (c *ProtoConverter) wrapperType(name string, field *protobuf.ProtoField, parentMessage *protobuf.ProtoMessage) string {
+	wrapper := &protobuf.ProtoMessage{
+		Name:   c.applyNamingOption(utils.ToUpperCase(name)),
+		Fields: []*protobuf.ProtoField{field},
+	}
+	messages, declared := c.ProtoFile.Messages, c.declaredTypes()
+	if parentMessage != nil {
+		messages, declared = parentMessage.Messages, make(map[string]bool)
+		for _, nestedMessage := range parentMessage.Messages {
+			declared[nestedMessage.Name] = true
+		}
+		for _, nestedEnum := range parentMessage.Enums {
+			declared[nestedEnum.Name] = true
+		}
+	}
+
+	base := wrapper.Name
+	for i := 2; declared[wrapper.Name]; i++ {
+		for _, message := range messages {
+			if message.Name == wrapper.Name && protoMessageKey(message, false) == protoMessageKey(wrapper, false) {
+				return wrapper.Name
+			}
+		}
+		wrapper.Name = base + strconv.Itoa(i)
+	}
+	if parentMessage == nil {
+		c.addMessageToProto(wrapper)
+	} else {
+		c.addNestedMessageToParent(parentMessage, wrapper)
+	}
+	return wrapper.Name
+}
+
+// protoMapValueType returns the value type of a map type
+func protoMapValueType(typ string) (string, bool) {
+	if !strings.HasPrefix(typ, "map<string, ") || !strings.HasSuffix(typ, ">") {
+		return "", false
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(typ, "map<string, "), ">"), true
+}
+
+// protoTypeBaseName returns the name of a type without its package
+func protoTypeBaseName(typ string) string {
+	return typ[strings.LastIndex(typ, ".")+1:]
+}
+
+// convertFormFiles turns the fields of the file parts of a multipart/form-data body into fields of
+// the form file message
+func (c *ProtoConverter) convertFormFiles(message *protobuf.ProtoMessage, schema *openapi3.SchemaRef) {
+	if schema.Value == nil {
+		return
+	}
+	for _, propName := range utils.SortedKeys(schema.Value.Properties) {
+		if !isFormFile(schema.Value.Properties[propName]) {
+			continue
+		}
+		name := c.applyNamingOption(propName)
+		for _, field := range message.Fields {
+			if field.Name == name {
+				field.Type = c.formFileType()
+				field.Optional = false
+			}
+		}
+	}
+}
+
+// formFileType declares the message of the file parts of multipart/form-data bodies the first time
+// it is needed and returns its name
+func (c *ProtoConverter) formFileType() string {
+	if c.formFile == "" {
+		message := &protobuf.ProtoMessage{
+			Name:        formFileName,
+			Description: "File part of a multipart/form-data request body",
+			Fields: []*protobuf.ProtoField{
+				{Name: c.applyNamingOption("filename"), Type: "string"},
+				{Name: c.applyNamingOption("content_type"), Type: "string"},
+				{Name: c.applyNamingOption("content"), Type: "bytes"},
+			},
+		}
 		c.addMessageToProto(message)
-		return message.Name, nil
+		c.formFile = message.Name
 	}
+	return c.formFile
+}
 
-	return "", nil
+// convertRequestBodyRef converts a referenced request body into a top-level message the first time it
+// is met and returns its name
+func (c *ProtoConverter) convertRequestBodyRef(bodyRef *openapi3.RequestBodyRef) (string, error) {
+	if bodyRef.Value == nil {
+		return "", fmt.Errorf("request body %s is not resolved", bodyRef.Ref)
+	}
+	if name, ok := c.refTypes[bodyRef.Value]; ok {
+		return name, nil
+	}
+
+	name := c.refTypeName(bodyRef.Ref, "Request")
+	message := &protobuf.ProtoMessage{Name: name}
+	if err := c.addRequestBodyFields(message, bodyRef.Value); err != nil {
+		return "", err
+	}
+	c.addMessageToProto(message)
+	c.refTypes[bodyRef.Value] = message.Name
+	return message.Name, nil
+}
+
+// addParameterEnum declares the enum of a referenced parameter once at the top level and returns
+// its name
+func (c *ProtoConverter) addParameterEnum(param *openapi3.ParameterRef, enum *protobuf.ProtoEnum) string {
+	if name, ok := c.refTypes[param.Value]; ok {
+		return name
+	}
+
+	enum.Name = c.refTypeName(param.Ref, "Enum")
+	c.addEnumToProto(enum)
+	c.refTypes[param.Value] = enum.Name
+	return enum.Name
 }
 
 // generateResponseMessage generates a response message for an operation
@@ -549,15 +961,22 @@ func (c *ProtoConverter) generateResponseMessage(operation *openapi3.Operation,
 
 	emptyFlag := true
 
-	for statusCode, responseRef := range responses {
+	for _, statusCode := range utils.SortedKeys(responses) {
+		responseRef := responses[statusCode]
 		if responseRef.Ref == "" && (responseRef.Value == nil || len(responseRef.Value.Content) == 0) {
-			break
+			if responseRef.Value != nil && len(responseRef.Value.Headers) > 0 {
+				c.diagnostics.drop(responseRef.Value, "headers of response %s without content are dropped from %s", statusCode, wrapperMessageName)
+			}
+			continue
 		}
-		emptyFlag = false
 		messageName, err := c.processSingleResponse(statusCode, responseRef, operation, methodName)
 		if err != nil {
 			return "", err
 		}
+		if messageName == "" {
+			continue
+		}
+		emptyFlag = false
 
 		name := "Response_" + statusCode
 		if c.converterOption.NamingOption {
@@ -577,120 +996,75 @@ func (c *ProtoConverter) generateResponseMessage(operation *openapi3.Operation,
 
 	c.addMessageToProto(wrapperMessage)
 
-	return wrapperMessageName, nil
+	return wrapperMessage.Name, nil
 }
 
 // processSingleResponse deals with a single response in an operation
 func (c *ProtoConverter) processSingleResponse(statusCode string, responseRef *openapi3.ResponseRef, operation *openapi3.Operation, methodName string) (string, error) {
 	if responseRef.Ref != "" {
-		return utils.ExtractMessageNameFromRef(responseRef.Ref), nil
+		return c.convertResponseRef(responseRef)
 	}
 
-	response := responseRef.Value
 	messageName := utils.GetMessageName(operation, methodName, "Response") + utils.ToUpperCase(statusCode)
 
 	if c.converterOption.NamingOption {
 		messageName = utils.ToPascaleCase(messageName)
 	}
 
-	message := &protobuf.ProtoMessage{Name: messageName}
-
-	if len(response.Headers) > 0 {
-		for headerName, headerRef := range response.Headers {
-			if headerRef != nil {
+	return c.convertResponse(responseRef.Value, messageName)
+}
 
-				fieldOrMessage, err := c.ConvertSchemaToProtoType(headerRef.Value.Schema, headerName, message)
-				if err != nil {
-					return "", err
-				}
+// convertResponseRef converts a referenced response into a top-level message the first time it is
+// met and returns its name
+func (c *ProtoConverter) convertResponseRef(responseRef *openapi3.ResponseRef) (string, error) {
+	if responseRef.Value == nil {
+		return "", fmt.Errorf("response %s is not resolved", responseRef.Ref)
+	}
+	if name, ok := c.refTypes[responseRef.Value]; ok {
+		return name, nil
+	}
 
-				switch v := fieldOrMessage.(type) {
-				case *protobuf.ProtoField:
-					if c.converterOption.ApiOption {
-						option := &protobuf.Option{
-							Name:  "api.header",
-							Value: fmt.Sprintf("%q", headerName),
-						}
-						v.Options = append(v.Options, option)
-						c.AddProtoImport(apiProtoFile)
-					}
-					if c.converterOption.OpenapiOption {
-						optionStr := utils.StructToOption(headerRef.Value, "     ")
+	name := c.refTypeName(responseRef.Ref, "Response")
+	messageName, err := c.convertResponse(responseRef.Value, name)
+	if err != nil {
+		return "", err
+	}
+	// A response without content is still declared, as it is used as a type
+	if messageName == "" {
+		message := &protobuf.ProtoMessage{Name: name}
+		c.addMessageToProto(message)
+		messageName = message.Name
+	}
+	c.refTypes[responseRef.Value] = messageName
+	return messageName, nil
+}
 
-						schemaOption := &protobuf.Option{
-							Name:  openapiPropertyOption,
-							Value: optionStr,
-						}
-						v.Options = append(v.Options, schemaOption)
-						c.AddProtoImport(openapiProtoFile)
-					}
-					c.addFieldIfNotExists(&message.Fields, v)
-				case *protobuf.ProtoMessage:
-					for _, field := range v.Fields {
-						if c.converterOption.ApiOption {
-							option := &protobuf.Option{
-								Name:  "api.header",
-								Value: fmt.Sprintf("%q", field.Name),
-							}
-							field.Options = append(field.Options, option)
-							c.AddProtoImport(apiProtoFile)
-						}
-						if c.converterOption.OpenapiOption {
-							optionStr := utils.StructToOption(headerRef.Value, "     ")
-
-							schemaOption := &protobuf.Option{
-								Name:  openapiPropertyOption,
-								Value: optionStr,
-							}
-							field.Options = append(field.Options, schemaOption)
-							c.AddProtoImport(openapiProtoFile)
-						}
-						c.addFieldIfNotExists(&message.Fields, field)
-					}
-					for _, enum := range v.Enums {
-						message.Enums = append(message.Enums, enum)
-					}
-					for _, nestedMessage := range v.Messages {
-						c.addMessageIfNotExists(&message.Messages, nestedMessage)
-					}
-				case *protobuf.ProtoEnum:
-					name := headerName
-					if c.converterOption.NamingOption {
-						name = utils.ToSnakeCase(name)
-					}
-					newField := &protobuf.ProtoField{
-						Name: name + "_field",
-						Type: v.Name,
-					}
-					if c.converterOption.ApiOption {
-						option := &protobuf.Option{
-							Name:  "api.header",
-							Value: fmt.Sprintf("%q", headerName),
-						}
-						newField.Options = append(newField.Options, option)
-						c.AddProtoImport(apiProtoFile)
-					}
-					if c.converterOption.OpenapiOption {
-						optionStr := utils.StructToOption(headerRef.Value, "     ")
+// convertResponse converts the headers and content of a response into a message named messageName
+// and returns its name, or an empty string if the response has neither
+func (c *ProtoConverter) convertResponse(response *openapi3.Response, messageName string) (string, error) {
+	message := &protobuf.ProtoMessage{Name: messageName}
 
-						schemaOption := &protobuf.Option{
-							Name:  openapiPropertyOption,
-							Value: optionStr,
-						}
-						newField.Options = append(newField.Options, schemaOption)
-						c.AddProtoImport(openapiProtoFile)
-					}
-					message.Enums = append(message.Enums, v)
-					message.Fields = append(message.Fields, newField)
-				case *protobuf.ProtoOneOf:
-					message.OneOfs = append(message.OneOfs, v)
-				}
+	for _, headerName := range utils.SortedKeys(response.Headers) {
+		headerRef := response.Headers[headerName]
+		if headerRef == nil {
+			continue
+		}
+		// A referenced header is either embedded or referenced as a fragment
+		if headerRef.Ref != "" && c.converterOption.FragmentOption == FragmentReference {
+			field, err := c.headerFragmentField(headerName, headerRef)
+			if err != nil {
+				return "", err
 			}
+			c.addFieldIfNotExists(&message.Fields, field)
+			continue
+		}
+		if err := c.addHeaderFields(message, headerName, headerRef); err != nil {
+			return "", err
 		}
 	}
 
-	for mediaTypeStr, mediaType := range response.Content {
-		schema := mediaType.Schema
+	for _, mediaTypeStr := range utils.SortedKeys(response.Content) {
+		schema := response.Content[mediaTypeStr].Schema
 		if schema != nil {
 
 			protoType, err := c.ConvertSchemaToProtoType(schema, utils.FormatStr(mediaTypeStr), message)
@@ -739,14 +1113,9 @@ func (c *ProtoConverter) processSingleResponse(statusCode string, responseRef *o
 						field.Options = append(field.Options, schemaOption)
 						c.AddProtoImport(openapiProtoFile)
 					}
-					c.addFieldIfNotExists(&message.Fields, field)
-				}
-				for _, enum := range v.Enums {
-					message.Enums = append(message.Enums, enum)
-				}
-				for _, nestedMessage := range v.Messages {
-					c.addMessageIfNotExists(&message.Messages, nestedMessage)
+					c.addContentField(message, field, schema.Value, mediaTypeStr)
 				}
+				c.mergeDeclarations(message, v, schema.Value, mediaTypeStr)
 			case *protobuf.ProtoEnum:
 				name := mediaTypeStr
 				if c.converterOption.NamingOption {
@@ -779,12 +1148,12 @@ func (c *ProtoConverter) processSingleResponse(statusCode string, responseRef *o
 				message.Enums = append(message.Enums, v)
 				message.Fields = append(message.Fields, newField)
 			case *protobuf.ProtoOneOf:
-				message.OneOfs = append(message.OneOfs, v)
+				c.addNestedOneOfToParent(message, v)
 			}
 		}
 	}
 
-	if len(message.Fields) > 0 || len(message.Messages) > 0 || len(message.Enums) > 0 {
+	if len(message.Fields) > 0 || len(message.OneOfs) > 0 || len(message.Messages) > 0 || len(message.Enums) > 0 {
 		c.addMessageToProto(message)
 		return message.Name, nil
 	}
@@ -802,10 +1171,13 @@ func (c *ProtoConverter) ConvertSchemaToProtoType(
 
 	// Handle referenced schema
 	if schemaRef.Ref != "" {
-		name := c.applyNamingOption(utils.ExtractMessageNameFromRef(schemaRef.Ref))
+		name, err := c.addExternalSchema(schemaRef)
+		if err != nil {
+			return nil, err
+		}
 		return &protobuf.ProtoField{
 			Name: name,
-			Type: utils.ExtractMessageNameFromRef(schemaRef.Ref),
+			Type: name,
 		}, nil
 	}
 
@@ -817,34 +1189,45 @@ func (c *ProtoConverter) ConvertSchemaToProtoType(
 	schema := schemaRef.Value
 	description := schema.Description
 
+	// A schema met again while it is being converted is a recursive structure referring to itself
+	if name, ok := c.converting[schema]; ok {
+		return &protobuf.ProtoField{
+			Name: c.applyNamingOption(protoName),
+			Type: name,
+		}, nil
+	}
+
 	// Handle oneOf, allOf, anyOf even if schema.Type is nil
 	if len(schema.OneOf) > 0 {
-		protoUnion, err := c.handleOneOf(schema.OneOf, protoName, parentMessage)
+		protoUnion, err := c.handleOneOf(schema, protoName, parentMessage)
 		if err != nil {
 			return nil, err
 		}
 		return protoUnion, nil
 	} else if len(schema.AllOf) > 0 {
-		protoMessage, err := c.handleAllOf(schema.AllOf, protoName, parentMessage)
+		protoMessage, err := c.handleAllOf(schema, protoName, parentMessage)
 		if err != nil {
 			return nil, err
 		}
 		return protoMessage, nil
 	} else if len(schema.AnyOf) > 0 {
-		protoMessage, err := c.handleAnyOf(schema.AnyOf, protoName, parentMessage)
+		protoMessage, err := c.handleAnyOf(schema, protoName, parentMessage)
 		if err != nil {
 			return nil, err
 		}
 		return protoMessage, nil
 	}
 
+	if schema.Not != nil {
+		c.diagnostics.warn(schema, "not is ignored")
+	}
+
+	checkTypeList(c.diagnostics, schema)
+
 	// Process schema type
 	switch {
 	case schema.Type.Includes("string"):
-		if schema.Format == "date" || schema.Format == "date-time" {
-			protoType = "google.protobuf.Timestamp"
-			c.AddProtoImport("google/protobuf/timestamp.proto")
-		} else if len(schema.Enum) != 0 {
+		if len(schema.Enum) != 0 {
 			var name string
 			if parentMessage == nil {
 				name = protoName
@@ -863,7 +1246,7 @@ func (c *ProtoConverter) ConvertSchemaToProtoType(
 			}
 			result = protoEnum
 		} else {
-			protoType = "string"
+			protoType = c.mapProtoType(schema, openapi3.TypeString)
 		}
 
 	case schema.Type.Includes("integer"):
@@ -885,10 +1268,8 @@ func (c *ProtoConverter) ConvertSchemaToProtoType(
 				})
 			}
 			result = protoEnum
-		} else if schema.Format == "int32" {
-			protoType = "int32"
 		} else {
-			protoType = "int64"
+			protoType = c.mapProtoType(schema, openapi3.TypeInteger)
 		}
 
 	case schema.Type.Includes("number"):
@@ -910,14 +1291,12 @@ func (c *ProtoConverter) ConvertSchemaToProtoType(
 				})
 			}
 			result = protoEnum
-		} else if schema.Format == "float" {
-			protoType = "float"
 		} else {
-			protoType = "double"
+			protoType = c.mapProtoType(schema, openapi3.TypeNumber)
 		}
 
 	case schema.Type.Includes("boolean"):
-		protoType = "bool"
+		protoType = c.mapProtoType(schema, openapi3.TypeBoolean)
 
 	case schema.Type.Includes("array"):
 		if schema.Items != nil {
@@ -926,15 +1305,12 @@ func (c *ProtoConverter) ConvertSchemaToProtoType(
 				return nil, err
 			}
 
-			fieldType := ""
-			if field, ok := fieldOrMessage.(*protobuf.ProtoField); ok {
-				fieldType = field.Type
-			} else if nestedMessage, ok := fieldOrMessage.(*protobuf.ProtoMessage); ok {
-				fieldType = nestedMessage.Name
-				c.addNestedMessageToParent(parentMessage, nestedMessage)
-			} else if enum, ok := fieldOrMessage.(*protobuf.ProtoEnum); ok {
-				fieldType = enum.Name
-				c.addNestedEnumToParent(parentMessage, enum)
+			if _, ok := fieldOrMessage.(*protobuf.ProtoOneOf); ok {
+				c.diagnostics.warn(schema.Items.Value, "oneOf array items are not supported and are converted to strings")
+			}
+			fieldType := c.protoValueType(fieldOrMessage, parentMessage)
+			if _, ok := schema.Extensions["prefixItems"]; ok {
+				c.diagnostics.warn(schema, "prefixItems tuples are not supported and are converted to a list of %s", fieldType)
 			}
 
 			result = &protobuf.ProtoField{
@@ -943,16 +1319,41 @@ func (c *ProtoConverter) ConvertSchemaToProtoType(
 				Repeated:    true,
 				Description: description,
 			}
+		} else {
+			c.diagnostics.warn(schema, "array without items is converted to a list of strings")
+			result = &protobuf.ProtoField{
+				Name:        c.applyNamingOption(protoName),
+				Type:        "string",
+				Repeated:    true,
+				Description: description,
+			}
 		}
 
 	case schema.Type.Includes("object"):
+		// An object holding nothing but additionalProperties is a map
+		if parentMessage != nil && len(schema.Properties) == 0 && schema.AdditionalProperties.Schema != nil {
+			valueType, err := c.mapValueType(schema, protoName, parentMessage)
+			if err != nil {
+				return nil, err
+			}
+			result = &protobuf.ProtoField{
+				Name:        c.applyNamingOption(protoName),
+				Type:        "map<string, " + valueType + ">",
+				Description: description,
+			}
+			break
+		}
+
 		var message *protobuf.ProtoMessage
 		if parentMessage == nil {
 			message = &protobuf.ProtoMessage{Name: protoName}
 		} else {
 			message = &protobuf.ProtoMessage{Name: c.applyNamingOption(utils.ToUpperCase(protoName))}
 		}
-		for propName, propSchema := range schema.Properties {
+		c.converting[schema] = message.Name
+		defer delete(c.converting, schema)
+		for _, propName := range orderedKeys(schema.Properties, c.specOrder.PropertyOrder(schema), c.converterOption) {
+			propSchema := schema.Properties[propName]
 			protoType, err := c.ConvertSchemaToProtoType(propSchema, propName, message)
 			if err != nil {
 				return nil, err
@@ -969,6 +1370,7 @@ func (c *ProtoConverter) ConvertSchemaToProtoType(
 					field.Options = append(field.Options, schemaOption)
 					c.AddProtoImport(openapiProtoFile)
 				}
+				c.applyPresence(field, propSchema, utils.Contains(schema.Required, propName), isNullable(propSchema))
 				message.Fields = append(message.Fields, field)
 			} else if nestedMessage, ok := protoType.(*protobuf.ProtoMessage); ok {
 				var name string
@@ -1003,27 +1405,33 @@ func (c *ProtoConverter) ConvertSchemaToProtoType(
 		}
 
 		if schema.AdditionalProperties.Schema != nil {
-			mapValueType := "string"
-			additionalPropMessage, err := c.ConvertSchemaToProtoType(schema.AdditionalProperties.Schema, protoName+"AdditionalProperties", parentMessage)
+			mapValueType, err := c.mapValueType(schema, protoName, message)
 			if err != nil {
 				return nil, err
 			}
-			if msg, ok := additionalPropMessage.(*protobuf.ProtoMessage); ok {
-				mapValueType = msg.Name
-			} else if enum, ok := additionalPropMessage.(*protobuf.ProtoEnum); ok {
-				mapValueType = enum.Name
-			}
 
 			message.Fields = append(message.Fields, &protobuf.ProtoField{
 				Name: "additional_properties",
 				Type: "map<string, " + mapValueType + ">",
 			})
+		} else if schema.AdditionalProperties.Has != nil && *schema.AdditionalProperties.Has {
+			c.diagnostics.drop(schema, "additionalProperties without a schema are dropped")
 		}
 
 		message.Description = description
 		result = message
 	}
 
+	// Schemas without a supported type fall back to strings
+	if result == nil && protoType == "" {
+		if len(schema.Type.Slice()) == 0 {
+			c.diagnostics.warn(schema, "schema without a type is converted to string")
+		} else {
+			c.diagnostics.warn(schema, "schema of type %s is converted to string", strings.Join(schema.Type.Slice(), ", "))
+		}
+		protoType = "string"
+	}
+
 	// If result is still nil, construct a default ProtoField
 	if result == nil {
 		result = &protobuf.ProtoField{
@@ -1036,126 +1444,398 @@ func (c *ProtoConverter) ConvertSchemaToProtoType(
 	return result, nil
 }
 
-// handleOneOf processes oneOf schemas
-func (c *ProtoConverter) handleOneOf(oneOfSchemas []*openapi3.SchemaRef, protoName string, parentMessage *protobuf.ProtoMessage) (*protobuf.ProtoOneOf, error) {
+// handleOneOf processes oneOf schemas. The branches of a discriminated oneOf are named after their
+// discriminator values.
+func (c *ProtoConverter) handleOneOf(schema *openapi3.Schema, protoName string, parentMessage *protobuf.ProtoMessage) (*protobuf.ProtoOneOf, error) {
 	oneOf := &protobuf.ProtoOneOf{
 		Name: protoName + "OneOf",
 	}
 
-	for i, schemaRef := range oneOfSchemas {
+	values := discriminatorValues(c.diagnostics, schema, schema.OneOf)
+	for i, schemaRef := range schema.OneOf {
 		fieldName := fmt.Sprintf("%sOption%d", protoName, i+1)
-		protoType, err := c.ConvertSchemaToProtoType(schemaRef, fieldName, parentMessage)
+		branchParent, wrapper := c.unionBranchParent(schemaRef, fieldName, parentMessage)
+		protoType, err := c.ConvertSchemaToProtoType(schemaRef, fieldName, branchParent)
 		if err != nil {
 			return nil, err
 		}
 		switch v := protoType.(type) {
 		case *protobuf.ProtoField:
+			if values[i] != "" {
+				v.Name = c.applyNamingOption(values[i])
+			}
+			// oneof fields can be neither lists nor maps
+			if _, ok := protoMapValueType(v.Type); ok || v.Repeated {
+				v.Type = c.protoValueType(v, parentMessage)
+				v.Repeated = false
+			}
 			oneOf.Fields = append(oneOf.Fields, v)
 		case *protobuf.ProtoMessage:
-			//todo naming
 			newField := &protobuf.ProtoField{
-				Name: v.Name + "_field",
+				Name: c.unionMemberName(values[i], v.Name),
 				Type: v.Name,
 			}
 			c.addNestedMessageToParent(parentMessage, v)
 			oneOf.Fields = append(oneOf.Fields, newField)
 		case *protobuf.ProtoEnum:
 			newField := &protobuf.ProtoField{
-				Name: v.Name + "_field",
+				Name: c.unionMemberName(values[i], v.Name),
 				Type: v.Name,
 			}
 			c.addNestedEnumToParent(parentMessage, v)
 			oneOf.Fields = append(oneOf.Fields, newField)
 		case *protobuf.ProtoOneOf:
-			c.addNestedOneOfToParent(parentMessage, v)
+			newField := &protobuf.ProtoField{
+				Name: c.unionMemberName(values[i], wrapper.Name),
+				Type: wrapper.Name,
+			}
+			c.addNestedMessageToParent(parentMessage, wrapper)
+			oneOf.Fields = append(oneOf.Fields, newField)
 		}
 	}
 
 	if parentMessage != nil {
 		parentMessage.OneOfs = append(parentMessage.OneOfs, oneOf)
 	}
+	c.addDiscriminatorEnum(schema.Discriminator, values, protoName, parentMessage)
 
 	return oneOf, nil
 }
 
-// handleAllOf processes allOf schemas
-func (c *ProtoConverter) handleAllOf(allOfSchemas []*openapi3.SchemaRef, protoName string, parentMessage *protobuf.ProtoMessage) (*protobuf.ProtoMessage, error) {
-	allOfMessage := &protobuf.ProtoMessage{
-		Name: protoName + "AllOf",
+// handleAllOf merges the parts of an allOf schema into a single message. In embed mode the
+// referenced parts are kept as fields of their own type.
+func (c *ProtoConverter) handleAllOf(schema *openapi3.Schema, protoName string, parentMessage *protobuf.ProtoMessage) (interface{}, error) {
+	// A property wrapping a single referenced schema in an allOf only annotates the reference
+	if parentMessage != nil && len(schema.AllOf) == 1 && schema.AllOf[0].Ref != "" && len(schema.Properties) == 0 {
+		return c.ConvertSchemaToProtoType(schema.AllOf[0], protoName, parentMessage)
+	}
+
+	// The merged schema is converted into a message named as an object schema would be
+	name := protoName
+	if parentMessage != nil {
+		name = c.applyNamingOption(utils.ToUpperCase(protoName))
+	}
+	c.converting[schema] = name
+	defer delete(c.converting, schema)
+
+	merged, bases := flattenAllOf(c.diagnostics, c.specOrder, schema, c.converterOption.AllOfOption == AllOfEmbed)
+	protoType, err := c.ConvertSchemaToProtoType(&openapi3.SchemaRef{Value: merged}, protoName, parentMessage)
+	if err != nil {
+		return nil, err
+	}
+	message, ok := protoType.(*protobuf.ProtoMessage)
+	if !ok || len(bases) == 0 {
+		return protoType, nil
 	}
 
-	for i, schemaRef := range allOfSchemas {
-		fieldName := fmt.Sprintf("%sPart%d", protoName, i+1)
-		protoType, err := c.ConvertSchemaToProtoType(schemaRef, fieldName, parentMessage)
+	fields := make([]*protobuf.ProtoField, 0, len(bases)+len(message.Fields))
+	for _, base := range bases {
+		baseType, err := c.ConvertSchemaToProtoType(base, protoName, message)
 		if err != nil {
 			return nil, err
 		}
-
-		switch v := protoType.(type) {
-		case *protobuf.ProtoField:
-			allOfMessage.Fields = append(allOfMessage.Fields, v)
-		case *protobuf.ProtoMessage:
-			newField := &protobuf.ProtoField{
-				Name: v.Name + "_field",
-				Type: v.Name,
-			}
-			c.addNestedMessageToParent(allOfMessage, v)
-			allOfMessage.Fields = append(allOfMessage.Fields, newField)
-		case *protobuf.ProtoEnum:
-			newField := &protobuf.ProtoField{
-				Name: v.Name + "_field",
-				Type: v.Name,
-			}
-			c.addNestedEnumToParent(allOfMessage, v)
-			allOfMessage.Fields = append(allOfMessage.Fields, newField)
-		case *protobuf.ProtoOneOf:
-			// Handle nested oneOf
-			c.addNestedOneOfToParent(allOfMessage, v)
+		if field, ok := baseType.(*protobuf.ProtoField); ok {
+			fields = append(fields, field)
 		}
 	}
-
-	return allOfMessage, nil
+	message.Fields = append(fields, message.Fields...)
+	return message, nil
 }
 
-// handleAnyOf processes anyOf schemas
-func (c *ProtoConverter) handleAnyOf(anyOfSchemas []*openapi3.SchemaRef, protoName string, parentMessage *protobuf.ProtoMessage) (*protobuf.ProtoMessage, error) {
+// handleAnyOf processes anyOf schemas. The branches of a discriminated anyOf are named after their
+// discriminator values.
+func (c *ProtoConverter) handleAnyOf(schema *openapi3.Schema, protoName string, parentMessage *protobuf.ProtoMessage) (*protobuf.ProtoMessage, error) {
 	anyOfMessage := &protobuf.ProtoMessage{
 		Name: protoName + "AnyOf",
 	}
+	c.converting[schema] = anyOfMessage.Name
+	defer delete(c.converting, schema)
 
-	for i, schemaRef := range anyOfSchemas {
+	values := discriminatorValues(c.diagnostics, schema, schema.AnyOf)
+	for i, schemaRef := range schema.AnyOf {
 		fieldName := fmt.Sprintf("%sOption%d", protoName, i+1)
-		protoType, err := c.ConvertSchemaToProtoType(schemaRef, fieldName, parentMessage)
+		branchParent, wrapper := c.unionBranchParent(schemaRef, fieldName, parentMessage)
+		protoType, err := c.ConvertSchemaToProtoType(schemaRef, fieldName, branchParent)
 		if err != nil {
 			return nil, err
 		}
 
 		switch v := protoType.(type) {
 		case *protobuf.ProtoField:
+			if values[i] != "" {
+				v.Name = c.applyNamingOption(values[i])
+			}
 			anyOfMessage.Fields = append(anyOfMessage.Fields, v)
 		case *protobuf.ProtoMessage:
 			newField := &protobuf.ProtoField{
-				Name: v.Name + "_field",
+				Name: c.unionMemberName(values[i], v.Name),
 				Type: v.Name,
 			}
 			c.addNestedMessageToParent(anyOfMessage, v)
 			anyOfMessage.Fields = append(anyOfMessage.Fields, newField)
 		case *protobuf.ProtoEnum:
 			newField := &protobuf.ProtoField{
-				Name: v.Name + "_field",
+				Name: c.unionMemberName(values[i], v.Name),
 				Type: v.Name,
 			}
 			c.addNestedEnumToParent(anyOfMessage, v)
 			anyOfMessage.Fields = append(anyOfMessage.Fields, newField)
 		case *protobuf.ProtoOneOf:
-			// Handle nested oneOf
-			c.addNestedOneOfToParent(anyOfMessage, v)
+			newField := &protobuf.ProtoField{
+				Name: c.unionMemberName(values[i], wrapper.Name),
+				Type: wrapper.Name,
+			}
+			c.addNestedMessageToParent(anyOfMessage, wrapper)
+			anyOfMessage.Fields = append(anyOfMessage.Fields, newField)
 		}
 	}
+	c.addDiscriminatorEnum(schema.Discriminator, values, protoName, anyOfMessage)
 
 	return anyOfMessage, nil
 }
 
+// unionBranchParent returns the message the types of a oneOf or anyOf branch are declared in. A
+// oneof cannot hold another, so an inline oneOf branch is converted into a wrapper message instead.
+func (c *ProtoConverter) unionBranchParent(schemaRef *openapi3.SchemaRef, fieldName string, parentMessage *protobuf.ProtoMessage) (*protobuf.ProtoMessage, *protobuf.ProtoMessage) {
+	if schemaRef.Ref != "" || schemaRef.Value == nil || len(schemaRef.Value.OneOf) == 0 {
+		return parentMessage, nil
+	}
+	wrapper := &protobuf.ProtoMessage{
+		Name:        c.applyNamingOption(utils.ToUpperCase(fieldName)),
+		Description: schemaRef.Value.Description,
+	}
+	return wrapper, wrapper
+}
+
+// unionMemberName names the member of a oneOf or anyOf holding an inline type after its
+// discriminator value, or after the type without one
+func (c *ProtoConverter) unionMemberName(value, typeName string) string {
+	if value != "" {
+		return c.applyNamingOption(value)
+	}
+	return typeName + "_field"
+}
+
+// addDiscriminatorEnum declares an enum of the discriminator values of a oneOf or anyOf in
+// parentMessage, or at top level without a parent, if the discriminator enum is enabled
+func (c *ProtoConverter) addDiscriminatorEnum(discriminator *openapi3.Discriminator, values []string, protoName string, parentMessage *protobuf.ProtoMessage) {
+	if !c.converterOption.DiscriminatorEnum || discriminator == nil {
+		return
+	}
+
+	name := protoName
+	if parentMessage != nil {
+		name = c.applyNamingOption(utils.ToUpperCase(protoName))
+	}
+	enum := &protobuf.ProtoEnum{Name: name + utils.ToUpperCase(discriminator.PropertyName)}
+	for _, value := range values {
+		if value != "" {
+			enum.Values = append(enum.Values, &protobuf.ProtoEnumValue{
+				Index: len(enum.Values),
+				Value: discriminatorEnumValue(enum.Name, value),
+			})
+		}
+	}
+	if len(enum.Values) == 0 {
+		return
+	}
+	if parentMessage != nil {
+		c.addNestedEnumToParent(parentMessage, enum)
+	} else {
+		c.addEnumToProto(enum)
+	}
+}
+
+// declaredTypes returns the names of the top-level messages and enums of the ProtoFile
+func (c *ProtoConverter) declaredTypes() map[string]bool {
+	names := make(map[string]bool)
+	for _, message := range c.ProtoFile.Messages {
+		names[message.Name] = true
+	}
+	for _, enum := range c.ProtoFile.Enums {
+		names[enum.Name] = true
+	}
+	return names
+}
+
+// sortDeclarations sorts enums, messages, services, methods and message fields by name
+func (c *ProtoConverter) sortDeclarations() {
+	sort.SliceStable(c.ProtoFile.Enums, func(i, j int) bool {
+		return c.ProtoFile.Enums[i].Name < c.ProtoFile.Enums[j].Name
+	})
+	sort.SliceStable(c.ProtoFile.Messages, func(i, j int) bool {
+		return c.ProtoFile.Messages[i].Name < c.ProtoFile.Messages[j].Name
+	})
+	sort.SliceStable(c.ProtoFile.Services, func(i, j int) bool {
+		return c.ProtoFile.Services[i].Name < c.ProtoFile.Services[j].Name
+	})
+	for _, service := range c.ProtoFile.Services {
+		sort.SliceStable(service.Methods, func(i, j int) bool {
+			return service.Methods[i].Name < service.Methods[j].Name
+		})
+	}
+	for _, message := range c.ProtoFile.Messages {
+		sortMessageFields(message)
+	}
+}
+
+// sortMessageFields sorts the fields of a message and of its nested messages by name
+func sortMessageFields(message *protobuf.ProtoMessage) {
+	sort.SliceStable(message.Fields, func(i, j int) bool {
+		return message.Fields[i].Name < message.Fields[j].Name
+	})
+	for _, nestedMessage := range message.Messages {
+		sortMessageFields(nestedMessage)
+	}
+}
+
+// applyPresence tracks presence of a scalar field that is not required or is nullable, either as a
+// proto3 `optional` field or as a google.protobuf wrapper type depending on the presence option
+func (c *ProtoConverter) applyPresence(field *protobuf.ProtoField, schema *openapi3.SchemaRef, required, nullable bool) {
+	if required && !nullable {
+		return
+	}
+	if field.Repeated || !isScalarSchema(schema) {
+		return
+	}
+	// Scalars mapped to messages, such as google.protobuf.Timestamp, track presence already
+	wrapper, ok := protoScalarWrappers[field.Type]
+	if !ok {
+		return
+	}
+
+	switch c.converterOption.PresenceOption {
+	case PresenceOptional:
+		field.Optional = true
+	case PresenceWrapper:
+		field.Type = wrapper
+		c.AddProtoImport(wrappersProtoFile)
+	}
+}
+
+// addHeaderFields converts a response header into fields of message
+func (c *ProtoConverter) addHeaderFields(message *protobuf.ProtoMessage, headerName string, headerRef *openapi3.HeaderRef) error {
+	if headerRef.Value == nil || headerRef.Value.Schema == nil {
+		c.diagnostics.drop(headerRef.Value, "header %s has no schema and is dropped", headerName)
+		return nil
+	}
+
+	fieldOrMessage, err := c.ConvertSchemaToProtoType(headerRef.Value.Schema, headerName, message)
+	if err != nil {
+		return err
+	}
+
+	switch v := fieldOrMessage.(type) {
+	case *protobuf.ProtoField:
+		if c.converterOption.ApiOption {
+			option := &protobuf.Option{
+				Name:  "api.header",
+				Value: fmt.Sprintf("%q", headerName),
+			}
+			v.Options = append(v.Options, option)
+			c.AddProtoImport(apiProtoFile)
+		}
+		if c.converterOption.OpenapiOption {
+			optionStr := utils.StructToOption(headerRef.Value, "     ")
+
+			schemaOption := &protobuf.Option{
+				Name:  openapiPropertyOption,
+				Value: optionStr,
+			}
+			v.Options = append(v.Options, schemaOption)
+			c.AddProtoImport(openapiProtoFile)
+		}
+		c.applyPresence(v, headerRef.Value.Schema, headerRef.Value.Required, isNullable(headerRef.Value.Schema))
+		c.addFieldIfNotExists(&message.Fields, v)
+	case *protobuf.ProtoMessage:
+		for _, field := range v.Fields {
+			if c.converterOption.ApiOption {
+				option := &protobuf.Option{
+					Name:  "api.header",
+					Value: fmt.Sprintf("%q", field.Name),
+				}
+				field.Options = append(field.Options, option)
+				c.AddProtoImport(apiProtoFile)
+			}
+			if c.converterOption.OpenapiOption {
+				optionStr := utils.StructToOption(headerRef.Value, "     ")
+
+				schemaOption := &protobuf.Option{
+					Name:  openapiPropertyOption,
+					Value: optionStr,
+				}
+				field.Options = append(field.Options, schemaOption)
+				c.AddProtoImport(openapiProtoFile)
+			}
+			c.addFieldIfNotExists(&message.Fields, field)
+		}
+		c.mergeDeclarations(message, v, headerRef.Value, "header "+headerName)
+	case *protobuf.ProtoEnum:
+		name := headerName
+		if c.converterOption.NamingOption {
+			name = utils.ToSnakeCase(name)
+		}
+		newField := &protobuf.ProtoField{
+			Name: name + "_field",
+			Type: v.Name,
+		}
+		if c.converterOption.ApiOption {
+			option := &protobuf.Option{
+				Name:  "api.header",
+				Value: fmt.Sprintf("%q", headerName),
+			}
+			newField.Options = append(newField.Options, option)
+			c.AddProtoImport(apiProtoFile)
+		}
+		if c.converterOption.OpenapiOption {
+			optionStr := utils.StructToOption(headerRef.Value, "     ")
+
+			schemaOption := &protobuf.Option{
+				Name:  openapiPropertyOption,
+				Value: optionStr,
+			}
+			newField.Options = append(newField.Options, schemaOption)
+			c.AddProtoImport(openapiProtoFile)
+		}
+		message.Enums = append(message.Enums, v)
+		message.Fields = append(message.Fields, newField)
+	case *protobuf.ProtoOneOf:
+		c.addNestedOneOfToParent(message, v)
+	}
+
+	return nil
+}
+
+// headerFragmentField declares the fragment message of a referenced header the first time it is
+// met and returns a field of the fragment type
+func (c *ProtoConverter) headerFragmentField(headerName string, headerRef *openapi3.HeaderRef) (*protobuf.ProtoField, error) {
+	name, ok := c.fragments[headerRef.Value]
+	if !ok {
+		name = c.refTypeName(headerRef.Ref, "Header")
+		fragment := &protobuf.ProtoMessage{Name: name}
+		c.addMessageToProto(fragment)
+		name = fragment.Name
+		c.fragments[headerRef.Value] = name
+		if err := c.addHeaderFields(fragment, headerName, headerRef); err != nil {
+			return nil, err
+		}
+	}
+	return &protobuf.ProtoField{
+		Name: c.applyNamingOption(headerName),
+		Type: name,
+	}, nil
+}
+
+// refTypeName names the declaration of a referenced component after the component, adding suffix
+// if the name is already declared
+func (c *ProtoConverter) refTypeName(ref, suffix string) string {
+	name := c.applyNamingOption(utils.ExtractMessageNameFromRef(ref))
+	if declared := c.declaredTypes(); declared[name] {
+		name = uniqueName(name+suffix, declared)
+	}
+	return name
+}
+
 // applyNamingOption applies naming convention based on the converter's naming option
 func (c *ProtoConverter) applyNamingOption(name string) string {
 	if c.converterOption.NamingOption {
@@ -1178,71 +1858,50 @@ func (c *ProtoConverter) addNestedEnumToParent(parentMessage *protobuf.ProtoMess
 	}
 }
 
-// mergeProtoMessage merges a ProtoMessage into the ProtoFile
+// addMessageToProto adds a top-level message to the ProtoFile. A message named like an existing
+// declaration is renamed unless it is identical to it, so it must be referenced by its name once added.
 func (c *ProtoConverter) addMessageToProto(message *protobuf.ProtoMessage) error {
-	var existingMessage *protobuf.ProtoMessage
-	for _, msg := range c.ProtoFile.Messages {
-		if msg.Name == message.Name {
-			existingMessage = msg
+	for _, existingMessage := range c.ProtoFile.Messages {
+		if existingMessage.Name == message.Name {
+			if existingMessage == message || protoMessageKey(existingMessage, false) == protoMessageKey(message, false) {
+				return nil
+			}
 			break
 		}
 	}
+	if declared := c.declaredTypes(); declared[message.Name] {
+		renameProtoMessage(message, uniqueName(message.Name, declared))
+	}
 
-	// merge message
-	if existingMessage != nil {
-		// merge Fields
-		fieldNames := make(map[string]struct{})
-		for _, field := range existingMessage.Fields {
-			fieldNames[field.Name] = struct{}{}
-		}
-		for _, newField := range message.Fields {
-			if _, exists := fieldNames[newField.Name]; !exists {
-				existingMessage.Fields = append(existingMessage.Fields, newField)
-			}
-		}
-
-		// merge Messages
-		messageNames := make(map[string]struct{})
-		for _, nestedMsg := range existingMessage.Messages {
-			messageNames[nestedMsg.Name] = struct{}{}
-		}
-		for _, newMessage := range message.Messages {
-			if _, exists := messageNames[newMessage.Name]; !exists {
-				existingMessage.Messages = append(existingMessage.Messages, newMessage)
-			}
-		}
-
-		// merge Enums
-		enumNames := make(map[string]struct{})
-		for _, enum := range existingMessage.Enums {
-			enumNames[enum.Name] = struct{}{}
-		}
-		for _, newEnum := range message.Enums {
-			if _, exists := enumNames[newEnum.Name]; !exists {
-				existingMessage.Enums = append(existingMessage.Enums, newEnum)
-			}
-		}
+	c.ProtoFile.Messages = append(c.ProtoFile.Messages, message)
+	c.recordSource(message.Name)
+	return nil
+}
 
-		// merge Options
-		optionNames := make(map[string]struct{})
-		for _, option := range existingMessage.Options {
-			optionNames[option.Name] = struct{}{}
-		}
-		for _, newOption := range message.Options {
-			if _, exists := optionNames[newOption.Name]; !exists {
-				existingMessage.Options = append(existingMessage.Options, newOption)
+// addEnumToProto adds an enum to the ProtoFile. An enum named like an existing declaration is
+// renamed unless it is identical to it, so it must be referenced by its name once added.
+func (c *ProtoConverter) addEnumToProto(enum *protobuf.ProtoEnum) {
+	for _, existingEnum := range c.ProtoFile.Enums {
+		if existingEnum.Name == enum.Name {
+			if existingEnum == enum || protoEnumKey(existingEnum) == protoEnumKey(enum) {
+				return
 			}
+			break
 		}
-	} else {
-		c.ProtoFile.Messages = append(c.ProtoFile.Messages, message)
+	}
+	if declared := c.declaredTypes(); declared[enum.Name] {
+		enum.Name = uniqueName(enum.Name, declared)
 	}
 
-	return nil
+	c.ProtoFile.Enums = append(c.ProtoFile.Enums, enum)
+	c.recordSource(enum.Name)
 }
 
-// addEnumToProto adds an enum to the ProtoFile
-func (c *ProtoConverter) addEnumToProto(enum *protobuf.ProtoEnum) {
-	c.ProtoFile.Enums = append(c.ProtoFile.Enums, enum)
+// recordSource remembers the external document a new top-level declaration comes from
+func (c *ProtoConverter) recordSource(name string) {
+	if c.currentSource != "" {
+		c.declSources[name] = c.currentSource
+	}
 }
 
 // AddProtoImport adds an import to the ProtoFile
@@ -1267,14 +1926,74 @@ func (c *ProtoConverter) addFieldIfNotExists(fields *[]*protobuf.ProtoField, fie
 	*fields = append(*fields, field)
 }
 
-// addMessageIfNotExists adds a message to Messages if it does not already exist
-func (c *ProtoConverter) addMessageIfNotExists(messages *[]*protobuf.ProtoMessage, nestedMessage *protobuf.ProtoMessage) {
-	for _, existingMessage := range *messages {
-		if existingMessage.Name == nestedMessage.Name {
+// addContentField adds a field converted from the content of a media type to message. A field
+// of several media types is added once, a field clashing with a differently typed one is dropped.
+func (c *ProtoConverter) addContentField(message *protobuf.ProtoMessage, field *protobuf.ProtoField, node interface{}, mediaType string) {
+	for _, existingField := range message.Fields {
+		if existingField.Name == field.Name {
+			if existingField.Type != field.Type || existingField.Repeated != field.Repeated {
+				c.diagnostics.drop(node, "field %s of %s clashes with a field of another media type and is dropped", field.Name, mediaType)
+			}
 			return
 		}
 	}
-	*messages = append(*messages, nestedMessage)
+	message.Fields = append(message.Fields, field)
+}
+
+// mergeDeclarations adds the oneofs, nested messages and enums of a message converted from a body,
+// parameter or header to message, dropping the ones clashing with different declarations
+func (c *ProtoConverter) mergeDeclarations(message, from *protobuf.ProtoMessage, node interface{}, source string) {
+	for _, oneOf := range from.OneOfs {
+		if existing := protoOneOfNamed(message.OneOfs, oneOf.Name); existing == nil {
+			message.OneOfs = append(message.OneOfs, oneOf)
+		} else if existing != oneOf {
+			c.diagnostics.drop(node, "oneof %s of %s clashes with another oneof and is dropped", oneOf.Name, source)
+		}
+	}
+	for _, nestedMessage := range from.Messages {
+		if existing := protoMessageNamed(message.Messages, nestedMessage.Name); existing == nil {
+			message.Messages = append(message.Messages, nestedMessage)
+		} else if protoMessageKey(existing, false) != protoMessageKey(nestedMessage, false) {
+			c.diagnostics.drop(node, "message %s of %s clashes with another message and is dropped", nestedMessage.Name, source)
+		}
+	}
+	for _, enum := range from.Enums {
+		if existing := protoEnumNamed(message.Enums, enum.Name); existing == nil {
+			message.Enums = append(message.Enums, enum)
+		} else if protoEnumKey(existing) != protoEnumKey(enum) {
+			c.diagnostics.drop(node, "enum %s of %s clashes with another enum and is dropped", enum.Name, source)
+		}
+	}
+}
+
+// protoOneOfNamed returns the oneof of the given name, or nil
+func protoOneOfNamed(oneOfs []*protobuf.ProtoOneOf, name string) *protobuf.ProtoOneOf {
+	for _, oneOf := range oneOfs {
+		if oneOf.Name == name {
+			return oneOf
+		}
+	}
+	return nil
+}
+
+// protoMessageNamed returns the message of the given name, or nil
+func protoMessageNamed(messages []*protobuf.ProtoMessage, name string) *protobuf.ProtoMessage {
+	for _, message := range messages {
+		if message.Name == name {
+			return message
+		}
+	}
+	return nil
+}
+
+// protoEnumNamed returns the enum of the given name, or nil
+func protoEnumNamed(enums []*protobuf.ProtoEnum, name string) *protobuf.ProtoEnum {
+	for _, enum := range enums {
+		if enum.Name == name {
+			return enum
+		}
+	}
+	return nil
 }
 
 // methodExistsInService checks if a method exists in a service
@@ -1302,9 +2021,15 @@ func (c *ProtoConverter) findOrCreateService(serviceName string) *protobuf.Proto
 	return newService
 }
 
-// addNestedOneOfToParent adds a nested oneOf to a parent message
+// addNestedOneOfToParent adds a nested oneOf to a parent message, unless handleOneOf already did
 func (c *ProtoConverter) addNestedOneOfToParent(parentMessage *protobuf.ProtoMessage, nestedOneOf *protobuf.ProtoOneOf) {
-	if parentMessage != nil && nestedOneOf != nil {
-		parentMessage.OneOfs = append(parentMessage.OneOfs, nestedOneOf)
+	if parentMessage == nil || nestedOneOf == nil {
+		return
+	}
+	for _, oneOf := range parentMessage.OneOfs {
+		if oneOf == nestedOneOf {
+			return
+		}
 	}
+	parentMessage.OneOfs = append(parentMessage.OneOfs, nestedOneOf)
 }
@@ -0,0 +1,420 @@
+package converter
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/hertz-contrib/swagger-generate/swagger2idl/protobuf"
+)
+
+// protoMessage returns the named top-level message of a ProtoFile
+func protoMessage(t *testing.T, file *protobuf.ProtoFile, name string) *protobuf.ProtoMessage {
+	t.Helper()
+	for _, message := range file.Messages {
+		if message.Name == name {
+			return message
+		}
+	}
+	t.Fatalf("message %s not found", name)
+	return nil
+}
+
+// protoFields describes the fields of a message or oneof as name and type
+func protoFields(fields []*protobuf.ProtoField) []string {
+	var described []string
+	for _, field := range fields {
+		described = append(described, field.Name+" "+field.Type)
+	}
+	return described
+}
+
+func TestProtoConverterUnionMembers(t *testing.T) {
+	tests := []struct {
+		name        string
+		union       string
+		wantMembers []string
+		wantNested  []string
+	}{
+		{
+			name: "mapped references",
+			union: `
+          oneOf:
+            - $ref: '#/components/schemas/Cat'
+            - $ref: '#/components/schemas/Dog'
+          discriminator:
+            propertyName: kind
+            mapping:
+              cat: '#/components/schemas/Cat'
+              dog: Dog`,
+			wantMembers: []string{"cat Cat", "dog Dog"},
+		},
+		{
+			name: "inline discriminated branches",
+			union: `
+          oneOf:
+            - type: object
+              properties:
+                kind: {type: string, enum: [cat]}
+            - type: string
+              enum: [a, b]
+          discriminator:
+            propertyName: kind`,
+			wantMembers: []string{"cat PetOption1", "PetOption2Enum_field PetOption2Enum"},
+			wantNested:  []string{"PetOption1"},
+		},
+		{
+			name: "nested oneOf branch",
+			union: `
+          oneOf:
+            - $ref: '#/components/schemas/Cat'
+            - oneOf:
+                - type: string
+                - type: integer`,
+			wantMembers: []string{"Cat Cat", "PetOption2_field PetOption2"},
+			wantNested:  []string{"PetOption2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := loadSpec(t, `openapi: 3.0.3
+info: {title: test, version: "1"}
+paths: {}
+components:
+  schemas:
+    Cat:
+      type: object
+      properties:
+        kind: {type: string}
+    Dog:
+      type: object
+      properties:
+        kind: {type: string}
+    Holder:
+      type: object
+      properties:
+        pet:`+tt.union+"\n")
+			c := NewProtoConverter(spec, &ConvertOption{})
+			if err := c.Convert(); err != nil {
+				t.Fatalf("Convert() error = %v", err)
+			}
+
+			holder := protoMessage(t, c.ProtoFile, "Holder")
+			if len(holder.OneOfs) != 1 {
+				t.Fatalf("oneofs = %d, want 1", len(holder.OneOfs))
+			}
+			if got := protoFields(holder.OneOfs[0].Fields); !equalStrings(got, tt.wantMembers) {
+				t.Errorf("members = %v, want %v", got, tt.wantMembers)
+			}
+			var nested []string
+			for _, message := range holder.Messages {
+				nested = append(nested, message.Name)
+			}
+			if !equalStrings(nested, tt.wantNested) {
+				t.Errorf("nested messages = %v, want %v", nested, tt.wantNested)
+			}
+		})
+	}
+}
+
+// equalStrings reports whether two string slices hold the same elements in the same order
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// protoOptions describes the options of a field as name=value
+func protoOptions(field *protobuf.ProtoField) []string {
+	var described []string
+	for _, option := range field.Options {
+		described = append(described, fmt.Sprintf("%s=%v", option.Name, option.Value))
+	}
+	return described
+}
+
+func TestProtoConverterParameterFragments(t *testing.T) {
+	tests := []struct {
+		name        string
+		message     string
+		wantFields  []string
+		wantOptions map[string][]string
+	}{
+		{
+			name:       "request",
+			message:    "ListUsersRequest",
+			wantFields: []string{"PagePageSizeParameters PagePageSizeParameters", "Q string", "XRequestId RequestId"},
+		},
+		{
+			name:       "request of another path",
+			message:    "ListItemsRequest",
+			wantFields: []string{"PagePageSizeParameters PagePageSizeParameters", "XRequestId RequestId"},
+		},
+		{
+			name:        "grouped fragment",
+			message:     "PagePageSizeParameters",
+			wantFields:  []string{"Page int64", "PageSize int64"},
+			wantOptions: map[string][]string{"Page": {`api.query="page"`}, "PageSize": {`api.query="page_size"`}},
+		},
+		{
+			name:        "single fragment",
+			message:     "RequestId",
+			wantFields:  []string{"XRequestId string"},
+			wantOptions: map[string][]string{"XRequestId": {`api.header="X-Request-Id"`}},
+		},
+	}
+	c := NewProtoConverter(loadSpec(t, fragmentSpec), &ConvertOption{
+		ApiOption:      true,
+		NamingOption:   true,
+		FragmentOption: FragmentReference,
+	})
+	if err := c.Convert(); err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := protoMessage(t, c.ProtoFile, tt.message)
+			if got := protoFields(message.Fields); !equalStrings(got, tt.wantFields) {
+				t.Errorf("fields = %v, want %v", got, tt.wantFields)
+			}
+			for _, field := range message.Fields {
+				if want, ok := tt.wantOptions[field.Name]; ok && !equalStrings(protoOptions(field), want) {
+					t.Errorf("options of %s = %v, want %v", field.Name, protoOptions(field), want)
+				}
+			}
+		})
+	}
+}
+
+func TestProtoConverterSharedBodies(t *testing.T) {
+	c := NewProtoConverter(loadSpec(t, sharedBodySpec), &ConvertOption{NamingOption: true})
+	if err := c.Convert(); err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+
+	tests := []struct {
+		message    string
+		wantFields []string
+	}{
+		{message: "PetBody", wantFields: []string{"Name string"}},
+		{message: "UpdatePetRequest", wantFields: []string{"Id string", "PetBody PetBody"}},
+		{message: "UpdatePetResponse", wantFields: []string{"response_200 PetResponse"}},
+	}
+	for _, tt := range tests {
+		if got := protoFields(protoMessage(t, c.ProtoFile, tt.message).Fields); !equalStrings(got, tt.wantFields) {
+			t.Errorf("fields of %s = %v, want %v", tt.message, got, tt.wantFields)
+		}
+	}
+
+	methods := make(map[string]string)
+	for _, method := range c.ProtoFile.Services[0].Methods {
+		methods[method.Name] = method.Input + " -> " + method.Output
+	}
+	if want := "PetBody -> PetResponse"; methods["CreatePet"] != want {
+		t.Errorf("CreatePet = %s, want %s", methods["CreatePet"], want)
+	}
+}
+
+func TestProtoConverterOneOfComponents(t *testing.T) {
+	tests := []struct {
+		name        string
+		component   string
+		wantMembers []string
+	}{
+		{
+			name: "references",
+			component: `
+      oneOf:
+        - $ref: '#/components/schemas/Cat'
+        - $ref: '#/components/schemas/Dog'`,
+			wantMembers: []string{"Cat Cat", "Dog Dog"},
+		},
+		{
+			name: "discriminated references",
+			component: `
+      oneOf:
+        - $ref: '#/components/schemas/Cat'
+        - $ref: '#/components/schemas/Dog'
+      discriminator:
+        propertyName: kind
+        mapping:
+          cat: '#/components/schemas/Cat'
+          dog: '#/components/schemas/Dog'`,
+			wantMembers: []string{"cat Cat", "dog Dog"},
+		},
+		{
+			name: "scalars",
+			component: `
+      oneOf:
+        - type: string
+        - type: integer`,
+			wantMembers: []string{"PetOption1 string", "PetOption2 int64"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := loadSpec(t, `openapi: 3.0.3
+info: {title: test, version: "1"}
+paths: {}
+components:
+  schemas:
+    Cat:
+      type: object
+      properties:
+        kind: {type: string}
+    Dog:
+      type: object
+      properties:
+        kind: {type: string}
+    Pet:`+tt.component+"\n")
+			c := NewProtoConverter(spec, &ConvertOption{})
+			if err := c.Convert(); err != nil {
+				t.Fatalf("Convert() error = %v", err)
+			}
+
+			pet := protoMessage(t, c.ProtoFile, "Pet")
+			if len(pet.Fields) != 0 || len(pet.OneOfs) != 1 {
+				t.Fatalf("Pet has %d fields and %d oneofs, want a single oneof", len(pet.Fields), len(pet.OneOfs))
+			}
+			if pet.OneOfs[0].Name != "PetOneOf" {
+				t.Errorf("oneof name = %s, want PetOneOf", pet.OneOfs[0].Name)
+			}
+			if got := protoFields(pet.OneOfs[0].Fields); !equalStrings(got, tt.wantMembers) {
+				t.Errorf("members = %v, want %v", got, tt.wantMembers)
+			}
+		})
+	}
+}
+
+// allOfSpec declares a schema composed of a referenced base and an inline part redeclaring a
+// property of the base with another type
+const allOfSpec = `openapi: 3.0.3
+info: {title: test, version: "1"}
+paths: {}
+components:
+  schemas:
+    Base:
+      type: object
+      required: [id]
+      properties:
+        id: {type: string}
+        created: {type: integer}
+    Pet:
+      allOf:
+        - $ref: '#/components/schemas/Base'
+        - type: object
+          required: [name]
+          properties:
+            name: {type: string}
+            created: {type: string}
+`
+
+func TestProtoConverterAllOf(t *testing.T) {
+	tests := []struct {
+		name            string
+		option          string
+		wantFields      []string
+		wantDiagnostics int
+	}{
+		{
+			name:            "flatten",
+			option:          AllOfFlatten,
+			wantFields:      []string{"created int64", "id string", "name string"},
+			wantDiagnostics: 1,
+		},
+		{
+			name:       "embed",
+			option:     AllOfEmbed,
+			wantFields: []string{"Base Base", "created string", "name string"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewProtoConverter(loadSpec(t, allOfSpec), &ConvertOption{AllOfOption: tt.option})
+			if err := c.Convert(); err != nil {
+				t.Fatalf("Convert() error = %v", err)
+			}
+
+			if got := protoFields(protoMessage(t, c.ProtoFile, "Pet").Fields); !equalStrings(got, tt.wantFields) {
+				t.Errorf("fields = %v, want %v", got, tt.wantFields)
+			}
+			for _, message := range c.ProtoFile.Messages {
+				if strings.HasSuffix(message.Name, "AllOf") {
+					t.Errorf("message %s wraps the allOf parts", message.Name)
+				}
+			}
+			if len(c.Diagnostics()) != tt.wantDiagnostics {
+				t.Errorf("Diagnostics() = %v, want %d", c.Diagnostics(), tt.wantDiagnostics)
+			}
+		})
+	}
+}
+
+func TestProtoConverterContainers(t *testing.T) {
+	c := NewProtoConverter(loadSpec(t, `openapi: 3.0.3
+info: {title: test, version: "1"}
+paths: {}
+components:
+  schemas:
+    Counts:
+      type: object
+      additionalProperties: {type: integer, format: int32}
+    Item:
+      type: object
+      properties:
+        matrix:
+          type: array
+          items:
+            type: array
+            items: {type: integer}
+        groups:
+          type: object
+          additionalProperties:
+            type: array
+            items: {type: string}
+        scores:
+          type: object
+          additionalProperties: {type: number, format: float}
+`), &ConvertOption{})
+	if err := c.Convert(); err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+
+	tests := []struct {
+		message    string
+		wantFields []string
+	}{
+		// A pure map keeps its value type
+		{message: "Counts", wantFields: []string{"additional_properties map<string, int32>"}},
+		// Nested arrays and array values are wrapped, proto3 cannot nest repeated fields
+		{message: "Item", wantFields: []string{"groups map<string, StringList>", "matrix Int64List", "scores map<string, float>"}},
+	}
+	for _, tt := range tests {
+		if got := protoFields(protoMessage(t, c.ProtoFile, tt.message).Fields); !equalStrings(got, tt.wantFields) {
+			t.Errorf("fields of %s = %v, want %v", tt.message, got, tt.wantFields)
+		}
+	}
+
+	item := protoMessage(t, c.ProtoFile, "Item")
+	var wrappers []string
+	for _, message := range item.Messages {
+		if len(message.Fields) != 1 || !message.Fields[0].Repeated {
+			t.Errorf("wrapper %s = %v, want a single repeated field", message.Name, protoFields(message.Fields))
+		}
+		wrappers = append(wrappers, message.Name+" "+protoFields(message.Fields)[0])
+	}
+	if want := []string{"StringList items string", "Int64List items int64"}; !equalStrings(wrappers, want) {
+		t.Errorf("wrappers of Item = %v, want %v", wrappers, want)
+	}
+	for _, field := range item.Fields {
+		if field.Name == "matrix" && !field.Repeated {
+			t.Errorf("matrix is not repeated")
+		}
+	}
+}
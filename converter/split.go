@@ -0,0 +1,595 @@
+package converter
+
+import (
+	"fmt"
+	pathpkg "path"
+	"strconv"
+	"strings"
+
+	"github.com/hertz-contrib/swagger-generate/swagger2idl/protobuf"
+	"github.com/hertz-contrib/swagger-generate/swagger2idl/thrift"
+	"github.com/hertz-contrib/swagger-generate/swagger2idl/utils"
+)
+
+// commonFileName is the name of the file holding the declarations shared by several services
+const commonFileName = "common"
+
+// SplitByService partitions the converted ProtoFile into one file per service and a common file.
+// Each file gets its own package below the package of the ProtoFile, imports and qualified type
+// names between the files are added by ProtoGenerate.GenerateFiles.
+func (c *ProtoConverter) SplitByService() []*protobuf.ProtoFile {
+	deps := make(map[string][]string)
+	for _, message := range c.ProtoFile.Messages {
+		deps[message.Name] = protoMessageDeps(message, nil)
+	}
+	for _, enum := range c.ProtoFile.Enums {
+		deps[enum.Name] = nil
+	}
+
+	serviceFiles := make([]string, 0, len(c.ProtoFile.Services))
+	roots := make([][]string, 0, len(c.ProtoFile.Services))
+	for _, service := range c.ProtoFile.Services {
+		serviceFiles = append(serviceFiles, utils.ToSnakeCase(service.Name))
+		var names []string
+		for _, method := range service.Methods {
+			names = append(names, utils.TypeNames(method.Input)...)
+			names = append(names, utils.TypeNames(method.Output)...)
+		}
+		roots = append(roots, names)
+	}
+	common := commonName(serviceFiles, c.ProtoFile.Imports)
+	owners := assignTypeFiles(deps, roots, c.sharedTypes, serviceFiles, common)
+	return c.splitFiles(owners, serviceFiles, append([]string{common}, serviceFiles...))
+}
+
+// SplitBySource partitions the converted ProtoFile by the documents of a multi-document spec.
+// Declarations of schemas referenced from another document go to a file mirroring the path of
+// that document, everything else goes to the file named rootName.
+func (c *ProtoConverter) SplitBySource(rootName string) []*protobuf.ProtoFile {
+	owners := make(map[string]string)
+	serviceFiles := make([]string, 0, len(c.ProtoFile.Services))
+	order := []string{rootName}
+	for _, message := range c.ProtoFile.Messages {
+		owners[message.Name] = c.sourceFileName(message.Name, rootName)
+	}
+	for _, enum := range c.ProtoFile.Enums {
+		owners[enum.Name] = c.sourceFileName(enum.Name, rootName)
+	}
+	for range c.ProtoFile.Services {
+		serviceFiles = append(serviceFiles, rootName)
+	}
+	order = append(order, sourceFileNames(c.declSources)...)
+	return c.splitFiles(owners, serviceFiles, order)
+}
+
+// sourceFileName returns the file of a declaration when splitting by source document
+func (c *ProtoConverter) sourceFileName(name, rootName string) string {
+	if source, ok := c.declSources[name]; ok {
+		return sourceFileName(source)
+	}
+	return rootName
+}
+
+// splitFiles distributes the declarations over the files named by owners and the services over
+// serviceFiles. The files are returned in the given order, the first file keeps the document option.
+func (c *ProtoConverter) splitFiles(owners map[string]string, serviceFiles, order []string) []*protobuf.ProtoFile {
+	files := make(map[string]*protobuf.ProtoFile)
+	fileFor := func(name string) *protobuf.ProtoFile {
+		if file, ok := files[name]; ok {
+			return file
+		}
+		file := c.newSplitFile(name)
+		files[name] = file
+		return file
+	}
+	for _, enum := range c.ProtoFile.Enums {
+		file := fileFor(owners[enum.Name])
+		file.Enums = append(file.Enums, enum)
+	}
+	for _, message := range c.ProtoFile.Messages {
+		file := fileFor(owners[message.Name])
+		file.Messages = append(file.Messages, message)
+	}
+	for i, service := range c.ProtoFile.Services {
+		file := fileFor(serviceFiles[i])
+		file.Services = append(file.Services, service)
+	}
+
+	result := make([]*protobuf.ProtoFile, 0, len(files))
+	for _, name := range order {
+		if file, ok := files[name]; ok {
+			result = append(result, file)
+			delete(files, name)
+		}
+	}
+
+	// The document option describes the whole API, keep it in the first file only
+	for i := 1; i < len(result); i++ {
+		file := result[i]
+		options := file.Options[:0]
+		for _, option := range file.Options {
+			if option.Name != openapiDocumentOption {
+				options = append(options, option)
+			}
+		}
+		file.Options = options
+	}
+	c.splitImports(result)
+	return result
+}
+
+// splitImports gives every split file the imports of the ProtoFile that declare a type or an option
+// the file uses. Imports that declare nothing known to the converter are kept in every file.
+func (c *ProtoConverter) splitImports(files []*protobuf.ProtoFile) {
+	typeImports := map[string]string{EmptyMessage: EmptyProtoFile}
+	for _, wrapper := range protoScalarWrappers {
+		typeImports[wrapper] = wrappersProtoFile
+	}
+	for _, mapping := range c.typeMappings {
+		if mapping.Proto != "" && mapping.ProtoImport != "" {
+			typeImports[mapping.Proto] = mapping.ProtoImport
+		}
+	}
+	optionImports := map[string]string{"api": apiProtoFile, "openapi": openapiProtoFile}
+
+	known := make(map[string]bool)
+	for _, importFile := range typeImports {
+		known[importFile] = true
+	}
+	for _, importFile := range optionImports {
+		known[importFile] = true
+	}
+	for _, file := range files {
+		typeNames, optionPackages := protoFileUses(file)
+		used := make(map[string]bool)
+		for name := range typeNames {
+			used[typeImports[name]] = true
+		}
+		for name := range optionPackages {
+			used[optionImports[name]] = true
+		}
+		file.Imports = []string{}
+		for _, importFile := range c.ProtoFile.Imports {
+			if used[importFile] || !known[importFile] {
+				file.Imports = append(file.Imports, importFile)
+			}
+		}
+	}
+}
+
+// protoFileUses returns the type names and the packages of the option names used in a ProtoFile
+func protoFileUses(file *protobuf.ProtoFile) (typeNames, optionPackages map[string]bool) {
+	typeNames = make(map[string]bool)
+	optionPackages = make(map[string]bool)
+	addOptions := func(options []*protobuf.Option) {
+		for _, option := range options {
+			pkg, _, _ := strings.Cut(option.Name, ".")
+			optionPackages[pkg] = true
+		}
+	}
+	addFields := func(fields []*protobuf.ProtoField) {
+		for _, field := range fields {
+			for _, name := range utils.TypeNames(field.Type) {
+				typeNames[name] = true
+			}
+			addOptions(field.Options)
+		}
+	}
+	var addMessage func(message *protobuf.ProtoMessage)
+	addMessage = func(message *protobuf.ProtoMessage) {
+		addOptions(message.Options)
+		addFields(message.Fields)
+		for _, oneOf := range message.OneOfs {
+			addOptions(oneOf.Options)
+			addFields(oneOf.Fields)
+		}
+		for _, enum := range message.Enums {
+			addOptions(enum.Options)
+		}
+		for _, nestedMessage := range message.Messages {
+			addMessage(nestedMessage)
+		}
+	}
+
+	addOptions(file.Options)
+	for _, enum := range file.Enums {
+		addOptions(enum.Options)
+	}
+	for _, message := range file.Messages {
+		addMessage(message)
+	}
+	for _, service := range file.Services {
+		addOptions(service.Options)
+		for _, method := range service.Methods {
+			typeNames[method.Input] = true
+			typeNames[method.Output] = true
+			addOptions(method.Options)
+		}
+	}
+	return typeNames, optionPackages
+}
+
+// newSplitFile creates an empty file of the split output that inherits the options of the ProtoFile
+func (c *ProtoConverter) newSplitFile(name string) *protobuf.ProtoFile {
+	file := &protobuf.ProtoFile{
+		FileName:    name + ".proto",
+		PackageName: c.ProtoFile.PackageName + "." + strings.ReplaceAll(name, "/", "."),
+		Messages:    []*protobuf.ProtoMessage{},
+		Services:    []*protobuf.ProtoService{},
+		Enums:       []*protobuf.ProtoEnum{},
+		Imports:     []string{},
+		Options:     []*protobuf.Option{},
+	}
+	for _, option := range c.ProtoFile.Options {
+		if option.Name == "go_package" {
+			option = &protobuf.Option{Name: option.Name, Value: splitGoPackage(option.Value, name)}
+		}
+		file.Options = append(file.Options, option)
+	}
+	return file
+}
+
+// splitGoPackage moves a quoted go_package value into the sub package of the given file
+func splitGoPackage(value interface{}, name string) interface{} {
+	str, ok := value.(string)
+	if !ok {
+		return value
+	}
+	goPackage, err := strconv.Unquote(str)
+	if err != nil {
+		return value
+	}
+	if path, _, found := strings.Cut(goPackage, ";"); found {
+		return fmt.Sprintf("%q", path+"/"+name+";"+pathpkg.Base(name))
+	}
+	return fmt.Sprintf("%q", goPackage+"/"+name)
+}
+
+// protoMessageDeps returns the type names referenced by the fields of a message and its nested messages
+func protoMessageDeps(message *protobuf.ProtoMessage, names []string) []string {
+	for _, field := range message.Fields {
+		names = append(names, utils.TypeNames(field.Type)...)
+	}
+	for _, oneOf := range message.OneOfs {
+		for _, field := range oneOf.Fields {
+			names = append(names, utils.TypeNames(field.Type)...)
+		}
+	}
+	for _, nestedMessage := range message.Messages {
+		names = protoMessageDeps(nestedMessage, names)
+	}
+	return names
+}
+
+// SplitByService partitions the converted ThriftFile into one file per service and a common file.
+// Each file gets its own namespace, includes and qualified type names between the files are
+// added by ThriftGenerate.GenerateFiles.
+func (c *ThriftConverter) SplitByService() []*thrift.ThriftFile {
+	deps := make(map[string][]string)
+	for _, message := range c.ThriftFile.Structs {
+		deps[message.Name] = thriftFieldDeps(message.Fields)
+	}
+	for _, union := range c.ThriftFile.Unions {
+		deps[union.Name] = thriftFieldDeps(union.Fields)
+	}
+	for _, typedef := range c.ThriftFile.Typedefs {
+		deps[typedef.Name] = utils.TypeNames(typedef.Type)
+	}
+	for _, enum := range c.ThriftFile.Enums {
+		deps[enum.Name] = nil
+	}
+
+	serviceFiles := make([]string, 0, len(c.ThriftFile.Services))
+	roots := make([][]string, 0, len(c.ThriftFile.Services))
+	for _, service := range c.ThriftFile.Services {
+		serviceFiles = append(serviceFiles, utils.ToSnakeCase(service.Name))
+		var names []string
+		for _, method := range service.Methods {
+			for _, input := range method.Input {
+				names = append(names, utils.TypeNames(input)...)
+			}
+			names = append(names, utils.TypeNames(method.Output)...)
+		}
+		roots = append(roots, names)
+	}
+	common := commonName(serviceFiles, c.ThriftFile.Includes)
+	owners := assignTypeFiles(deps, roots, c.sharedTypes, serviceFiles, common)
+	return c.splitFiles(owners, serviceFiles, common, append([]string{common}, serviceFiles...))
+}
+
+// SplitBySource partitions the converted ThriftFile by the documents of a multi-document spec.
+// Declarations of schemas referenced from another document go to a file mirroring the path of
+// that document, everything else goes to the file named rootName.
+func (c *ThriftConverter) SplitBySource(rootName string) []*thrift.ThriftFile {
+	owners := make(map[string]string)
+	serviceFiles := make([]string, 0, len(c.ThriftFile.Services))
+	order := []string{rootName}
+	for _, message := range c.ThriftFile.Structs {
+		owners[message.Name] = c.sourceFileName(message.Name, rootName)
+	}
+	for _, union := range c.ThriftFile.Unions {
+		owners[union.Name] = c.sourceFileName(union.Name, rootName)
+	}
+	for _, typedef := range c.ThriftFile.Typedefs {
+		owners[typedef.Name] = c.sourceFileName(typedef.Name, rootName)
+	}
+	for _, enum := range c.ThriftFile.Enums {
+		owners[enum.Name] = c.sourceFileName(enum.Name, rootName)
+	}
+	for range c.ThriftFile.Services {
+		serviceFiles = append(serviceFiles, rootName)
+	}
+	order = append(order, sourceFileNames(c.declSources)...)
+	return c.splitFiles(owners, serviceFiles, rootName, order)
+}
+
+// sourceFileName returns the file of a declaration when splitting by source document
+func (c *ThriftConverter) sourceFileName(name, rootName string) string {
+	if source, ok := c.declSources[name]; ok {
+		return sourceFileName(source)
+	}
+	return rootName
+}
+
+// splitFiles distributes the declarations over the files named by owners, the constants to the
+// constants file and the services over serviceFiles. The files are returned in the given order.
+func (c *ThriftConverter) splitFiles(owners map[string]string, serviceFiles []string, constants string, order []string) []*thrift.ThriftFile {
+	files := make(map[string]*thrift.ThriftFile)
+	fileFor := func(name string) *thrift.ThriftFile {
+		if file, ok := files[name]; ok {
+			return file
+		}
+		file := c.newSplitFile(name)
+		files[name] = file
+		return file
+	}
+	for _, enum := range c.ThriftFile.Enums {
+		file := fileFor(owners[enum.Name])
+		file.Enums = append(file.Enums, enum)
+	}
+	for _, constant := range c.ThriftFile.Constants {
+		file := fileFor(constants)
+		file.Constants = append(file.Constants, constant)
+	}
+	for _, typedef := range c.ThriftFile.Typedefs {
+		file := fileFor(owners[typedef.Name])
+		file.Typedefs = append(file.Typedefs, typedef)
+	}
+	for _, message := range c.ThriftFile.Structs {
+		file := fileFor(owners[message.Name])
+		file.Structs = append(file.Structs, message)
+	}
+	for _, union := range c.ThriftFile.Unions {
+		file := fileFor(owners[union.Name])
+		file.Unions = append(file.Unions, union)
+	}
+	for i, service := range c.ThriftFile.Services {
+		file := fileFor(serviceFiles[i])
+		file.Services = append(file.Services, service)
+	}
+
+	result := make([]*thrift.ThriftFile, 0, len(files))
+	for _, name := range order {
+		if file, ok := files[name]; ok {
+			result = append(result, file)
+			delete(files, name)
+		}
+	}
+	c.splitIncludes(result)
+	return result
+}
+
+// splitIncludes gives every split file the includes of the ThriftFile that declare a type or an
+// annotation the file uses. Includes that declare nothing known to the converter are kept in
+// every file.
+func (c *ThriftConverter) splitIncludes(files []*thrift.ThriftFile) {
+	typeIncludes := make(map[string]string)
+	for _, mapping := range c.typeMappings {
+		if mapping.Thrift != "" && mapping.ThriftInclude != "" {
+			typeIncludes[mapping.Thrift] = mapping.ThriftInclude
+		}
+	}
+	optionIncludes := map[string]string{"openapi": openapiThriftFile}
+
+	known := make(map[string]bool)
+	for _, include := range typeIncludes {
+		known[include] = true
+	}
+	for _, include := range optionIncludes {
+		known[include] = true
+	}
+	for _, file := range files {
+		typeNames, optionPackages := thriftFileUses(file)
+		used := make(map[string]bool)
+		for name := range typeNames {
+			used[typeIncludes[name]] = true
+		}
+		for name := range optionPackages {
+			used[optionIncludes[name]] = true
+		}
+		file.Includes = []string{}
+		for _, include := range c.ThriftFile.Includes {
+			if used[include] || !known[include] {
+				file.Includes = append(file.Includes, include)
+			}
+		}
+	}
+}
+
+// thriftFileUses returns the type names and the packages of the annotation names used in a ThriftFile
+func thriftFileUses(file *thrift.ThriftFile) (typeNames, optionPackages map[string]bool) {
+	typeNames = make(map[string]bool)
+	optionPackages = make(map[string]bool)
+	addType := func(typeStr string) {
+		for _, name := range utils.TypeNames(typeStr) {
+			typeNames[name] = true
+		}
+	}
+	addOptions := func(options []*thrift.Option) {
+		for _, option := range options {
+			pkg, _, _ := strings.Cut(option.Name, ".")
+			optionPackages[pkg] = true
+		}
+	}
+	addFields := func(fields []*thrift.ThriftField) {
+		for _, field := range fields {
+			addType(field.Type)
+			addOptions(field.Options)
+		}
+	}
+
+	for _, typedef := range file.Typedefs {
+		addType(typedef.Type)
+		addOptions(typedef.Options)
+	}
+	for _, message := range file.Structs {
+		addFields(message.Fields)
+		addOptions(message.Options)
+	}
+	for _, union := range file.Unions {
+		addFields(union.Fields)
+		addOptions(union.Options)
+	}
+	for _, enum := range file.Enums {
+		addOptions(enum.Options)
+	}
+	for _, constant := range file.Constants {
+		addType(constant.Type)
+	}
+	for _, service := range file.Services {
+		addOptions(service.Options)
+		for _, method := range service.Methods {
+			for _, input := range method.Input {
+				addType(input)
+			}
+			addType(method.Output)
+			addOptions(method.Options)
+		}
+	}
+	return typeNames, optionPackages
+}
+
+// newSplitFile creates an empty file of the split output that inherits the namespaces of the ThriftFile
+func (c *ThriftConverter) newSplitFile(name string) *thrift.ThriftFile {
+	file := &thrift.ThriftFile{
+		FileName:  name + ".thrift",
+		Namespace: map[string]string{},
+		Includes:  []string{},
+		Typedefs:  []*thrift.ThriftTypedef{},
+		Structs:   []*thrift.ThriftStruct{},
+		Enums:     []*thrift.ThriftEnum{},
+		Constants: []*thrift.ThriftConstant{},
+		Services:  []*thrift.ThriftService{},
+	}
+	suffix := strings.ReplaceAll(name, "/", ".")
+	for language, namespace := range c.ThriftFile.Namespace {
+		if unquoted, err := strconv.Unquote(namespace); err == nil {
+			file.Namespace[language] = fmt.Sprintf("%q", unquoted+"."+suffix)
+		} else {
+			file.Namespace[language] = namespace + "." + suffix
+		}
+	}
+	if len(file.Namespace) == 0 {
+		file.Namespace["go"] = utils.GetPackageName(c.spec) + "." + suffix
+	}
+	return file
+}
+
+// thriftFieldDeps returns the type names referenced by a list of fields
+func thriftFieldDeps(fields []*thrift.ThriftField) []string {
+	var names []string
+	for _, field := range fields {
+		names = append(names, utils.TypeNames(field.Type)...)
+	}
+	return names
+}
+
+// sourceFileName returns the output file name of a source document: its path without extension,
+// with every path segment converted to snake case
+func sourceFileName(source string) string {
+	source = strings.TrimSuffix(source, pathpkg.Ext(source))
+	var segments []string
+	for _, segment := range strings.Split(source, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			continue
+		}
+		segments = append(segments, utils.ToSnakeCase(segment))
+	}
+	return strings.Join(segments, "/")
+}
+
+// sourceFileNames returns the sorted output file names of the given source documents
+func sourceFileNames(declSources map[string]string) []string {
+	names := make(map[string]bool)
+	for _, source := range declSources {
+		names[sourceFileName(source)] = true
+	}
+	return utils.SortedKeys(names)
+}
+
+// commonName returns the name of the common file, which must differ from the service files and
+// from the imported files
+func commonName(serviceFiles, imports []string) string {
+	taken := append([]string{}, serviceFiles...)
+	for _, importFile := range imports {
+		taken = append(taken, strings.TrimSuffix(importFile, pathpkg.Ext(importFile)))
+	}
+	name := commonFileName
+	for utils.Contains(taken, name) {
+		name += "_types"
+	}
+	return name
+}
+
+// assignTypeFiles decides the file of every declaration. A declaration that is reachable from the
+// methods of a single service goes to the file of that service, declarations produced by the
+// component schemas or shared by several services go to the common file.
+func assignTypeFiles(deps map[string][]string, roots [][]string, shared map[string]bool, serviceFiles []string, common string) map[string]string {
+	users := make(map[string]map[string]bool)
+	for i, names := range roots {
+		visited := make(map[string]bool)
+		queue := append([]string{}, names...)
+		for len(queue) > 0 {
+			name := queue[0]
+			queue = queue[1:]
+			// Nested types are referenced as Parent.Child, they belong to the parent declaration
+			name, _, _ = strings.Cut(name, ".")
+			if _, ok := deps[name]; !ok || visited[name] {
+				continue
+			}
+			visited[name] = true
+			if users[name] == nil {
+				users[name] = make(map[string]bool)
+			}
+			users[name][serviceFiles[i]] = true
+			queue = append(queue, deps[name]...)
+		}
+	}
+
+	owners := make(map[string]string, len(deps))
+	var queue []string
+	for name := range deps {
+		owners[name] = common
+		if shared[name] || len(users[name]) != 1 {
+			queue = append(queue, name)
+			continue
+		}
+		for file := range users[name] {
+			owners[name] = file
+		}
+	}
+
+	// Everything used by the common file must be common too, the common file never imports a service file
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		for _, dep := range deps[name] {
+			dep, _, _ = strings.Cut(dep, ".")
+			if owner, ok := owners[dep]; ok && owner != common {
+				owners[dep] = common
+				queue = append(queue, dep)
+			}
+		}
+	}
+	return owners
+}
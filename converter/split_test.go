@@ -0,0 +1,247 @@
+package converter
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/hertz-contrib/swagger-generate/swagger2idl/parser"
+)
+
+func TestSourceFileName(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{source: "user.yaml", want: "user"},
+		{source: "./models/user.yaml", want: "models/user"},
+		{source: "../shared/OrderItem.json", want: "shared/order_item"},
+		{source: "models/pet-store.yml", want: "models/pet_store"},
+	}
+	for _, tt := range tests {
+		if got := sourceFileName(tt.source); got != tt.want {
+			t.Errorf("sourceFileName(%q) = %q, want %q", tt.source, got, tt.want)
+		}
+	}
+}
+
+func TestCommonName(t *testing.T) {
+	tests := []struct {
+		name         string
+		serviceFiles []string
+		imports      []string
+		want         string
+	}{
+		{name: "free", serviceFiles: []string{"pet", "store"}, want: "common"},
+		{name: "service file", serviceFiles: []string{"common"}, want: "common_types"},
+		{name: "imported file", imports: []string{"common.thrift"}, want: "common_types"},
+		{name: "both", serviceFiles: []string{"common"}, imports: []string{"common_types.proto"}, want: "common_types_types"},
+		{name: "nested import", imports: []string{"google/type/common.proto"}, want: "common"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := commonName(tt.serviceFiles, tt.imports); got != tt.want {
+				t.Errorf("commonName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitGoPackage(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		name  string
+		want  interface{}
+	}{
+		{value: `"example.com/api"`, name: "pet", want: `"example.com/api/pet"`},
+		{value: `"example.com/api;api"`, name: "models/user", want: `"example.com/api/models/user;user"`},
+		{value: "unquoted", name: "pet", want: "unquoted"},
+		{value: 1, name: "pet", want: 1},
+	}
+	for _, tt := range tests {
+		if got := splitGoPackage(tt.value, tt.name); got != tt.want {
+			t.Errorf("splitGoPackage(%v, %q) = %v, want %v", tt.value, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAssignTypeFiles(t *testing.T) {
+	tests := []struct {
+		name   string
+		deps   map[string][]string
+		roots  [][]string
+		shared map[string]bool
+		want   map[string]string
+	}{
+		{
+			name:  "used by a single service",
+			deps:  map[string][]string{"PetRequest": {"Filter"}, "Filter": nil},
+			roots: [][]string{{"PetRequest"}, nil},
+			want:  map[string]string{"PetRequest": "pet", "Filter": "pet"},
+		},
+		{
+			name:  "used by several services",
+			deps:  map[string][]string{"PetRequest": {"Page"}, "OrderRequest": {"Page"}, "Page": nil},
+			roots: [][]string{{"PetRequest"}, {"OrderRequest"}},
+			want:  map[string]string{"PetRequest": "pet", "OrderRequest": "store", "Page": "common"},
+		},
+		{
+			name:   "dependencies of shared types",
+			deps:   map[string][]string{"PetRequest": {"Pet"}, "Pet": {"Pet.Owner", "Tag"}, "Tag": nil},
+			roots:  [][]string{{"PetRequest"}, nil},
+			shared: map[string]bool{"Pet": true},
+			want:   map[string]string{"PetRequest": "pet", "Pet": "common", "Tag": "common"},
+		},
+		{
+			name:  "unused",
+			deps:  map[string][]string{"Orphan": nil},
+			roots: [][]string{nil, nil},
+			want:  map[string]string{"Orphan": "common"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := assignTypeFiles(tt.deps, tt.roots, tt.shared, []string{"pet", "store"}, "common")
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("assignTypeFiles() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// splitSpec declares two services sharing a component schema, one of them using a mapped type
+const splitSpec = `openapi: 3.0.3
+info: {title: shop, version: "1"}
+tags: [{name: pet}, {name: store}]
+paths:
+  /pets:
+    get:
+      tags: [pet]
+      operationId: getPet
+      responses:
+        "200": {description: ok, content: {application/json: {schema: {$ref: '#/components/schemas/Pet'}}}}
+  /orders:
+    get:
+      tags: [store]
+      operationId: getOrder
+      responses:
+        "200": {description: ok, content: {application/json: {schema: {$ref: '#/components/schemas/Order'}}}}
+components:
+  schemas:
+    Pet:
+      type: object
+      properties:
+        price: {type: string, format: decimal}
+    Order:
+      type: object
+      properties:
+        pet: {$ref: '#/components/schemas/Pet'}
+`
+
+// splitMappings maps decimal strings onto types declared in other files
+var splitMappings = []parser.TypeMapping{{
+	Type:          "string",
+	Format:        "decimal",
+	Proto:         "google.type.Decimal",
+	ProtoImport:   "google/type/decimal.proto",
+	Thrift:        "money.Decimal",
+	ThriftInclude: "money.thrift",
+}}
+
+func TestProtoSplitByService(t *testing.T) {
+	c := NewProtoConverter(loadSpec(t, splitSpec), &ConvertOption{NamingOption: true, ApiOption: true, TypeMappings: splitMappings})
+	if err := c.Convert(); err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+
+	type splitFile struct {
+		pkg      string
+		messages []string
+		services []string
+		imports  []string
+	}
+	want := map[string]splitFile{
+		"common.proto": {pkg: "shop.common", messages: []string{"Order", "Pet"}, imports: []string{"google/type/decimal.proto"}},
+		"pet.proto": {
+			pkg:      "shop.pet",
+			messages: []string{"GetPetResponse"},
+			services: []string{"Pet"},
+			imports:  []string{"api.proto", "google/protobuf/empty.proto"},
+		},
+		"store.proto": {
+			pkg:      "shop.store",
+			messages: []string{"GetOrderResponse"},
+			services: []string{"Store"},
+			imports:  []string{"api.proto", "google/protobuf/empty.proto"},
+		},
+	}
+
+	files := c.SplitByService()
+	if len(files) != len(want) {
+		t.Fatalf("SplitByService() returned %d files, want %d", len(files), len(want))
+	}
+	for _, file := range files {
+		w, ok := want[file.FileName]
+		if !ok {
+			t.Errorf("unexpected file %s", file.FileName)
+			continue
+		}
+		got := splitFile{pkg: file.PackageName, imports: append([]string{}, file.Imports...)}
+		for _, message := range file.Messages {
+			got.messages = append(got.messages, message.Name)
+		}
+		for _, service := range file.Services {
+			got.services = append(got.services, service.Name)
+		}
+		sort.Strings(got.messages)
+		sort.Strings(got.imports)
+		if !reflect.DeepEqual(got, w) {
+			t.Errorf("%s = %+v, want %+v", file.FileName, got, w)
+		}
+	}
+}
+
+func TestThriftSplitByService(t *testing.T) {
+	c := NewThriftConverter(loadSpec(t, splitSpec), &ConvertOption{NamingOption: true, ApiOption: true, TypeMappings: splitMappings})
+	if err := c.Convert(); err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+
+	type splitFile struct {
+		namespace string
+		structs   []string
+		services  []string
+		includes  []string
+	}
+	want := map[string]splitFile{
+		"common.thrift": {namespace: "shop.common", structs: []string{"Order", "Pet"}, includes: []string{"money.thrift"}},
+		"pet.thrift":    {namespace: "shop.pet", structs: []string{"GetPetResponse"}, services: []string{"Pet"}},
+		"store.thrift":  {namespace: "shop.store", structs: []string{"GetOrderResponse"}, services: []string{"Store"}},
+	}
+
+	files := c.SplitByService()
+	if len(files) != len(want) {
+		t.Fatalf("SplitByService() returned %d files, want %d", len(files), len(want))
+	}
+	for _, file := range files {
+		w, ok := want[file.FileName]
+		if !ok {
+			t.Errorf("unexpected file %s", file.FileName)
+			continue
+		}
+		got := splitFile{namespace: file.Namespace["go"]}
+		if len(file.Includes) > 0 {
+			got.includes = append([]string{}, file.Includes...)
+		}
+		for _, message := range file.Structs {
+			got.structs = append(got.structs, message.Name)
+		}
+		for _, service := range file.Services {
+			got.services = append(got.services, service.Name)
+		}
+		sort.Strings(got.structs)
+		if !reflect.DeepEqual(got, w) {
+			t.Errorf("%s = %+v, want %+v", file.FileName, got, w)
+		}
+	}
+}
@@ -3,7 +3,11 @@ package converter
 import (
 	"errors"
 	"fmt"
+	"sort"
+	"strings"
+
 	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/hertz-contrib/swagger-generate/swagger2idl/parser"
 	"github.com/hertz-contrib/swagger-generate/swagger2idl/thrift"
 	"github.com/hertz-contrib/swagger-generate/swagger2idl/utils"
 )
@@ -17,6 +21,24 @@ type ThriftConverter struct {
 	spec            *openapi3.T
 	ThriftFile      *thrift.ThriftFile
 	converterOption *ConvertOption
+	fieldLock       *thrift.FieldLock      // Field IDs of a previous generation, may be nil
+	usedLock        *thrift.FieldLock      // Field IDs assigned by this conversion
+	specOrder       *parser.SpecOrder      // Declaration order of the spec, may be nil
+	sharedTypes     map[string]bool        // Top-level declarations produced by the component schemas
+	schemaSources   *parser.SchemaSources  // Declaring documents of external references, may be nil
+	externalTypes   map[string]string      // Declarations of the schemas of external references, by document and pointer
+	declSources     map[string]string      // Declaring document of each top-level declaration of another file
+	currentSource   string                 // Document of the external schema being converted
+	diagnostics     *diagnostics           // Lossy decisions of the conversion
+	refTypes        map[interface{}]string // Declarations of the referenced request bodies, responses and parameters
+	fragments       map[interface{}]string // Fragment declarations of the referenced parameters and headers
+	inlineTypes     map[string]bool        // Structs, unions and enums of inline schemas, identical ones are merged
+	converting      map[interface{}]string // Declarations of the schemas being converted, which recursive schemas refer to
+	typeMappings    []parser.TypeMapping   // User type mappings followed by the default ones
+	formFile        string                 // Declared form file struct, empty until a file part is met
+
+	// paramGroups maps each referenced parameter onto the parameters sharing its fragment, computed on first use
+	paramGroups map[*openapi3.Parameter][]*openapi3.ParameterRef
 }
 
 // NewThriftConverter creates and initializes a ThriftConverter
@@ -26,15 +48,51 @@ func NewThriftConverter(spec *openapi3.T, option *ConvertOption) *ThriftConverte
 		ThriftFile: &thrift.ThriftFile{
 			Namespace: map[string]string{},
 			Includes:  []string{},
+			Typedefs:  []*thrift.ThriftTypedef{},
 			Structs:   []*thrift.ThriftStruct{},
 			Enums:     []*thrift.ThriftEnum{},
 			Constants: []*thrift.ThriftConstant{},
 			Services:  []*thrift.ThriftService{},
 		},
 		converterOption: option,
+		externalTypes:   map[string]string{},
+		declSources:     map[string]string{},
+		diagnostics:     newDiagnostics(spec),
+		refTypes:        map[interface{}]string{},
+		fragments:       map[interface{}]string{},
+		converting:      map[interface{}]string{},
+		inlineTypes:     map[string]bool{},
+		typeMappings:    typeMappings(option),
+		usedLock:        thrift.NewFieldLock(),
 	}
 }
 
+// SetFieldLock makes the converter keep the field IDs recorded in lock. Fields missing from
+// the lock receive fresh IDs and IDs of locked fields that no longer exist are never reused.
+func (c *ThriftConverter) SetFieldLock(lock *thrift.FieldLock) {
+	c.fieldLock = lock
+}
+
+// FieldLock returns the field IDs assigned by the last call to Convert
+func (c *ThriftConverter) FieldLock() *thrift.FieldLock {
+	return c.usedLock
+}
+
+// SetSchemaSources provides the declaring documents of the external references of a multi-document spec
+func (c *ThriftConverter) SetSchemaSources(sources *parser.SchemaSources) {
+	c.schemaSources = sources
+}
+
+// SetSpecOrder provides the declaration order used when the spec order option is selected
+func (c *ThriftConverter) SetSpecOrder(order *parser.SpecOrder) {
+	c.specOrder = order
+}
+
+// Diagnostics returns the lossy decisions made while converting the spec
+func (c *ThriftConverter) Diagnostics() []Diagnostic {
+	return c.diagnostics.list
+}
+
 // Convert converts the OpenAPI specification to a Thrift file
 func (c *ThriftConverter) Convert() error {
 	// Convert the go Option to Thrift
@@ -54,6 +112,7 @@ func (c *ThriftConverter) Convert() error {
 	if err != nil {
 		return fmt.Errorf("error converting components to thrift messages: %w", err)
 	}
+	c.sharedTypes = c.declaredTypes()
 
 	// Convert paths into Thrift services
 	err = c.convertPathsToThriftServices()
@@ -68,7 +127,14 @@ func (c *ThriftConverter) Convert() error {
 		}
 	}
 
-	return nil
+	c.dedupeInlineTypes()
+
+	if c.converterOption.OrderOption != OrderSpec {
+		c.sortDeclarations()
+	}
+
+	// Assign field IDs once all structs and unions are complete
+	return c.assignFieldIDs()
 }
 
 func (c *ThriftConverter) GetIdl() interface{} {
@@ -141,73 +207,165 @@ func (c *ThriftConverter) convertComponentsToThriftMessages() error {
 	if components.Schemas == nil {
 		return nil
 	}
-	for name, schemaRef := range components.Schemas {
-		schema := schemaRef
+	for _, name := range orderedKeys(components.Schemas, c.specOrder.SchemaOrder(), c.converterOption) {
+		schema := components.Schemas[name]
 		if c.converterOption.NamingOption {
 			name = utils.ToPascaleCase(name)
 		}
-		thriftType, err := c.ConvertSchemaToThriftType(schema, name, nil)
-		if err != nil {
+		if err := c.addSchemaDeclaration(name, schema); err != nil {
 			return fmt.Errorf("error converting schema %s: %w", name, err)
 		}
-		switch v := thriftType.(type) {
-		case *thrift.ThriftField:
-			message := &thrift.ThriftStruct{
-				Name:   name,
-				Fields: []*thrift.ThriftField{v},
-			}
-			if c.converterOption.OpenapiOption {
-				optionStr := utils.StructToOption(schema.Value, "    ")
+	}
+	return nil
+}
 
-				schemaOption := &thrift.Option{
-					Name:  openapiSchemaOption,
-					Value: optionStr,
-				}
-				message.Options = append(message.Options, schemaOption)
-				c.AddThriftInclude(openapiThriftFile)
+// addSchemaDeclaration converts a named schema into a top-level declaration of the ThriftFile
+func (c *ThriftConverter) addSchemaDeclaration(name string, schema *openapi3.SchemaRef) error {
+	if typedef, err := c.mapTypedef(name, schema); err != nil || typedef {
+		return err
+	}
+
+	thriftType, err := c.ConvertSchemaToThriftType(schema, name, nil)
+	if err != nil {
+		return err
+	}
+	switch v := thriftType.(type) {
+	case *thrift.ThriftField:
+		message := &thrift.ThriftStruct{
+			Name:   name,
+			Fields: []*thrift.ThriftField{v},
+		}
+		if c.converterOption.OpenapiOption {
+			optionStr := utils.StructToOption(schema.Value, "    ")
+
+			schemaOption := &thrift.Option{
+				Name:  openapiSchemaOption,
+				Value: optionStr,
 			}
-			c.addMessageToThrift(message)
-		case *thrift.ThriftStruct:
-			if c.converterOption.OpenapiOption {
-				optionStr := utils.StructToOption(schema.Value, "    ")
+			message.Options = append(message.Options, schemaOption)
+			c.AddThriftInclude(openapiThriftFile)
+		}
+		c.addMessageToThrift(message)
+	case *thrift.ThriftStruct:
+		if c.converterOption.OpenapiOption {
+			optionStr := utils.StructToOption(schema.Value, "    ")
 
-				schemaOption := &thrift.Option{
-					Name:  openapiSchemaOption,
-					Value: optionStr,
-				}
-				v.Options = append(v.Options, schemaOption)
-				c.AddThriftInclude(openapiThriftFile)
+			schemaOption := &thrift.Option{
+				Name:  openapiSchemaOption,
+				Value: optionStr,
 			}
-			c.addMessageToThrift(v)
-		case *thrift.ThriftEnum:
-			if c.converterOption.OpenapiOption {
-				optionStr := utils.StructToOption(schema.Value, "    ")
+			v.Options = append(v.Options, schemaOption)
+			c.AddThriftInclude(openapiThriftFile)
+		}
+		c.addMessageToThrift(v)
+	case *thrift.ThriftEnum:
+		if c.converterOption.OpenapiOption {
+			optionStr := utils.StructToOption(schema.Value, "    ")
 
-				schemaOption := &thrift.Option{
-					Name:  openapiSchemaOption,
-					Value: optionStr,
-				}
-				v.Options = append(v.Options, schemaOption)
-				c.AddThriftInclude(openapiThriftFile)
+			schemaOption := &thrift.Option{
+				Name:  openapiSchemaOption,
+				Value: optionStr,
 			}
-			c.addEnumToThrift(v)
-		case *thrift.ThriftUnion:
-			if c.converterOption.OpenapiOption {
-				optionStr := utils.StructToOption(schema.Value, "    ")
+			v.Options = append(v.Options, schemaOption)
+			c.AddThriftInclude(openapiThriftFile)
+		}
+		c.addEnumToThrift(v)
+	case *thrift.ThriftUnion:
+		// The union of a top-level oneOf is named after the schema it declares
+		v.Name = name
+		if c.converterOption.OpenapiOption {
+			optionStr := utils.StructToOption(schema.Value, "    ")
 
-				schemaOption := &thrift.Option{
-					Name:  openapiSchemaOption,
-					Value: optionStr,
-				}
-				v.Options = append(v.Options, schemaOption)
-				c.AddThriftInclude(openapiThriftFile)
+			schemaOption := &thrift.Option{
+				Name:  openapiSchemaOption,
+				Value: optionStr,
 			}
-			c.addUnionToThrift(v)
+			v.Options = append(v.Options, schemaOption)
+			c.AddThriftInclude(openapiThriftFile)
 		}
+		c.addUnionToThrift(v)
 	}
 	return nil
 }
 
+// mapTypedef declares a named schema holding nothing but additionalProperties as a typedef of a
+// map and reports whether it did. A map whose values refer to the map itself stays a struct.
+func (c *ThriftConverter) mapTypedef(name string, schemaRef *openapi3.SchemaRef) (bool, error) {
+	schema := schemaRef.Value
+	if schemaRef.Ref != "" || schema == nil || !schema.Type.Includes("object") || len(schema.Properties) != 0 ||
+		schema.AdditionalProperties.Schema == nil || len(schema.OneOf) != 0 || len(schema.AllOf) != 0 || len(schema.AnyOf) != 0 {
+		return false, nil
+	}
+	valueType, err := c.mapValueType(schema, name, nil)
+	if err != nil {
+		return false, err
+	}
+	if utils.Contains(utils.TypeNames(valueType), name) {
+		return false, nil
+	}
+
+	typedef := &thrift.ThriftTypedef{
+		Name:        name,
+		Description: schema.Description,
+		Type:        "map<string, " + valueType + ">",
+	}
+	if c.converterOption.OpenapiOption {
+		typedef.Options = append(typedef.Options, &thrift.Option{
+			Name:  openapiSchemaOption,
+			Value: utils.StructToOption(schema, "    "),
+		})
+		c.AddThriftInclude(openapiThriftFile)
+	}
+	c.ThriftFile.Typedefs = append(c.ThriftFile.Typedefs, typedef)
+	c.recordSource(typedef.Name)
+	return true, nil
+}
+
+// addExternalSchema declares the schema of a reference to another document, or to a part of a
+// document outside of its components, the first time the reference is met
+func (c *ThriftConverter) addExternalSchema(schemaRef *openapi3.SchemaRef) (string, error) {
+	name := c.applyNamingOption(utils.ExtractMessageNameFromRef(schemaRef.Ref))
+	source := c.schemaSources.Lookup(schemaRef)
+	if source == "" && isComponentRef(schemaRef.Ref) {
+		return name, nil
+	}
+	key := externalRefKey(source, schemaRef.Ref)
+	if declared, ok := c.externalTypes[key]; ok {
+		return declared, nil
+	}
+	if schemaRef.Value == nil {
+		return name, nil
+	}
+
+	// Schemas of the same name in different documents are told apart by the name of their document
+	taken := c.declaredTypes()
+	for _, declared := range c.externalTypes {
+		taken[declared] = true
+	}
+	if c.spec.Components != nil {
+		for componentName := range c.spec.Components.Schemas {
+			taken[c.applyNamingOption(componentName)] = true
+		}
+	}
+	if taken[name] {
+		renamed := uniqueName(documentQualifier(source)+utils.ToUpperCase(name), taken)
+		c.diagnostics.warn(schemaRef.Value, "schema %s is declared as %s, %s is already declared", schemaRef.Ref, renamed, name)
+		name = renamed
+	}
+	c.externalTypes[key] = name
+
+	previous := c.currentSource
+	if source != "" {
+		c.currentSource = source
+	}
+	defer func() { c.currentSource = previous }()
+
+	if err := c.addSchemaDeclaration(name, &openapi3.SchemaRef{Value: schemaRef.Value}); err != nil {
+		return "", fmt.Errorf("error converting schema %s: %w", schemaRef.Ref, err)
+	}
+	return name, nil
+}
+
 // convertPathsToThriftServices converts OpenAPI path items into Thrift services and stores them in the ThriftFile
 func (c *ThriftConverter) convertPathsToThriftServices() error {
 	paths := c.spec.Paths
@@ -224,8 +382,11 @@ func (c *ThriftConverter) convertPathsToThriftServices() error {
 func (c *ThriftConverter) ConvertPathsToThriftServices(paths *openapi3.Paths) ([]*thrift.ThriftService, error) {
 	var services []*thrift.ThriftService
 
-	for path, pathItem := range paths.Map() {
-		for method, operation := range pathItem.Operations() {
+	for _, path := range orderedKeys(paths.Map(), c.specOrder.PathOrder(), c.converterOption) {
+		pathItem := paths.Value(path)
+		operations := pathItem.Operations()
+		for _, method := range orderedKeys(operations, c.specOrder.OperationOrder(path), c.converterOption) {
+			operation := withPathParameters(pathItem, operations[method])
 			serviceName := utils.GetServiceName(operation)
 			methodName := utils.GetMethodName(operation, path, method)
 
@@ -296,221 +457,482 @@ func (c *ThriftConverter) generateRequestMessage(operation *openapi3.Operation,
 	}
 
 	if operation.RequestBody != nil {
+		// A referenced request body is the request of the operations without parameters and a
+		// field of the requests of the others
 		if operation.RequestBody.Ref != "" {
-			//todo
-			return []string{utils.ExtractMessageNameFromRef(operation.RequestBody.Ref)}, nil
+			bodyName, err := c.convertRequestBodyRef(operation.RequestBody)
+			if err != nil {
+				return []string{""}, err
+			}
+			if len(operation.Parameters) == 0 {
+				return []string{bodyName}, nil
+			}
+			bodyField := &thrift.ThriftField{
+				Name: bodyName,
+				Type: bodyName,
+			}
+			c.applyRequiredness(bodyField, operation.RequestBody.Value.Required)
+			message.Fields = append(message.Fields, bodyField)
+		} else if operation.RequestBody.Value != nil {
+			if err := c.addRequestBodyFields(message, operation.RequestBody.Value); err != nil {
+				return []string{""}, err
+			}
 		}
+	}
 
-		if operation.RequestBody.Value != nil && len(operation.RequestBody.Value.Content) > 0 {
-			for mediaTypeStr, mediaType := range operation.RequestBody.Value.Content {
-				schema := mediaType.Schema
-				if schema != nil {
-					thriftType, err := c.ConvertSchemaToThriftType(schema, utils.FormatStr(mediaTypeStr), message)
-					if err != nil {
-						return []string{""}, err
-					}
+	for _, param := range operation.Parameters {
+		// A referenced parameter is either embedded field by field or referenced as a fragment
+		if param.Ref != "" && c.converterOption.FragmentOption == FragmentReference {
+			field, err := c.parameterFragmentField(param)
+			if err != nil {
+				return []string{""}, err
+			}
+			c.addFieldIfNotExists(&message.Fields, field)
+			continue
+		}
+		if err := c.addParameterFields(message, param); err != nil {
+			return []string{""}, err
+		}
+	}
 
-					switch v := thriftType.(type) {
-					case *thrift.ThriftField:
-						if c.converterOption.ApiOption {
-							var optionName string
-							if mediaTypeStr == "application/json" {
-								optionName = "api.body"
-							} else if mediaTypeStr == "application/x-www-form-urlencoded" || mediaTypeStr == "multipart/form-data" {
-								optionName = "api.form"
-							}
-							if optionName != "" {
-								v.Options = append(v.Options, &thrift.Option{
-									Name:  optionName,
-									Value: fmt.Sprintf("%q", v.Name),
-								})
-							}
-						}
-						c.addFieldIfNotExists(&message.Fields, v)
-					case *thrift.ThriftStruct:
-						for _, field := range v.Fields {
-							if c.converterOption.ApiOption {
-								var optionName string
-								if mediaTypeStr == "application/json" {
-									optionName = "api.body"
-								} else if mediaTypeStr == "application/x-www-form-urlencoded" || mediaTypeStr == "multipart/form-data" {
-									optionName = "api.form"
-								}
-								if optionName != "" {
-									field.Options = append(field.Options, &thrift.Option{
-										Name:  optionName,
-										Value: fmt.Sprintf("%q", field.Name),
-									})
-								}
-							}
-							c.addFieldIfNotExists(&message.Fields, field)
-						}
-					case *thrift.ThriftEnum:
-						name := mediaTypeStr
-						if c.converterOption.NamingOption {
-							name = utils.ToSnakeCase(name)
-						} else {
-							name = utils.FormatStr(name)
-						}
-						newField := &thrift.ThriftField{
-							Name: name + "_field",
-							Type: v.Name,
-						}
-						if c.converterOption.ApiOption {
-							var optionName string
-							if mediaTypeStr == "application/json" {
-								optionName = "api.body"
-							} else if mediaTypeStr == "application/x-www-form-urlencoded" || mediaTypeStr == "multipart/form-data" {
-								optionName = "api.form"
-							}
-							if optionName != "" {
-								newField.Options = append(newField.Options, &thrift.Option{
-									Name:  optionName,
-									Value: fmt.Sprintf("%q", v.Name),
-								})
-							}
-						}
-						if c.converterOption.OpenapiOption {
-							optionStr := utils.StructToOption(operation.RequestBody.Value, "     ")
-
-							schemaOption := &thrift.Option{
-								Name:  openapiPropertyOption,
-								Value: optionStr,
-							}
-							newField.Options = append(newField.Options, schemaOption)
-							c.AddThriftInclude(openapiThriftFile)
-						}
-						message.Fields = append(message.Fields, newField)
-					case *thrift.ThriftUnion:
-						name := mediaTypeStr
-						if c.converterOption.NamingOption {
-							name = utils.ToSnakeCase(name)
-						} else {
-							name = utils.FormatStr(name)
-						}
-						newField := &thrift.ThriftField{
-							Name: name + "_field",
-							Type: v.Name,
-						}
-						if c.converterOption.ApiOption {
-							var optionName string
-							if mediaTypeStr == "application/json" {
-								optionName = "api.body"
-							} else if mediaTypeStr == "application/x-www-form-urlencoded" || mediaTypeStr == "multipart/form-data" {
-								optionName = "api.form"
-							}
-							if optionName != "" {
-								newField.Options = append(newField.Options, &thrift.Option{
-									Name:  optionName,
-									Value: fmt.Sprintf("%q", v.Name),
-								})
-							}
-						}
-						if c.converterOption.OpenapiOption {
-							optionStr := utils.StructToOption(operation.RequestBody.Value, "     ")
-
-							schemaOption := &thrift.Option{
-								Name:  openapiPropertyOption,
-								Value: optionStr,
-							}
-							newField.Options = append(newField.Options, schemaOption)
-							c.AddThriftInclude(openapiThriftFile)
-						}
-						message.Fields = append(message.Fields, newField)
+	// if there are no fields or messages, return an empty message
+	if len(message.Fields) > 0 {
+		c.addMessageToThrift(message)
+		return []string{message.Name}, nil
+	}
+
+	return []string{""}, nil
+}
+
+// addParameterFields converts a parameter into fields of message
+func (c *ThriftConverter) addParameterFields(message *thrift.ThriftStruct, param *openapi3.ParameterRef) error {
+	if param.Value.Schema != nil {
+		fieldOrMessage, err := c.ConvertSchemaToThriftType(param.Value.Schema, param.Value.Name, message)
+		if err != nil {
+			return err
+		}
+
+		fieldID, err := c.explicitFieldID(param.Value, param.Value.Extensions)
+		if err != nil {
+			return err
+		}
+
+		switch v := fieldOrMessage.(type) {
+		case *thrift.ThriftField:
+			v.ID = fieldID
+			if c.converterOption.ApiOption {
+				v.Options = append(v.Options, &thrift.Option{
+					Name:  "api." + param.Value.In,
+					Value: fmt.Sprintf("%q", param.Value.Name),
+				})
+			}
+			if c.converterOption.OpenapiOption {
+				optionStr := utils.StructToOption(param.Value, "     ")
+
+				schemaOption := &thrift.Option{
+					Name:  openapiParameterOption,
+					Value: optionStr,
+				}
+				v.Options = append(v.Options, schemaOption)
+				c.AddThriftInclude(openapiThriftFile)
+			}
+			v.Description = param.Value.Description
+			c.applyRequiredness(v, param.Value.Required && !isNullable(param.Value.Schema))
+			c.addFieldIfNotExists(&message.Fields, v)
+		case *thrift.ThriftStruct:
+			for _, field := range v.Fields {
+				if c.converterOption.ApiOption {
+					field.Options = append(field.Options, &thrift.Option{
+						Name:  "api." + param.Value.In,
+						Value: fmt.Sprintf("%q", param.Value.Name),
+					})
+				}
+				if c.converterOption.OpenapiOption {
+					optionStr := utils.StructToOption(param.Value, "     ")
+
+					schemaOption := &thrift.Option{
+						Name:  openapiParameterOption,
+						Value: optionStr,
 					}
+					field.Options = append(field.Options, schemaOption)
+					c.AddThriftInclude(openapiThriftFile)
+				}
+				c.addFieldIfNotExists(&message.Fields, field)
+			}
+		case *thrift.ThriftEnum:
+			// The enum of a referenced parameter is declared once at the top level, the enum of an
+			// inline one as any inline enum
+			var enumName string
+			if param.Ref != "" {
+				enumName = c.addParameterEnum(param, v)
+			} else {
+				c.addInlineEnum(v)
+				enumName = v.Name
+			}
+			name := param.Value.Name
+			if c.converterOption.NamingOption {
+				name = utils.ToPascaleCase(name)
+			}
+			newField := &thrift.ThriftField{
+				ID:   fieldID,
+				Name: name,
+				Type: enumName,
+			}
+			if c.converterOption.ApiOption {
+				newField.Options = append(newField.Options, &thrift.Option{
+					Name:  "api." + param.Value.In,
+					Value: fmt.Sprintf("%q", param.Value.Name),
+				})
+			}
+			if c.converterOption.OpenapiOption {
+				optionStr := utils.StructToOption(param.Value, "     ")
+
+				schemaOption := &thrift.Option{
+					Name:  openapiParameterOption,
+					Value: optionStr,
+				}
+				newField.Options = append(newField.Options, schemaOption)
+				c.AddThriftInclude(openapiThriftFile)
+			}
+			c.applyRequiredness(newField, param.Value.Required && !isNullable(param.Value.Schema))
+			message.Fields = append(message.Fields, newField)
+		case *thrift.ThriftUnion:
+			c.addInlineUnion(v)
+			name := param.Value.Name
+			if c.converterOption.NamingOption {
+				name = utils.ToPascaleCase(name)
+			}
+			newField := &thrift.ThriftField{
+				ID:   fieldID,
+				Name: name,
+				Type: v.Name,
+			}
+			if c.converterOption.ApiOption {
+				newField.Options = append(newField.Options, &thrift.Option{
+					Name:  "api." + param.Value.In,
+					Value: fmt.Sprintf("%q", param.Value.Name),
+				})
+			}
+			if c.converterOption.OpenapiOption {
+				optionStr := utils.StructToOption(param.Value, "     ")
+
+				schemaOption := &thrift.Option{
+					Name:  openapiParameterOption,
+					Value: optionStr,
 				}
+				newField.Options = append(newField.Options, schemaOption)
+				c.AddThriftInclude(openapiThriftFile)
 			}
+			c.applyRequiredness(newField, param.Value.Required && !isNullable(param.Value.Schema))
+			message.Fields = append(message.Fields, newField)
 		}
+	} else {
+		c.diagnostics.drop(param.Value, "parameter %s has no schema and is dropped", param.Value.Name)
 	}
 
-	if len(operation.Parameters) > 0 {
-		for _, param := range operation.Parameters {
-			if param.Value.Schema != nil {
-				fieldOrMessage, err := c.ConvertSchemaToThriftType(param.Value.Schema, param.Value.Name, message)
-				if err != nil {
-					return []string{""}, err
-				}
+	return nil
+}
 
-				switch v := fieldOrMessage.(type) {
-				case *thrift.ThriftField:
-					if c.converterOption.ApiOption {
+// parameterFragmentField declares the fragment struct of the group of a referenced parameter the
+// first time it is met and returns a field of the fragment type. The fields of the fragment keep
+// the annotations binding them to the request.
+func (c *ThriftConverter) parameterFragmentField(param *openapi3.ParameterRef) (*thrift.ThriftField, error) {
+	if c.paramGroups == nil {
+		c.paramGroups = parameterGroups(c.spec.Paths)
+	}
+	group := c.paramGroups[param.Value]
+	if len(group) == 0 {
+		group = []*openapi3.ParameterRef{param}
+	}
+
+	name, ok := c.fragments[group[0].Value]
+	if !ok {
+		fragment := &thrift.ThriftStruct{Name: c.fragmentName(group)}
+		c.addMessageToThrift(fragment)
+		name = fragment.Name
+		for _, member := range group {
+			c.fragments[member.Value] = name
+		}
+		for _, member := range group {
+			if err := c.addParameterFields(fragment, member); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	fieldName := name
+	if len(group) == 1 {
+		fieldName = param.Value.Name
+	}
+	return &thrift.ThriftField{
+		Name: c.applyNamingOption(fieldName),
+		Type: name,
+	}, nil
+}
+
+// fragmentName names the fragment struct of a group of referenced parameters after the single
+// parameter, or after all of them
+func (c *ThriftConverter) fragmentName(group []*openapi3.ParameterRef) string {
+	if len(group) == 1 {
+		return c.refTypeName(group[0].Ref, "Parameter")
+	}
+	var sb strings.Builder
+	for _, param := range group {
+		sb.WriteString(utils.ToUpperCase(utils.ExtractMessageNameFromRef(param.Ref)))
+	}
+	name := c.applyNamingOption(sb.String() + "Parameters")
+	if declared := c.declaredTypes(); declared[name] {
+		name = uniqueName(name, declared)
+	}
+	return name
+}
+
+// addRequestBodyFields converts the content of a request body into fields of message
+func (c *ThriftConverter) addRequestBodyFields(message *thrift.ThriftStruct, requestBody *openapi3.RequestBody) error {
+	for _, mediaTypeStr := range utils.SortedKeys(requestBody.Content) {
+		schema := requestBody.Content[mediaTypeStr].Schema
+		if schema != nil {
+			thriftType, err := c.ConvertSchemaToThriftType(schema, utils.FormatStr(mediaTypeStr), message)
+			if err != nil {
+				return err
+			}
+
+			switch v := thriftType.(type) {
+			case *thrift.ThriftField:
+				if c.converterOption.ApiOption {
+					var optionName string
+					if mediaTypeStr == "application/json" {
+						optionName = "api.body"
+					} else if mediaTypeStr == "application/x-www-form-urlencoded" || mediaTypeStr == "multipart/form-data" {
+						optionName = "api.form"
+					}
+					if optionName != "" {
 						v.Options = append(v.Options, &thrift.Option{
-							Name:  "api." + param.Value.In,
-							Value: fmt.Sprintf("%q", param.Value.Name),
+							Name:  optionName,
+							Value: fmt.Sprintf("%q", v.Name),
 						})
 					}
-					if c.converterOption.OpenapiOption {
-						optionStr := utils.StructToOption(param.Value, "     ")
-
-						schemaOption := &thrift.Option{
-							Name:  openapiParameterOption,
-							Value: optionStr,
+				}
+				c.applyRequiredness(v, requestBody.Required && !isNullable(schema))
+				c.addFieldIfNotExists(&message.Fields, v)
+			case *thrift.ThriftStruct:
+				if mediaTypeStr == multipartFormData {
+					c.convertFormFiles(v, schema)
+				}
+				for _, field := range v.Fields {
+					if c.converterOption.ApiOption {
+						var optionName string
+						if mediaTypeStr == "application/json" {
+							optionName = "api.body"
+						} else if mediaTypeStr == "application/x-www-form-urlencoded" || mediaTypeStr == "multipart/form-data" {
+							optionName = "api.form"
 						}
-						v.Options = append(v.Options, schemaOption)
-						c.AddThriftInclude(openapiThriftFile)
-					}
-					v.Description = param.Value.Description
-					c.addFieldIfNotExists(&message.Fields, v)
-				case *thrift.ThriftStruct:
-					for _, field := range v.Fields {
-						if c.converterOption.ApiOption {
+						if optionName != "" {
 							field.Options = append(field.Options, &thrift.Option{
-								Name:  "api." + param.Value.In,
-								Value: fmt.Sprintf("%q", param.Value.Name),
+								Name:  optionName,
+								Value: fmt.Sprintf("%q", field.Name),
 							})
 						}
-						if c.converterOption.OpenapiOption {
-							optionStr := utils.StructToOption(param.Value, "     ")
-
-							schemaOption := &thrift.Option{
-								Name:  openapiParameterOption,
-								Value: optionStr,
-							}
-							field.Options = append(field.Options, schemaOption)
-							c.AddThriftInclude(openapiThriftFile)
-						}
-						c.addFieldIfNotExists(&message.Fields, field)
 					}
-				case *thrift.ThriftEnum:
+					c.addFieldIfNotExists(&message.Fields, field)
+				}
+			case *thrift.ThriftEnum:
+				c.addInlineEnum(v)
+				name := mediaTypeStr
+				if c.converterOption.NamingOption {
+					name = utils.ToSnakeCase(name)
+				} else {
+					name = utils.FormatStr(name)
+				}
+				newField := &thrift.ThriftField{
+					Name: name + "_field",
+					Type: v.Name,
+				}
+				if c.converterOption.ApiOption {
+					var optionName string
+					if mediaTypeStr == "application/json" {
+						optionName = "api.body"
+					} else if mediaTypeStr == "application/x-www-form-urlencoded" || mediaTypeStr == "multipart/form-data" {
+						optionName = "api.form"
+					}
+					if optionName != "" {
+						newField.Options = append(newField.Options, &thrift.Option{
+							Name:  optionName,
+							Value: fmt.Sprintf("%q", v.Name),
+						})
+					}
+				}
+				if c.converterOption.OpenapiOption {
+					optionStr := utils.StructToOption(requestBody, "     ")
 
-				case *thrift.ThriftUnion:
-					name := param.Value.Name
-					if c.converterOption.NamingOption {
-						name = utils.ToPascaleCase(name)
+					schemaOption := &thrift.Option{
+						Name:  openapiPropertyOption,
+						Value: optionStr,
 					}
-					newField := &thrift.ThriftField{
-						Name: name,
-						Type: v.Name,
+					newField.Options = append(newField.Options, schemaOption)
+					c.AddThriftInclude(openapiThriftFile)
+				}
+				message.Fields = append(message.Fields, newField)
+			case *thrift.ThriftUnion:
+				c.addInlineUnion(v)
+				name := mediaTypeStr
+				if c.converterOption.NamingOption {
+					name = utils.ToSnakeCase(name)
+				} else {
+					name = utils.FormatStr(name)
+				}
+				newField := &thrift.ThriftField{
+					Name: name + "_field",
+					Type: v.Name,
+				}
+				if c.converterOption.ApiOption {
+					var optionName string
+					if mediaTypeStr == "application/json" {
+						optionName = "api.body"
+					} else if mediaTypeStr == "application/x-www-form-urlencoded" || mediaTypeStr == "multipart/form-data" {
+						optionName = "api.form"
 					}
-					if c.converterOption.ApiOption {
+					if optionName != "" {
 						newField.Options = append(newField.Options, &thrift.Option{
-							Name:  "api." + param.Value.In,
-							Value: fmt.Sprintf("%q", param.Value.Name),
+							Name:  optionName,
+							Value: fmt.Sprintf("%q", v.Name),
 						})
 					}
-					if c.converterOption.OpenapiOption {
-						optionStr := utils.StructToOption(param.Value, "     ")
+				}
+				if c.converterOption.OpenapiOption {
+					optionStr := utils.StructToOption(requestBody, "     ")
 
-						schemaOption := &thrift.Option{
-							Name:  openapiParameterOption,
-							Value: optionStr,
-						}
-						newField.Options = append(newField.Options, schemaOption)
-						c.AddThriftInclude(openapiThriftFile)
+					schemaOption := &thrift.Option{
+						Name:  openapiPropertyOption,
+						Value: optionStr,
 					}
-					message.Fields = append(message.Fields, newField)
+					newField.Options = append(newField.Options, schemaOption)
+					c.AddThriftInclude(openapiThriftFile)
 				}
+				message.Fields = append(message.Fields, newField)
 			}
 		}
 	}
 
-	// if there are no fields or messages, return an empty message
-	if len(message.Fields) > 0 {
+	return nil
+}
+
+// thriftTypeOf returns the type expression of a converted schema, declaring the struct, enum or
+// union it converted into
+func (c *ThriftConverter) thriftTypeOf(converted interface{}) string {
+	switch v := converted.(type) {
+	case *thrift.ThriftField:
+		if v.Repeated {
+			return "list<" + v.Type + ">"
+		}
+		return v.Type
+	case *thrift.ThriftStruct:
+		c.addInlineStruct(v)
+		return v.Name
+	case *thrift.ThriftEnum:
+		c.addInlineEnum(v)
+		return v.Name
+	case *thrift.ThriftUnion:
+		c.addInlineUnion(v)
+		return v.Name
+	}
+	return "string"
+}
+
+// arrayField returns the field of an array of itemType, a set when its items are unique
+func (c *ThriftConverter) arrayField(schema *openapi3.Schema, thriftName, itemType, description string) *thrift.ThriftField {
+	field := &thrift.ThriftField{
+		Name:        c.applyNamingOption(thriftName),
+		Type:        itemType,
+		Repeated:    true,
+		Description: description,
+	}
+	if schema.UniqueItems {
+		field.Type = "set<" + itemType + ">"
+		field.Repeated = false
+	}
+	return field
+}
+
+// mapValueType converts the additionalProperties schema of an object into the value type of a map
+func (c *ThriftConverter) mapValueType(schema *openapi3.Schema, thriftName string, parentMessage *thrift.ThriftStruct) (string, error) {
+	converted, err := c.ConvertSchemaToThriftType(schema.AdditionalProperties.Schema, thriftName+"AdditionalProperties", parentMessage)
+	if err != nil {
+		return "", err
+	}
+	return c.thriftTypeOf(converted), nil
+}
+
+// convertFormFiles turns the fields of the file parts of a multipart/form-data body into fields of
+// the form file struct
+func (c *ThriftConverter) convertFormFiles(message *thrift.ThriftStruct, schema *openapi3.SchemaRef) {
+	if schema.Value == nil {
+		return
+	}
+	for _, propName := range utils.SortedKeys(schema.Value.Properties) {
+		if !isFormFile(schema.Value.Properties[propName]) {
+			continue
+		}
+		name := c.applyNamingOption(propName)
+		for _, field := range message.Fields {
+			if field.Name == name {
+				field.Type = c.formFileType()
+			}
+		}
+	}
+}
+
+// formFileType declares the struct of the file parts of multipart/form-data bodies the first time
+// it is needed and returns its name
+func (c *ThriftConverter) formFileType() string {
+	if c.formFile == "" {
+		message := &thrift.ThriftStruct{
+			Name:        formFileName,
+			Description: "File part of a multipart/form-data request body",
+			Fields: []*thrift.ThriftField{
+				{Name: c.applyNamingOption("filename"), Type: "string", Optional: true},
+				{Name: c.applyNamingOption("content_type"), Type: "string", Optional: true},
+				{Name: c.applyNamingOption("content"), Type: "binary"},
+			},
+		}
+		c.applyRequiredness(message.Fields[2], true)
 		c.addMessageToThrift(message)
-		return []string{message.Name}, nil
+		c.formFile = message.Name
 	}
+	return c.formFile
+}
 
-	return []string{""}, nil
+// convertRequestBodyRef converts a referenced request body into a top-level struct the first time it
+// is met and returns its name
+func (c *ThriftConverter) convertRequestBodyRef(bodyRef *openapi3.RequestBodyRef) (string, error) {
+	if bodyRef.Value == nil {
+		return "", fmt.Errorf("request body %s is not resolved", bodyRef.Ref)
+	}
+	if name, ok := c.refTypes[bodyRef.Value]; ok {
+		return name, nil
+	}
+
+	name := c.refTypeName(bodyRef.Ref, "Request")
+	message := &thrift.ThriftStruct{Name: name}
+	if err := c.addRequestBodyFields(message, bodyRef.Value); err != nil {
+		return "", err
+	}
+	c.addMessageToThrift(message)
+	c.refTypes[bodyRef.Value] = message.Name
+	return message.Name, nil
+}
+
+// addParameterEnum declares the enum of a referenced parameter once at the top level and returns
+// its name
+func (c *ThriftConverter) addParameterEnum(param *openapi3.ParameterRef, enum *thrift.ThriftEnum) string {
+	if name, ok := c.refTypes[param.Value]; ok {
+		return name
+	}
+
+	enum.Name = c.refTypeName(param.Ref, "Enum")
+	c.addEnumToThrift(enum)
+	c.refTypes[param.Value] = enum.Name
+	return enum.Name
 }
 
 // generateResponseMessage generates a response message for an operation
@@ -551,15 +973,22 @@ func (c *ThriftConverter) generateResponseMessage(operation *openapi3.Operation,
 
 	emptyFlag := true
 
-	for statusCode, responseRef := range responses {
+	for _, statusCode := range utils.SortedKeys(responses) {
+		responseRef := responses[statusCode]
 		if responseRef.Ref == "" && (responseRef.Value == nil || len(responseRef.Value.Content) == 0) {
-			break
+			if responseRef.Value != nil && len(responseRef.Value.Headers) > 0 {
+				c.diagnostics.drop(responseRef.Value, "headers of response %s without content are dropped from %s", statusCode, wrapperMessageName)
+			}
+			continue
 		}
-		emptyFlag = false
 		messageName, err := c.processSingleResponse(statusCode, responseRef, operation, methodName)
 		if err != nil {
 			return "", err
 		}
+		if messageName == "" {
+			continue
+		}
+		emptyFlag = false
 
 		name := "Response_" + statusCode
 		if c.converterOption.NamingOption {
@@ -585,129 +1014,69 @@ func (c *ThriftConverter) generateResponseMessage(operation *openapi3.Operation,
 // processSingleResponse deals with a single response in an operation
 func (c *ThriftConverter) processSingleResponse(statusCode string, responseRef *openapi3.ResponseRef, operation *openapi3.Operation, methodName string) (string, error) {
 	if responseRef.Ref != "" {
-		return utils.ExtractMessageNameFromRef(responseRef.Ref), nil
+		return c.convertResponseRef(responseRef)
 	}
 
-	response := responseRef.Value
 	messageName := operation.OperationID + "Response" + utils.ToUpperCase(statusCode)
 
 	if c.converterOption.NamingOption {
 		messageName = utils.ToPascaleCase(messageName)
 	}
 
-	message := &thrift.ThriftStruct{Name: messageName}
-
-	if len(response.Headers) > 0 {
-		for headerName, headerRef := range response.Headers {
-			if headerRef != nil {
-
-				fieldOrMessage, err := c.ConvertSchemaToThriftType(headerRef.Value.Schema, headerName, message)
-				if err != nil {
-					return "", err
-				}
-
-				switch v := fieldOrMessage.(type) {
-				case *thrift.ThriftField:
-					if c.converterOption.ApiOption {
-						option := &thrift.Option{
-							Name:  "api.header",
-							Value: fmt.Sprintf("%q", headerName),
-						}
-						v.Options = append(v.Options, option)
-					}
-					if c.converterOption.OpenapiOption {
-						optionStr := utils.StructToOption(headerRef.Value, "     ")
+	return c.convertResponse(responseRef.Value, messageName)
+}
 
-						schemaOption := &thrift.Option{
-							Name:  openapiPropertyOption,
-							Value: optionStr,
-						}
-						v.Options = append(v.Options, schemaOption)
-						c.AddThriftInclude(openapiThriftFile)
-					}
-					c.addFieldIfNotExists(&message.Fields, v)
-				case *thrift.ThriftStruct:
-					for _, field := range v.Fields {
-						if c.converterOption.ApiOption {
-							option := &thrift.Option{
-								Name:  "api.header",
-								Value: fmt.Sprintf("%q", field.Name),
-							}
-							field.Options = append(field.Options, option)
-						}
-						if c.converterOption.OpenapiOption {
-							optionStr := utils.StructToOption(headerRef.Value, "     ")
-
-							schemaOption := &thrift.Option{
-								Name:  openapiPropertyOption,
-								Value: optionStr,
-							}
-							field.Options = append(field.Options, schemaOption)
-							c.AddThriftInclude(openapiThriftFile)
-						}
-						c.addFieldIfNotExists(&message.Fields, field)
-					}
-				case *thrift.ThriftEnum:
-					name := headerName
-					if c.converterOption.NamingOption {
-						name = utils.ToSnakeCase(name)
-					}
-					newField := &thrift.ThriftField{
-						Name: name + "_field",
-						Type: v.Name,
-					}
-					if c.converterOption.ApiOption {
-						option := &thrift.Option{
-							Name:  "api.header",
-							Value: fmt.Sprintf("%q", headerName),
-						}
-						newField.Options = append(newField.Options, option)
-					}
-					if c.converterOption.OpenapiOption {
-						optionStr := utils.StructToOption(headerRef.Value, "     ")
+// convertResponseRef converts a referenced response into a top-level struct the first time it is
+// met and returns its name
+func (c *ThriftConverter) convertResponseRef(responseRef *openapi3.ResponseRef) (string, error) {
+	if responseRef.Value == nil {
+		return "", fmt.Errorf("response %s is not resolved", responseRef.Ref)
+	}
+	if name, ok := c.refTypes[responseRef.Value]; ok {
+		return name, nil
+	}
 
-						schemaOption := &thrift.Option{
-							Name:  openapiPropertyOption,
-							Value: optionStr,
-						}
-						newField.Options = append(newField.Options, schemaOption)
-						c.AddThriftInclude(openapiThriftFile)
-					}
-					message.Fields = append(message.Fields, newField)
-				case *thrift.ThriftUnion:
-					name := headerName
-					if c.converterOption.NamingOption {
-						name = utils.ToSnakeCase(name)
-					}
-					newField := &thrift.ThriftField{
-						Name: name + "_field",
-						Type: v.Name,
-					}
-					if c.converterOption.ApiOption {
-						option := &thrift.Option{
-							Name:  "api.header",
-							Value: fmt.Sprintf("%q", headerName),
-						}
-						newField.Options = append(newField.Options, option)
-					}
-					if c.converterOption.OpenapiOption {
-						optionStr := utils.StructToOption(headerRef.Value, "     ")
+	name := c.refTypeName(responseRef.Ref, "Response")
+	messageName, err := c.convertResponse(responseRef.Value, name)
+	if err != nil {
+		return "", err
+	}
+	// A response without content is still declared, as it is used as a type
+	if messageName == "" {
+		message := &thrift.ThriftStruct{Name: name}
+		c.addMessageToThrift(message)
+		messageName = message.Name
+	}
+	c.refTypes[responseRef.Value] = messageName
+	return messageName, nil
+}
 
-						schemaOption := &thrift.Option{
-							Name:  openapiPropertyOption,
-							Value: optionStr,
-						}
-						newField.Options = append(newField.Options, schemaOption)
-						c.AddThriftInclude(openapiThriftFile)
-					}
-					message.Fields = append(message.Fields, newField)
-				}
+// convertResponse converts the headers and content of a response into a struct named messageName
+// and returns its name, or an empty string if the response has neither
+func (c *ThriftConverter) convertResponse(response *openapi3.Response, messageName string) (string, error) {
+	message := &thrift.ThriftStruct{Name: messageName}
+
+	for _, headerName := range utils.SortedKeys(response.Headers) {
+		headerRef := response.Headers[headerName]
+		if headerRef == nil {
+			continue
+		}
+		// A referenced header is either embedded or referenced as a fragment
+		if headerRef.Ref != "" && c.converterOption.FragmentOption == FragmentReference {
+			field, err := c.headerFragmentField(headerName, headerRef)
+			if err != nil {
+				return "", err
 			}
+			c.addFieldIfNotExists(&message.Fields, field)
+			continue
+		}
+		if err := c.addHeaderFields(message, headerName, headerRef); err != nil {
+			return "", err
 		}
 	}
 
-	for mediaTypeStr, mediaType := range response.Content {
-		schema := mediaType.Schema
+	for _, mediaTypeStr := range utils.SortedKeys(response.Content) {
+		schema := response.Content[mediaTypeStr].Schema
 		if schema != nil {
 
 			thriftType, err := c.ConvertSchemaToThriftType(schema, utils.FormatStr(mediaTypeStr), message)
@@ -747,6 +1116,7 @@ func (c *ThriftConverter) processSingleResponse(statusCode string, responseRef *
 					c.addFieldIfNotExists(&message.Fields, field)
 				}
 			case *thrift.ThriftEnum:
+				c.addInlineEnum(v)
 				name := mediaTypeStr
 				if c.converterOption.NamingOption {
 					name = utils.ToSnakeCase(mediaTypeStr)
@@ -776,6 +1146,7 @@ func (c *ThriftConverter) processSingleResponse(statusCode string, responseRef *
 				}
 				message.Fields = append(message.Fields, newField)
 			case *thrift.ThriftUnion:
+				c.addInlineUnion(v)
 				name := mediaTypeStr
 				if c.converterOption.NamingOption {
 					name = utils.ToSnakeCase(mediaTypeStr)
@@ -826,10 +1197,13 @@ func (c *ThriftConverter) ConvertSchemaToThriftType(
 
 	// Handle referenced schema
 	if schemaRef.Ref != "" {
-		name := c.applyNamingOption(utils.ExtractMessageNameFromRef(schemaRef.Ref))
+		name, err := c.addExternalSchema(schemaRef)
+		if err != nil {
+			return nil, err
+		}
 		return &thrift.ThriftField{
 			Name: name,
-			Type: utils.ExtractMessageNameFromRef(schemaRef.Ref),
+			Type: name,
 		}, nil
 	}
 
@@ -841,35 +1215,45 @@ func (c *ThriftConverter) ConvertSchemaToThriftType(
 	schema := schemaRef.Value
 	description := schema.Description
 
+	// A schema met again while it is being converted is a recursive structure referring to itself
+	if name, ok := c.converting[schema]; ok {
+		return &thrift.ThriftField{
+			Name: c.applyNamingOption(thriftName),
+			Type: name,
+		}, nil
+	}
+
 	// Handle oneOf, allOf, anyOf even if schema.Type is nil
 	if len(schema.OneOf) > 0 {
-		thriftStruct, err := c.handleOneOf(schema.OneOf, thriftName, parentMessage)
+		thriftStruct, err := c.handleOneOf(schema, thriftName, parentMessage)
 		if err != nil {
 			return nil, err
 		}
 		return thriftStruct, nil
 	} else if len(schema.AllOf) > 0 {
-		thriftStruct, err := c.handleAllOf(schema.AllOf, thriftName, parentMessage)
+		thriftStruct, err := c.handleAllOf(schema, thriftName, parentMessage)
 		if err != nil {
 			return nil, err
 		}
 		return thriftStruct, nil
 	} else if len(schema.AnyOf) > 0 {
-		thriftStruct, err := c.handleAnyOf(schema.AnyOf, thriftName, parentMessage)
+		thriftStruct, err := c.handleAnyOf(schema, thriftName, parentMessage)
 		if err != nil {
 			return nil, err
 		}
 		return thriftStruct, nil
 	}
 
+	if schema.Not != nil {
+		c.diagnostics.warn(schema, "not is ignored")
+	}
+
+	checkTypeList(c.diagnostics, schema)
+
 	// Process schema type
 	switch {
 	case schema.Type.Includes("string"):
-		if schema.Format == "date" || schema.Format == "date-time" {
-			thriftType = "string"
-		} else if schema.Format == "byte" || schema.Format == "binary" {
-			thriftType = "binary"
-		} else if len(schema.Enum) != 0 {
+		if len(schema.Enum) != 0 {
 			var name string
 			if parentMessage == nil {
 				name = thriftName
@@ -888,7 +1272,7 @@ func (c *ThriftConverter) ConvertSchemaToThriftType(
 			}
 			result = thriftEnum
 		} else {
-			thriftType = "string"
+			thriftType = c.mapThriftType(schema, openapi3.TypeString)
 		}
 
 	case schema.Type.Includes("integer"):
@@ -910,10 +1294,8 @@ func (c *ThriftConverter) ConvertSchemaToThriftType(
 				})
 			}
 			result = thriftEnum
-		} else if schema.Format == "int32" {
-			thriftType = "i32"
 		} else {
-			thriftType = "i64"
+			thriftType = c.mapThriftType(schema, openapi3.TypeInteger)
 		}
 
 	case schema.Type.Includes("number"):
@@ -935,14 +1317,12 @@ func (c *ThriftConverter) ConvertSchemaToThriftType(
 				})
 			}
 			result = thriftEnum
-		} else if schema.Format == "float" {
-			thriftType = "float"
 		} else {
-			thriftType = "double"
+			thriftType = c.mapThriftType(schema, openapi3.TypeNumber)
 		}
 
 	case schema.Type.Includes("boolean"):
-		thriftType = "bool"
+		thriftType = c.mapThriftType(schema, openapi3.TypeBoolean)
 
 	case schema.Type.Includes("array"):
 		if schema.Items != nil {
@@ -951,30 +1331,32 @@ func (c *ThriftConverter) ConvertSchemaToThriftType(
 				return nil, err
 			}
 
-			fieldType := ""
-			if field, ok := fieldOrMessage.(*thrift.ThriftField); ok {
-				fieldType = field.Type
-			} else if nestedMessage, ok := fieldOrMessage.(*thrift.ThriftStruct); ok {
-				fieldType = nestedMessage.Name
-				c.addMessageToThrift(nestedMessage)
-			} else if enum, ok := fieldOrMessage.(*thrift.ThriftEnum); ok {
-				fieldType = enum.Name
-				c.addEnumToThrift(enum)
-			} else if union, ok := fieldOrMessage.(*thrift.ThriftUnion); ok {
-				fieldType = union.Name
-				c.addUnionToThrift(union)
+			fieldType := c.thriftTypeOf(fieldOrMessage)
+			if _, ok := schema.Extensions["prefixItems"]; ok {
+				c.diagnostics.warn(schema, "prefixItems tuples are not supported and are converted to a list of %s", fieldType)
 			}
 
+			result = c.arrayField(schema, thriftName, fieldType, description)
+		} else {
+			c.diagnostics.warn(schema, "array without items is converted to a list of strings")
+			result = c.arrayField(schema, thriftName, "string", description)
+		}
+
+	case schema.Type.Includes("object"):
+		// An object holding nothing but additionalProperties is a map
+		if parentMessage != nil && len(schema.Properties) == 0 && schema.AdditionalProperties.Schema != nil {
+			valueType, err := c.mapValueType(schema, thriftName, parentMessage)
+			if err != nil {
+				return nil, err
+			}
 			result = &thrift.ThriftField{
 				Name:        c.applyNamingOption(thriftName),
-				Type:        fieldType,
-				Repeated:    true,
+				Type:        "map<string, " + valueType + ">",
 				Description: description,
 			}
+			break
 		}
 
-	case schema.Type.Includes("object"):
-
 		// Regular object handling
 		var message *thrift.ThriftStruct
 		if parentMessage == nil {
@@ -982,14 +1364,27 @@ func (c *ThriftConverter) ConvertSchemaToThriftType(
 		} else {
 			message = &thrift.ThriftStruct{Name: c.applyNamingOption(utils.ToUpperCase(thriftName))}
 		}
+		c.converting[schema] = message.Name
+		defer delete(c.converting, schema)
 
 		// Process each property in the object
-		for propName, propSchema := range schema.Properties {
+		for _, propName := range orderedKeys(schema.Properties, c.specOrder.PropertyOrder(schema), c.converterOption) {
+			propSchema := schema.Properties[propName]
 			thriftType, err := c.ConvertSchemaToThriftType(propSchema, propName, message)
 			if err != nil {
 				return nil, err
 			}
 
+			var fieldID int
+			if propSchema.Value != nil {
+				fieldID, err = c.explicitFieldID(propSchema.Value, propSchema.Value.Extensions)
+				if err != nil {
+					return nil, err
+				}
+			}
+			// A nullable property may hold no value even when it is required
+			required := utils.Contains(schema.Required, propName) && !isNullable(propSchema)
+
 			// Add the converted fields to the message
 			if field, ok := thriftType.(*thrift.ThriftField); ok {
 				if c.converterOption.OpenapiOption {
@@ -1002,13 +1397,17 @@ func (c *ThriftConverter) ConvertSchemaToThriftType(
 					field.Options = append(field.Options, schemaOption)
 					c.AddThriftInclude(openapiThriftFile)
 				}
+				field.ID = fieldID
+				c.applyRequiredness(field, required)
 				message.Fields = append(message.Fields, field)
 			} else if nestedMessage, ok := thriftType.(*thrift.ThriftStruct); ok {
 				var name string
 				if c.converterOption.NamingOption {
 					name = utils.ToSnakeCase(nestedMessage.Name)
 				}
+				c.addInlineStruct(nestedMessage)
 				newField := &thrift.ThriftField{
+					ID:   fieldID,
 					Name: name + "_field",
 					Type: nestedMessage.Name,
 				}
@@ -1022,40 +1421,42 @@ func (c *ThriftConverter) ConvertSchemaToThriftType(
 					newField.Options = append(newField.Options, schemaOption)
 					c.AddThriftInclude(openapiThriftFile)
 				}
-				c.addMessageToThrift(nestedMessage)
+				c.applyRequiredness(newField, required)
 				message.Fields = append(message.Fields, newField)
 			} else if enum, ok := thriftType.(*thrift.ThriftEnum); ok {
-				c.addEnumToThrift(enum)
-				message.Fields = append(message.Fields, &thrift.ThriftField{
+				c.addInlineEnum(enum)
+				enumField := &thrift.ThriftField{
+					ID:   fieldID,
 					Name: c.applyNamingOption(propName),
 					Type: enum.Name,
-				})
+				}
+				c.applyRequiredness(enumField, required)
+				message.Fields = append(message.Fields, enumField)
 			} else if union, ok := thriftType.(*thrift.ThriftUnion); ok {
-				c.addUnionToThrift(union)
-				message.Fields = append(message.Fields, &thrift.ThriftField{
+				c.addInlineUnion(union)
+				unionField := &thrift.ThriftField{
+					ID:   fieldID,
 					Name: c.applyNamingOption(propName),
 					Type: union.Name,
-				})
+				}
+				c.applyRequiredness(unionField, required)
+				message.Fields = append(message.Fields, unionField)
 			}
 		}
 
 		// Handle additionalProperties if present
 		if schema.AdditionalProperties.Schema != nil {
-			mapValueType := "string"
-			additionalPropMessage, err := c.ConvertSchemaToThriftType(schema.AdditionalProperties.Schema, thriftName+"AdditionalProperties", parentMessage)
+			mapValueType, err := c.mapValueType(schema, thriftName, parentMessage)
 			if err != nil {
 				return nil, err
 			}
-			if msg, ok := additionalPropMessage.(*thrift.ThriftStruct); ok {
-				mapValueType = msg.Name
-			} else if enum, ok := additionalPropMessage.(*thrift.ThriftEnum); ok {
-				mapValueType = enum.Name
-			}
 
 			message.Fields = append(message.Fields, &thrift.ThriftField{
 				Name: "additionalProperties",
 				Type: "map<string, " + mapValueType + ">",
 			})
+		} else if schema.AdditionalProperties.Has != nil && *schema.AdditionalProperties.Has {
+			c.diagnostics.drop(schema, "additionalProperties without a schema are dropped")
 		}
 
 		// Set the result as the final message
@@ -1063,6 +1464,16 @@ func (c *ThriftConverter) ConvertSchemaToThriftType(
 		result = message
 	}
 
+	// Schemas without a supported type fall back to strings
+	if result == nil && thriftType == "" {
+		if len(schema.Type.Slice()) == 0 {
+			c.diagnostics.warn(schema, "schema without a type is converted to string")
+		} else {
+			c.diagnostics.warn(schema, "schema of type %s is converted to string", strings.Join(schema.Type.Slice(), ", "))
+		}
+		thriftType = "string"
+	}
+
 	// If result is still nil, construct a default ThriftField
 	if result == nil {
 		result = &thrift.ThriftField{
@@ -1075,12 +1486,22 @@ func (c *ThriftConverter) ConvertSchemaToThriftType(
 	return result, nil
 }
 
-func (c *ThriftConverter) handleOneOf(oneOfSchemas []*openapi3.SchemaRef, thriftName string, parentMessage *thrift.ThriftStruct) (*thrift.ThriftUnion, error) {
+// handleOneOf converts a oneOf schema into a union. The fields of a discriminated union are named
+// after their discriminator values.
+func (c *ThriftConverter) handleOneOf(schema *openapi3.Schema, thriftName string, parentMessage *thrift.ThriftStruct) (*thrift.ThriftUnion, error) {
 	oneOfUnion := &thrift.ThriftUnion{
 		Name: thriftName + "OneOf",
 	}
+	// The union of a top-level oneOf is renamed after its schema by addSchemaDeclaration
+	if parentMessage == nil {
+		c.converting[schema] = thriftName
+	} else {
+		c.converting[schema] = oneOfUnion.Name
+	}
+	defer delete(c.converting, schema)
 
-	for i, schemaRef := range oneOfSchemas {
+	values := discriminatorValues(c.diagnostics, schema, schema.OneOf)
+	for i, schemaRef := range schema.OneOf {
 		fieldName := fmt.Sprintf("%sOption%d", thriftName, i+1)
 		thriftType, err := c.ConvertSchemaToThriftType(schemaRef, fieldName, parentMessage)
 		if err != nil {
@@ -1089,82 +1510,91 @@ func (c *ThriftConverter) handleOneOf(oneOfSchemas []*openapi3.SchemaRef, thrift
 
 		switch v := thriftType.(type) {
 		case *thrift.ThriftField:
+			if values[i] != "" {
+				v.Name = c.applyNamingOption(values[i])
+			}
 			oneOfUnion.Fields = append(oneOfUnion.Fields, v)
 		case *thrift.ThriftStruct:
+			c.addInlineStruct(v)
 			newField := &thrift.ThriftField{
-				Name: v.Name + "_field",
+				Name: c.unionMemberName(values[i], v.Name),
 				Type: v.Name,
 			}
-			c.addMessageToThrift(v)
 			oneOfUnion.Fields = append(oneOfUnion.Fields, newField)
 		case *thrift.ThriftEnum:
+			c.addInlineEnum(v)
 			newField := &thrift.ThriftField{
-				Name: v.Name + "_field",
+				Name: c.unionMemberName(values[i], v.Name),
 				Type: v.Name,
 			}
-			c.addEnumToThrift(v)
 			oneOfUnion.Fields = append(oneOfUnion.Fields, newField)
 		case *thrift.ThriftUnion:
+			c.addInlineUnion(v)
 			newField := &thrift.ThriftField{
-				Name: v.Name + "_field",
+				Name: c.unionMemberName(values[i], v.Name),
 				Type: v.Name,
 			}
-			c.addUnionToThrift(v)
 			oneOfUnion.Fields = append(oneOfUnion.Fields, newField)
 		}
 	}
+	c.addDiscriminatorEnum(schema.Discriminator, values, thriftName, parentMessage)
 
 	return oneOfUnion, nil
 }
 
-func (c *ThriftConverter) handleAllOf(allOfSchemas []*openapi3.SchemaRef, thriftName string, parentMessage *thrift.ThriftStruct) (*thrift.ThriftStruct, error) {
-	allOfStruct := &thrift.ThriftStruct{
-		Name: thriftName + "AllOf",
+// handleAllOf merges the parts of an allOf schema into a single struct. In embed mode the
+// referenced parts are kept as fields of their own type.
+func (c *ThriftConverter) handleAllOf(schema *openapi3.Schema, thriftName string, parentMessage *thrift.ThriftStruct) (interface{}, error) {
+	// A property wrapping a single referenced schema in an allOf only annotates the reference
+	if parentMessage != nil && len(schema.AllOf) == 1 && schema.AllOf[0].Ref != "" && len(schema.Properties) == 0 {
+		return c.ConvertSchemaToThriftType(schema.AllOf[0], thriftName, parentMessage)
 	}
 
-	for i, schemaRef := range allOfSchemas {
-		fieldName := fmt.Sprintf("%sPart%d", thriftName, i+1)
-		thriftType, err := c.ConvertSchemaToThriftType(schemaRef, fieldName, parentMessage)
+	// The merged schema is converted into a struct named as an object schema would be
+	name := thriftName
+	if parentMessage != nil {
+		name = c.applyNamingOption(utils.ToUpperCase(thriftName))
+	}
+	c.converting[schema] = name
+	defer delete(c.converting, schema)
+
+	merged, bases := flattenAllOf(c.diagnostics, c.specOrder, schema, c.converterOption.AllOfOption == AllOfEmbed)
+	thriftType, err := c.ConvertSchemaToThriftType(&openapi3.SchemaRef{Value: merged}, thriftName, parentMessage)
+	if err != nil {
+		return nil, err
+	}
+	message, ok := thriftType.(*thrift.ThriftStruct)
+	if !ok || len(bases) == 0 {
+		return thriftType, nil
+	}
+
+	fields := make([]*thrift.ThriftField, 0, len(bases)+len(message.Fields))
+	for _, base := range bases {
+		baseType, err := c.ConvertSchemaToThriftType(base, thriftName, message)
 		if err != nil {
 			return nil, err
 		}
-
-		switch v := thriftType.(type) {
-		case *thrift.ThriftField:
-			allOfStruct.Fields = append(allOfStruct.Fields, v)
-		case *thrift.ThriftStruct:
-			newField := &thrift.ThriftField{
-				Name: v.Name + "_field",
-				Type: v.Name,
-			}
-			c.addMessageToThrift(v)
-			allOfStruct.Fields = append(allOfStruct.Fields, newField)
-		case *thrift.ThriftEnum:
-			newField := &thrift.ThriftField{
-				Name: v.Name + "_field",
-				Type: v.Name,
-			}
-			c.addEnumToThrift(v)
-			allOfStruct.Fields = append(allOfStruct.Fields, newField)
-		case *thrift.ThriftUnion:
-			newField := &thrift.ThriftField{
-				Name: v.Name + "_field",
-				Type: v.Name,
-			}
-			c.addUnionToThrift(v)
-			allOfStruct.Fields = append(allOfStruct.Fields, newField)
+		if field, ok := baseType.(*thrift.ThriftField); ok {
+			// Every value of the schema holds its bases
+			c.applyRequiredness(field, true)
+			fields = append(fields, field)
 		}
 	}
-
-	return allOfStruct, nil
+	message.Fields = append(fields, message.Fields...)
+	return message, nil
 }
 
-func (c *ThriftConverter) handleAnyOf(anyOfSchemas []*openapi3.SchemaRef, thriftName string, parentMessage *thrift.ThriftStruct) (*thrift.ThriftStruct, error) {
+// handleAnyOf converts an anyOf schema into a struct. The fields of a discriminated anyOf are named
+// after their discriminator values.
+func (c *ThriftConverter) handleAnyOf(schema *openapi3.Schema, thriftName string, parentMessage *thrift.ThriftStruct) (*thrift.ThriftStruct, error) {
 	anyOfStruct := &thrift.ThriftStruct{
 		Name: thriftName + "AnyOf",
 	}
+	c.converting[schema] = anyOfStruct.Name
+	defer delete(c.converting, schema)
 
-	for i, schemaRef := range anyOfSchemas {
+	values := discriminatorValues(c.diagnostics, schema, schema.AnyOf)
+	for i, schemaRef := range schema.AnyOf {
 		fieldName := fmt.Sprintf("%sOption%d", thriftName, i+1)
 		thriftType, err := c.ConvertSchemaToThriftType(schemaRef, fieldName, parentMessage)
 		if err != nil {
@@ -1173,34 +1603,390 @@ func (c *ThriftConverter) handleAnyOf(anyOfSchemas []*openapi3.SchemaRef, thrift
 
 		switch v := thriftType.(type) {
 		case *thrift.ThriftField:
+			if values[i] != "" {
+				v.Name = c.applyNamingOption(values[i])
+			}
 			anyOfStruct.Fields = append(anyOfStruct.Fields, v)
 		case *thrift.ThriftStruct:
+			c.addInlineStruct(v)
 			newField := &thrift.ThriftField{
-				Name: v.Name + "_field",
+				Name: c.unionMemberName(values[i], v.Name),
 				Type: v.Name,
 			}
-			c.addMessageToThrift(v)
 			anyOfStruct.Fields = append(anyOfStruct.Fields, newField)
 		case *thrift.ThriftEnum:
+			c.addInlineEnum(v)
 			newField := &thrift.ThriftField{
-				Name: v.Name + "_field",
+				Name: c.unionMemberName(values[i], v.Name),
 				Type: v.Name,
 			}
-			c.addEnumToThrift(v)
 			anyOfStruct.Fields = append(anyOfStruct.Fields, newField)
 		case *thrift.ThriftUnion:
+			c.addInlineUnion(v)
 			newField := &thrift.ThriftField{
-				Name: v.Name + "_field",
+				Name: c.unionMemberName(values[i], v.Name),
 				Type: v.Name,
 			}
-			c.addUnionToThrift(v)
 			anyOfStruct.Fields = append(anyOfStruct.Fields, newField)
 		}
 	}
+	c.addDiscriminatorEnum(schema.Discriminator, values, thriftName, parentMessage)
 
 	return anyOfStruct, nil
 }
 
+// unionMemberName names the field of a union or anyOf struct holding an inline type after its
+// discriminator value, or after the type without one
+func (c *ThriftConverter) unionMemberName(value, typeName string) string {
+	if value != "" {
+		return c.applyNamingOption(value)
+	}
+	return typeName + "_field"
+}
+
+// addDiscriminatorEnum declares an enum of the discriminator values of a oneOf or anyOf if the
+// discriminator enum is enabled
+func (c *ThriftConverter) addDiscriminatorEnum(discriminator *openapi3.Discriminator, values []string, thriftName string, parentMessage *thrift.ThriftStruct) {
+	if !c.converterOption.DiscriminatorEnum || discriminator == nil {
+		return
+	}
+
+	name := thriftName
+	if parentMessage != nil {
+		name = c.applyNamingOption(utils.ToUpperCase(thriftName))
+	}
+	enum := &thrift.ThriftEnum{Name: name + utils.ToUpperCase(discriminator.PropertyName)}
+	for _, value := range values {
+		if value != "" {
+			enum.Values = append(enum.Values, &thrift.ThriftEnumValue{
+				Index: len(enum.Values),
+				Value: discriminatorEnumValue(enum.Name, value),
+			})
+		}
+	}
+	if len(enum.Values) > 0 {
+		c.addInlineEnum(enum)
+	}
+}
+
+// declaredTypes returns the names of the typedefs, structs, unions and enums of the ThriftFile
+func (c *ThriftConverter) declaredTypes() map[string]bool {
+	names := make(map[string]bool)
+	for _, typedef := range c.ThriftFile.Typedefs {
+		names[typedef.Name] = true
+	}
+	for _, message := range c.ThriftFile.Structs {
+		names[message.Name] = true
+	}
+	for _, union := range c.ThriftFile.Unions {
+		names[union.Name] = true
+	}
+	for _, enum := range c.ThriftFile.Enums {
+		names[enum.Name] = true
+	}
+	return names
+}
+
+// sortDeclarations sorts typedefs, structs, unions, enums, services, methods and struct fields by name
+func (c *ThriftConverter) sortDeclarations() {
+	sort.SliceStable(c.ThriftFile.Typedefs, func(i, j int) bool {
+		return c.ThriftFile.Typedefs[i].Name < c.ThriftFile.Typedefs[j].Name
+	})
+	sort.SliceStable(c.ThriftFile.Enums, func(i, j int) bool {
+		return c.ThriftFile.Enums[i].Name < c.ThriftFile.Enums[j].Name
+	})
+	sort.SliceStable(c.ThriftFile.Structs, func(i, j int) bool {
+		return c.ThriftFile.Structs[i].Name < c.ThriftFile.Structs[j].Name
+	})
+	sort.SliceStable(c.ThriftFile.Unions, func(i, j int) bool {
+		return c.ThriftFile.Unions[i].Name < c.ThriftFile.Unions[j].Name
+	})
+	sort.SliceStable(c.ThriftFile.Services, func(i, j int) bool {
+		return c.ThriftFile.Services[i].Name < c.ThriftFile.Services[j].Name
+	})
+	for _, service := range c.ThriftFile.Services {
+		sort.SliceStable(service.Methods, func(i, j int) bool {
+			return service.Methods[i].Name < service.Methods[j].Name
+		})
+	}
+	for _, message := range c.ThriftFile.Structs {
+		sort.SliceStable(message.Fields, func(i, j int) bool {
+			return message.Fields[i].Name < message.Fields[j].Name
+		})
+	}
+}
+
+// assignFieldIDs assigns IDs to the fields of every struct and union in the ThriftFile
+func (c *ThriftConverter) assignFieldIDs() error {
+	for _, message := range c.ThriftFile.Structs {
+		if err := checkExplicitFieldIDs(message.Name, message.Fields); err != nil {
+			return err
+		}
+		message.Reserved = c.assignStructFieldIDs(message.Name, message.Fields, message.Reserved)
+	}
+	for _, union := range c.ThriftFile.Unions {
+		if err := checkExplicitFieldIDs(union.Name, union.Fields); err != nil {
+			return err
+		}
+		union.Reserved = c.assignStructFieldIDs(union.Name, union.Fields, union.Reserved)
+	}
+	return nil
+}
+
+// explicitFieldID returns the field ID pinned by the extensions of a property or parameter node, or
+// 0 if none is. A pinned ID that is not an integer from 1 to thrift.MaxFieldID is an error locating
+// the node.
+func (c *ThriftConverter) explicitFieldID(node interface{}, extensions map[string]interface{}) (int, error) {
+	id, ok, err := utils.GetIntExtension(extensions, fieldIDExtensions...)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", c.diagnostics.pointers.Lookup(node), err)
+	}
+	if ok && (id < 1 || id > thrift.MaxFieldID) {
+		return 0, fmt.Errorf("%s: field ID %d is out of the range 1 to %d", c.diagnostics.pointers.Lookup(node), id, thrift.MaxFieldID)
+	}
+	return id, nil
+}
+
+// checkExplicitFieldIDs rejects two fields of a struct pinned to the same ID by an extension
+func checkExplicitFieldIDs(structName string, fields []*thrift.ThriftField) error {
+	owners := make(map[int]string)
+	for _, field := range fields {
+		if field.ID <= 0 {
+			continue
+		}
+		if other, ok := owners[field.ID]; ok && other != field.Name {
+			return fmt.Errorf("fields %s and %s of %s declare the same field ID %d", other, field.Name, structName, field.ID)
+		}
+		owners[field.ID] = field.Name
+	}
+	return nil
+}
+
+// assignStructFieldIDs numbers the fields of a struct and returns its reserved IDs. Explicit IDs
+// are kept, locked IDs are reused and the remaining fields are numbered after the highest used ID.
+func (c *ThriftConverter) assignStructFieldIDs(structName string, fields []*thrift.ThriftField, reserved []int) []int {
+	used := make(map[int]bool)
+	for _, id := range reserved {
+		used[id] = true
+	}
+	for _, field := range fields {
+		if field.ID > 0 {
+			used[field.ID] = true
+		}
+	}
+
+	locked := c.fieldLock.Lookup(structName)
+	present := make(map[string]bool)
+	next := 1
+	if locked != nil {
+		for _, id := range locked.Reserved {
+			if !used[id] {
+				reserved = append(reserved, id)
+				used[id] = true
+			}
+		}
+		for _, field := range fields {
+			name := utils.FormatStr(field.Name)
+			present[name] = true
+			if id, ok := locked.Fields[name]; ok && field.ID == 0 && !used[id] {
+				field.ID = id
+				used[id] = true
+			}
+		}
+
+		// Never reuse the IDs of removed fields
+		for name, id := range locked.Fields {
+			if !present[name] && !used[id] {
+				reserved = append(reserved, id)
+				used[id] = true
+			}
+		}
+		for id := range used {
+			if id >= next {
+				next = id + 1
+			}
+		}
+	}
+
+	for _, field := range fields {
+		if field.ID > 0 {
+			continue
+		}
+		for used[next] {
+			next++
+		}
+		field.ID = next
+		used[next] = true
+	}
+	sort.Ints(reserved)
+
+	// Record the IDs of this conversion
+	sl := c.usedLock.Struct(structName)
+	for _, field := range fields {
+		sl.Fields[utils.FormatStr(field.Name)] = field.ID
+	}
+	sl.Reserved = append([]int{}, reserved...)
+
+	return reserved
+}
+
+// applyRequiredness marks a field as required or optional according to the requiredness policy.
+// Required fields are `required` under the strict policy and default-requiredness otherwise.
+func (c *ThriftConverter) applyRequiredness(field *thrift.ThriftField, required bool) {
+	if required {
+		field.Required = c.converterOption.RequirednessOption == RequirednessStrict
+		field.Optional = false
+	} else {
+		field.Required = false
+		field.Optional = true
+	}
+}
+
+// addHeaderFields converts a response header into fields of message
+func (c *ThriftConverter) addHeaderFields(message *thrift.ThriftStruct, headerName string, headerRef *openapi3.HeaderRef) error {
+	if headerRef.Value == nil || headerRef.Value.Schema == nil {
+		c.diagnostics.drop(headerRef.Value, "header %s has no schema and is dropped", headerName)
+		return nil
+	}
+
+	fieldOrMessage, err := c.ConvertSchemaToThriftType(headerRef.Value.Schema, headerName, message)
+	if err != nil {
+		return err
+	}
+
+	switch v := fieldOrMessage.(type) {
+	case *thrift.ThriftField:
+		if c.converterOption.ApiOption {
+			option := &thrift.Option{
+				Name:  "api.header",
+				Value: fmt.Sprintf("%q", headerName),
+			}
+			v.Options = append(v.Options, option)
+		}
+		if c.converterOption.OpenapiOption {
+			optionStr := utils.StructToOption(headerRef.Value, "     ")
+
+			schemaOption := &thrift.Option{
+				Name:  openapiPropertyOption,
+				Value: optionStr,
+			}
+			v.Options = append(v.Options, schemaOption)
+			c.AddThriftInclude(openapiThriftFile)
+		}
+		c.applyRequiredness(v, headerRef.Value.Required && !isNullable(headerRef.Value.Schema))
+		c.addFieldIfNotExists(&message.Fields, v)
+	case *thrift.ThriftStruct:
+		for _, field := range v.Fields {
+			if c.converterOption.ApiOption {
+				option := &thrift.Option{
+					Name:  "api.header",
+					Value: fmt.Sprintf("%q", field.Name),
+				}
+				field.Options = append(field.Options, option)
+			}
+			if c.converterOption.OpenapiOption {
+				optionStr := utils.StructToOption(headerRef.Value, "     ")
+
+				schemaOption := &thrift.Option{
+					Name:  openapiPropertyOption,
+					Value: optionStr,
+				}
+				field.Options = append(field.Options, schemaOption)
+				c.AddThriftInclude(openapiThriftFile)
+			}
+			c.addFieldIfNotExists(&message.Fields, field)
+		}
+	case *thrift.ThriftEnum:
+		c.addInlineEnum(v)
+		name := headerName
+		if c.converterOption.NamingOption {
+			name = utils.ToSnakeCase(name)
+		}
+		newField := &thrift.ThriftField{
+			Name: name + "_field",
+			Type: v.Name,
+		}
+		if c.converterOption.ApiOption {
+			option := &thrift.Option{
+				Name:  "api.header",
+				Value: fmt.Sprintf("%q", headerName),
+			}
+			newField.Options = append(newField.Options, option)
+		}
+		if c.converterOption.OpenapiOption {
+			optionStr := utils.StructToOption(headerRef.Value, "     ")
+
+			schemaOption := &thrift.Option{
+				Name:  openapiPropertyOption,
+				Value: optionStr,
+			}
+			newField.Options = append(newField.Options, schemaOption)
+			c.AddThriftInclude(openapiThriftFile)
+		}
+		message.Fields = append(message.Fields, newField)
+	case *thrift.ThriftUnion:
+		c.addInlineUnion(v)
+		name := headerName
+		if c.converterOption.NamingOption {
+			name = utils.ToSnakeCase(name)
+		}
+		newField := &thrift.ThriftField{
+			Name: name + "_field",
+			Type: v.Name,
+		}
+		if c.converterOption.ApiOption {
+			option := &thrift.Option{
+				Name:  "api.header",
+				Value: fmt.Sprintf("%q", headerName),
+			}
+			newField.Options = append(newField.Options, option)
+		}
+		if c.converterOption.OpenapiOption {
+			optionStr := utils.StructToOption(headerRef.Value, "     ")
+
+			schemaOption := &thrift.Option{
+				Name:  openapiPropertyOption,
+				Value: optionStr,
+			}
+			newField.Options = append(newField.Options, schemaOption)
+			c.AddThriftInclude(openapiThriftFile)
+		}
+		message.Fields = append(message.Fields, newField)
+	}
+
+	return nil
+}
+
+// headerFragmentField declares the fragment struct of a referenced header the first time it is
+// met and returns a field of the fragment type
+func (c *ThriftConverter) headerFragmentField(headerName string, headerRef *openapi3.HeaderRef) (*thrift.ThriftField, error) {
+	name, ok := c.fragments[headerRef.Value]
+	if !ok {
+		name = c.refTypeName(headerRef.Ref, "Header")
+		fragment := &thrift.ThriftStruct{Name: name}
+		c.addMessageToThrift(fragment)
+		name = fragment.Name
+		c.fragments[headerRef.Value] = name
+		if err := c.addHeaderFields(fragment, headerName, headerRef); err != nil {
+			return nil, err
+		}
+	}
+	return &thrift.ThriftField{
+		Name: c.applyNamingOption(headerName),
+		Type: name,
+	}, nil
+}
+
+// refTypeName names the declaration of a referenced component after the component, adding suffix
+// if the name is already declared
+func (c *ThriftConverter) refTypeName(ref, suffix string) string {
+	name := c.applyNamingOption(utils.ExtractMessageNameFromRef(ref))
+	if declared := c.declaredTypes(); declared[name] {
+		name = uniqueName(name+suffix, declared)
+	}
+	return name
+}
+
 // applyNamingOption applies naming convention based on the converter's naming option
 func (c *ThriftConverter) applyNamingOption(name string) string {
 	if c.converterOption.NamingOption {
@@ -1209,42 +1995,107 @@ func (c *ThriftConverter) applyNamingOption(name string) string {
 	return name
 }
 
-// addMessageToThrift adds a ThriftStruct to the ThriftFile globally
+// addMessageToThrift adds a struct to the ThriftFile. A struct named like an existing declaration
+// is renamed unless it is identical to it, so it must be referenced by its name once added.
 func (c *ThriftConverter) addMessageToThrift(message *thrift.ThriftStruct) error {
 	if message == nil {
 		return errors.New("message is nil")
 	}
 
-	// Check if the message already exists in the ThriftFile
 	for _, existingMessage := range c.ThriftFile.Structs {
 		if existingMessage.Name == message.Name {
-			// Merge fields if the message already exists
-			fieldNames := make(map[string]struct{})
-			for _, field := range existingMessage.Fields {
-				fieldNames[field.Name] = struct{}{}
-			}
-			for _, newField := range message.Fields {
-				if _, exists := fieldNames[newField.Name]; !exists {
-					existingMessage.Fields = append(existingMessage.Fields, newField)
-				}
+			if existingMessage == message || thriftStructKey(existingMessage, false) == thriftStructKey(message, false) {
+				return nil
 			}
-			return nil
+			break
 		}
 	}
+	if declared := c.declaredTypes(); declared[message.Name] {
+		name := uniqueName(message.Name, declared)
+		retargetThriftFields(message.Fields, map[string]string{message.Name: name})
+		message.Name = name
+	}
 
-	// Add the message globally
 	c.ThriftFile.Structs = append(c.ThriftFile.Structs, message)
+	c.recordSource(message.Name)
 	return nil
 }
 
-// addEnumToThrift adds an enum to the ThriftFile
+// addInlineStruct adds the struct of an inline schema to the ThriftFile
+func (c *ThriftConverter) addInlineStruct(message *thrift.ThriftStruct) {
+	name := message.Name
+	declared := c.declaredTypes()[name]
+	c.addMessageToThrift(message)
+	if !declared || message.Name != name {
+		c.inlineTypes[message.Name] = true
+	}
+}
+
+// addEnumToThrift adds an enum to the ThriftFile. An enum named like an existing declaration is
+// renamed unless it is identical to it, so it must be referenced by its name once added.
 func (c *ThriftConverter) addEnumToThrift(enum *thrift.ThriftEnum) {
+	for _, existingEnum := range c.ThriftFile.Enums {
+		if existingEnum.Name == enum.Name {
+			if existingEnum == enum || thriftEnumKey(existingEnum) == thriftEnumKey(enum) {
+				return
+			}
+			break
+		}
+	}
+	if declared := c.declaredTypes(); declared[enum.Name] {
+		enum.Name = uniqueName(enum.Name, declared)
+	}
+
 	c.ThriftFile.Enums = append(c.ThriftFile.Enums, enum)
+	c.recordSource(enum.Name)
 }
 
-// addUnionToThrift adds a union to the ThriftFile
+// addUnionToThrift adds a union to the ThriftFile. A union named like an existing declaration is
+// renamed unless it is identical to it, so it must be referenced by its name once added.
 func (c *ThriftConverter) addUnionToThrift(union *thrift.ThriftUnion) {
+	for _, existingUnion := range c.ThriftFile.Unions {
+		if existingUnion.Name == union.Name {
+			if existingUnion == union || thriftUnionKey(existingUnion, false) == thriftUnionKey(union, false) {
+				return
+			}
+			break
+		}
+	}
+	if declared := c.declaredTypes(); declared[union.Name] {
+		name := uniqueName(union.Name, declared)
+		retargetThriftFields(union.Fields, map[string]string{union.Name: name})
+		union.Name = name
+	}
+
 	c.ThriftFile.Unions = append(c.ThriftFile.Unions, union)
+	c.recordSource(union.Name)
+}
+
+// addInlineEnum adds the enum of an inline schema to the ThriftFile
+func (c *ThriftConverter) addInlineEnum(enum *thrift.ThriftEnum) {
+	name := enum.Name
+	declared := c.declaredTypes()[name]
+	c.addEnumToThrift(enum)
+	if !declared || enum.Name != name {
+		c.inlineTypes[enum.Name] = true
+	}
+}
+
+// addInlineUnion adds the union of an inline schema to the ThriftFile
+func (c *ThriftConverter) addInlineUnion(union *thrift.ThriftUnion) {
+	name := union.Name
+	declared := c.declaredTypes()[name]
+	c.addUnionToThrift(union)
+	if !declared || union.Name != name {
+		c.inlineTypes[union.Name] = true
+	}
+}
+
+// recordSource remembers the external document a new top-level declaration comes from
+func (c *ThriftConverter) recordSource(name string) {
+	if c.currentSource != "" {
+		c.declSources[name] = c.currentSource
+	}
 }
 
 // AddThriftInclude adds an include to the ThriftFile
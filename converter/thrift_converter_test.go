@@ -0,0 +1,462 @@
+package converter
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/hertz-contrib/swagger-generate/swagger2idl/parser"
+	"github.com/hertz-contrib/swagger-generate/swagger2idl/thrift"
+)
+
+// loadSpec loads an OpenAPI document given as YAML
+func loadSpec(t *testing.T, data string) *openapi3.T {
+	t.Helper()
+	spec, err := parser.LoadOpenAPISpecData([]byte(data), "openapi.yaml")
+	if err != nil {
+		t.Fatalf("LoadOpenAPISpecData() error = %v", err)
+	}
+	return spec
+}
+
+// thriftStruct returns the named struct of a ThriftFile
+func thriftStruct(t *testing.T, file *thrift.ThriftFile, name string) *thrift.ThriftStruct {
+	t.Helper()
+	for _, message := range file.Structs {
+		if message.Name == name {
+			return message
+		}
+	}
+	t.Fatalf("struct %s not found", name)
+	return nil
+}
+
+func TestThriftConverterFieldIDs(t *testing.T) {
+	tests := []struct {
+		name         string
+		properties   string
+		lock         func() *thrift.FieldLock
+		wantIDs      map[string]int
+		wantReserved []int
+		wantErr      string
+	}{
+		{
+			name: "numbered by name",
+			properties: `
+        b: {type: string}
+        a: {type: string}`,
+			wantIDs: map[string]int{"A": 1, "B": 2},
+		},
+		{
+			name: "explicit IDs",
+			properties: `
+        a: {type: string, x-thrift-id: 5}
+        b: {type: string, x-field-id: 1}
+        c: {type: string}`,
+			wantIDs: map[string]int{"A": 5, "B": 1, "C": 2},
+		},
+		{
+			name: "locked IDs",
+			properties: `
+        a: {type: string}
+        c: {type: string}`,
+			lock: func() *thrift.FieldLock {
+				lock := thrift.NewFieldLock()
+				sl := lock.Struct("Item")
+				sl.Fields["C"] = 3
+				sl.Fields["B"] = 2
+				sl.Reserved = []int{4}
+				return lock
+			},
+			wantIDs:      map[string]int{"A": 5, "C": 3},
+			wantReserved: []int{2, 4},
+		},
+		{
+			name: "duplicate explicit IDs",
+			properties: `
+        a: {type: string, x-thrift-id: 2}
+        b: {type: string, x-field-id: 2}`,
+			wantErr: "declare the same field ID 2",
+		},
+		{
+			name: "fractional explicit ID",
+			properties: `
+        a: {type: string, x-thrift-id: 1.5}`,
+			wantErr: "#/components/schemas/Item/properties/a: x-thrift-id must be an integer, got 1.5",
+		},
+		{
+			name: "non-numeric explicit ID",
+			properties: `
+        a: {type: string, x-field-id: abc}`,
+			wantErr: "#/components/schemas/Item/properties/a: x-field-id must be an integer, got abc",
+		},
+		{
+			name: "zero explicit ID",
+			properties: `
+        a: {type: string, x-thrift-id: 0}`,
+			wantErr: "#/components/schemas/Item/properties/a: field ID 0 is out of the range 1 to 32767",
+		},
+		{
+			name: "explicit ID above the maximum",
+			properties: `
+        a: {type: string, x-thrift-id: 32768}`,
+			wantErr: "field ID 32768 is out of the range 1 to 32767",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := loadSpec(t, `openapi: 3.0.3
+info: {title: test, version: "1"}
+paths: {}
+components:
+  schemas:
+    Item:
+      type: object
+      properties:`+tt.properties+"\n")
+			c := NewThriftConverter(spec, &ConvertOption{NamingOption: true})
+			if tt.lock != nil {
+				c.SetFieldLock(tt.lock())
+			}
+			err := c.Convert()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Convert() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Convert() error = %v", err)
+			}
+
+			item := thriftStruct(t, c.ThriftFile, "Item")
+			ids := make(map[string]int)
+			for _, field := range item.Fields {
+				ids[field.Name] = field.ID
+			}
+			if len(ids) != len(tt.wantIDs) {
+				t.Errorf("field IDs = %v, want %v", ids, tt.wantIDs)
+			}
+			for name, id := range tt.wantIDs {
+				if ids[name] != id {
+					t.Errorf("ID of %s = %d, want %d", name, ids[name], id)
+				}
+			}
+			if len(item.Reserved) != len(tt.wantReserved) {
+				t.Fatalf("reserved = %v, want %v", item.Reserved, tt.wantReserved)
+			}
+			for i, id := range tt.wantReserved {
+				if item.Reserved[i] != id {
+					t.Errorf("reserved = %v, want %v", item.Reserved, tt.wantReserved)
+				}
+			}
+			if used := c.FieldLock().Lookup("Item"); used == nil || used.Fields["A"] != tt.wantIDs["A"] {
+				t.Errorf("FieldLock() = %+v, does not record the ID of A", used)
+			}
+		})
+	}
+}
+
+// thriftUnion returns the named union of a ThriftFile
+func thriftUnion(t *testing.T, file *thrift.ThriftFile, name string) *thrift.ThriftUnion {
+	t.Helper()
+	for _, union := range file.Unions {
+		if union.Name == name {
+			return union
+		}
+	}
+	t.Fatalf("union %s not found", name)
+	return nil
+}
+
+// thriftFields describes the fields of a struct or union as name and type
+func thriftFields(fields []*thrift.ThriftField) []string {
+	var described []string
+	for _, field := range fields {
+		described = append(described, field.Name+" "+field.Type)
+	}
+	return described
+}
+
+func TestThriftConverterUnionMembers(t *testing.T) {
+	tests := []struct {
+		name        string
+		union       string
+		wantMembers []string
+	}{
+		{
+			name: "mapped references",
+			union: `
+          oneOf:
+            - $ref: '#/components/schemas/Cat'
+            - $ref: '#/components/schemas/Dog'
+          discriminator:
+            propertyName: kind
+            mapping:
+              cat: '#/components/schemas/Cat'
+              dog: Dog`,
+			wantMembers: []string{"cat Cat", "dog Dog"},
+		},
+		{
+			name: "inline discriminated branches",
+			union: `
+          oneOf:
+            - type: object
+              properties:
+                kind: {type: string, enum: [cat]}
+            - type: object
+              properties:
+                kind: {type: string, enum: [dog]}
+          discriminator:
+            propertyName: kind`,
+			wantMembers: []string{"cat PetOption1", "dog PetOption2"},
+		},
+		{
+			name: "undiscriminated inline branch",
+			union: `
+          oneOf:
+            - $ref: '#/components/schemas/Cat'
+            - type: string
+              enum: [a, b]`,
+			wantMembers: []string{"Cat Cat", "PetOption2Enum_field PetOption2Enum"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := loadSpec(t, `openapi: 3.0.3
+info: {title: test, version: "1"}
+paths: {}
+components:
+  schemas:
+    Cat:
+      type: object
+      properties:
+        kind: {type: string}
+    Dog:
+      type: object
+      properties:
+        kind: {type: string}
+    Holder:
+      type: object
+      properties:
+        pet:`+tt.union+"\n")
+			c := NewThriftConverter(spec, &ConvertOption{})
+			if err := c.Convert(); err != nil {
+				t.Fatalf("Convert() error = %v", err)
+			}
+
+			if got := thriftFields(thriftUnion(t, c.ThriftFile, "petOneOf").Fields); !equalStrings(got, tt.wantMembers) {
+				t.Errorf("members = %v, want %v", got, tt.wantMembers)
+			}
+		})
+	}
+}
+
+func TestThriftConverterParameterFragments(t *testing.T) {
+	c := NewThriftConverter(loadSpec(t, fragmentSpec), &ConvertOption{
+		ApiOption:      true,
+		NamingOption:   true,
+		FragmentOption: FragmentReference,
+	})
+	if err := c.Convert(); err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+
+	tests := []struct {
+		message    string
+		wantFields []string
+	}{
+		{message: "ListUsersRequest", wantFields: []string{"PagePageSizeParameters PagePageSizeParameters", "Q string", "XRequestId RequestId"}},
+		{message: "GetUserRequest", wantFields: []string{"Id string", "XRequestId RequestId"}},
+		{message: "PagePageSizeParameters", wantFields: []string{"Page i64", "PageSize i64"}},
+	}
+	for _, tt := range tests {
+		if got := thriftFields(thriftStruct(t, c.ThriftFile, tt.message).Fields); !equalStrings(got, tt.wantFields) {
+			t.Errorf("fields of %s = %v, want %v", tt.message, got, tt.wantFields)
+		}
+	}
+	for _, field := range thriftStruct(t, c.ThriftFile, "PagePageSizeParameters").Fields {
+		if len(field.Options) != 1 || field.Options[0].Name != "api.query" {
+			t.Errorf("options of %s = %v, want an api.query annotation", field.Name, field.Options)
+		}
+	}
+}
+
+func TestThriftConverterSharedBodies(t *testing.T) {
+	c := NewThriftConverter(loadSpec(t, sharedBodySpec), &ConvertOption{NamingOption: true})
+	if err := c.Convert(); err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+
+	tests := []struct {
+		message    string
+		wantFields []string
+	}{
+		{message: "PetBody", wantFields: []string{"Name string"}},
+		{message: "UpdatePetRequest", wantFields: []string{"Id string", "PetBody PetBody"}},
+		// The empty 204 response is no field of the wrapper
+		{message: "UpdatePetResponse", wantFields: []string{"response_200 PetResponse"}},
+	}
+	for _, tt := range tests {
+		if got := thriftFields(thriftStruct(t, c.ThriftFile, tt.message).Fields); !equalStrings(got, tt.wantFields) {
+			t.Errorf("fields of %s = %v, want %v", tt.message, got, tt.wantFields)
+		}
+	}
+}
+
+func TestThriftConverterRequiredness(t *testing.T) {
+	spec := `openapi: 3.0.3
+info: {title: test, version: "1"}
+paths:
+  /items:
+    get:
+      operationId: listItems
+      parameters:
+        - {name: page, in: query, required: true, schema: {type: integer}}
+        - {name: size, in: query, schema: {type: integer}}
+      responses:
+        "200": {description: ok}
+components:
+  schemas:
+    Item:
+      type: object
+      required: [name, note]
+      properties:
+        name: {type: string}
+        note: {type: string, nullable: true}
+        tag: {type: string}
+`
+	tests := []struct {
+		policy string
+		want   map[string]string
+	}{
+		{
+			policy: RequirednessLenient,
+			want:   map[string]string{"Name": "default", "Note": "optional", "Tag": "optional", "Page": "default", "Size": "optional"},
+		},
+		{
+			policy: RequirednessStrict,
+			want:   map[string]string{"Name": "required", "Note": "optional", "Tag": "optional", "Page": "required", "Size": "optional"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			c := NewThriftConverter(loadSpec(t, spec), &ConvertOption{NamingOption: true, RequirednessOption: tt.policy})
+			if err := c.Convert(); err != nil {
+				t.Fatalf("Convert() error = %v", err)
+			}
+
+			got := make(map[string]string)
+			for _, name := range []string{"Item", "ListItemsRequest"} {
+				for _, field := range thriftStruct(t, c.ThriftFile, name).Fields {
+					switch {
+					case field.Required:
+						got[field.Name] = "required"
+					case field.Optional:
+						got[field.Name] = "optional"
+					default:
+						got[field.Name] = "default"
+					}
+				}
+			}
+			for name, want := range tt.want {
+				if got[name] != want {
+					t.Errorf("requiredness of %s = %q, want %q", name, got[name], want)
+				}
+			}
+		})
+	}
+}
+
+func TestThriftConverterAllOf(t *testing.T) {
+	c := NewThriftConverter(loadSpec(t, allOfSpec), &ConvertOption{
+		AllOfOption:        AllOfFlatten,
+		RequirednessOption: RequirednessStrict,
+	})
+	if err := c.Convert(); err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+
+	// The merged struct requires the properties required by any of the parts
+	var got []string
+	for _, field := range thriftStruct(t, c.ThriftFile, "Pet").Fields {
+		got = append(got, fmt.Sprintf("%s %s required=%v", field.Name, field.Type, field.Required))
+	}
+	want := []string{"created i64 required=false", "id string required=true", "name string required=true"}
+	if !equalStrings(got, want) {
+		t.Errorf("fields = %v, want %v", got, want)
+	}
+}
+
+func TestThriftConverterContainers(t *testing.T) {
+	c := NewThriftConverter(loadSpec(t, `openapi: 3.0.3
+info: {title: test, version: "1"}
+paths: {}
+components:
+  schemas:
+    Counts:
+      type: object
+      additionalProperties: {type: integer, format: int32}
+    Tree:
+      type: object
+      additionalProperties: {$ref: '#/components/schemas/Tree'}
+    Item:
+      type: object
+      properties:
+        tags:
+          type: array
+          uniqueItems: true
+          items: {type: string}
+        names:
+          type: array
+          items: {type: string}
+        scores:
+          type: object
+          additionalProperties: {type: number}
+        groups:
+          type: object
+          additionalProperties:
+            type: array
+            items: {type: integer}
+        counts: {$ref: '#/components/schemas/Counts'}
+`), &ConvertOption{})
+	if err := c.Convert(); err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+
+	// A pure map is a typedef, unless its values refer to the map itself
+	var typedefs []string
+	for _, typedef := range c.ThriftFile.Typedefs {
+		typedefs = append(typedefs, typedef.Name+" "+typedef.Type)
+	}
+	if want := []string{"Counts map<string, i32>"}; !equalStrings(typedefs, want) {
+		t.Errorf("typedefs = %v, want %v", typedefs, want)
+	}
+	thriftStruct(t, c.ThriftFile, "Tree")
+
+	tests := []struct {
+		field    string
+		wantType string
+		repeated bool
+	}{
+		{field: "tags", wantType: "set<string>"},
+		{field: "names", wantType: "string", repeated: true},
+		{field: "scores", wantType: "map<string, double>"},
+		{field: "groups", wantType: "map<string, list<i64>>"},
+		{field: "Counts", wantType: "Counts"},
+	}
+	fields := make(map[string]*thrift.ThriftField)
+	for _, field := range thriftStruct(t, c.ThriftFile, "Item").Fields {
+		fields[field.Name] = field
+	}
+	for _, tt := range tests {
+		field, ok := fields[tt.field]
+		if !ok {
+			t.Errorf("field %s not found in %v", tt.field, thriftFields(thriftStruct(t, c.ThriftFile, "Item").Fields))
+			continue
+		}
+		if field.Type != tt.wantType || field.Repeated != tt.repeated {
+			t.Errorf("field %s = %s repeated=%v, want %s repeated=%v", tt.field, field.Type, field.Repeated, tt.wantType, tt.repeated)
+		}
+	}
+}
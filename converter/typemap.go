@@ -0,0 +1,60 @@
+package converter
+
+import (
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/hertz-contrib/swagger-generate/swagger2idl/parser"
+)
+
+// defaultTypeMappings maps the scalar schemas that no user type mapping matches. Each type ends
+// with a mapping of any format, so every scalar schema is mapped.
+var defaultTypeMappings = []parser.TypeMapping{
+	{Type: openapi3.TypeString, Format: "date", Proto: "google.protobuf.Timestamp", ProtoImport: timestampProtoFile, Thrift: "string"},
+	{Type: openapi3.TypeString, Format: "date-time", Proto: "google.protobuf.Timestamp", ProtoImport: timestampProtoFile, Thrift: "string"},
+	{Type: openapi3.TypeString, Format: "byte", Proto: "bytes", Thrift: "binary"},
+	{Type: openapi3.TypeString, Format: "binary", Proto: "bytes", Thrift: "binary"},
+	{Type: openapi3.TypeString, Proto: "string", Thrift: "string"},
+	{Type: openapi3.TypeInteger, Format: "int32", Proto: "int32", Thrift: "i32"},
+	{Type: openapi3.TypeInteger, Proto: "int64", Thrift: "i64"},
+	{Type: openapi3.TypeNumber, Format: "float", Proto: "float", Thrift: "float"},
+	{Type: openapi3.TypeNumber, Proto: "double", Thrift: "double"},
+	{Type: openapi3.TypeBoolean, Proto: "bool", Thrift: "bool"},
+}
+
+// typeMappings returns the user type mappings of the options followed by the default ones
+func typeMappings(option *ConvertOption) []parser.TypeMapping {
+	mappings := make([]parser.TypeMapping, 0, len(option.TypeMappings)+len(defaultTypeMappings))
+	mappings = append(mappings, option.TypeMappings...)
+	return append(mappings, defaultTypeMappings...)
+}
+
+// mapProtoType returns the Proto type of a scalar schema converted as typ, importing the file
+// declaring it
+func (c *ProtoConverter) mapProtoType(schema *openapi3.Schema, typ string) string {
+	for i := range c.typeMappings {
+		mapping := &c.typeMappings[i]
+		if mapping.Proto == "" || !mapping.Matches(schema, typ) {
+			continue
+		}
+		if mapping.ProtoImport != "" {
+			c.AddProtoImport(mapping.ProtoImport)
+		}
+		return mapping.Proto
+	}
+	return "string"
+}
+
+// mapThriftType returns the Thrift type of a scalar schema converted as typ, including the file
+// declaring it
+func (c *ThriftConverter) mapThriftType(schema *openapi3.Schema, typ string) string {
+	for i := range c.typeMappings {
+		mapping := &c.typeMappings[i]
+		if mapping.Thrift == "" || !mapping.Matches(schema, typ) {
+			continue
+		}
+		if mapping.ThriftInclude != "" {
+			c.AddThriftInclude(mapping.ThriftInclude)
+		}
+		return mapping.Thrift
+	}
+	return "string"
+}
@@ -0,0 +1,135 @@
+package converter
+
+import (
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/hertz-contrib/swagger-generate/swagger2idl/parser"
+	"github.com/hertz-contrib/swagger-generate/swagger2idl/protobuf"
+	"github.com/hertz-contrib/swagger-generate/swagger2idl/utils"
+)
+
+// userMappings overrides int64 strings and maps decimals and signed integers
+var userMappings = []parser.TypeMapping{
+	{Type: openapi3.TypeInteger, Format: "int64", Extension: "x-as-string=true", Proto: "string", Thrift: "string"},
+	{Type: openapi3.TypeString, Format: "decimal", Proto: "google.type.Decimal", ProtoImport: "google/type/decimal.proto", Thrift: "common.Decimal", ThriftInclude: "common.thrift"},
+	{Type: openapi3.TypeInteger, Format: "int32", Extension: "x-signed", Proto: "sint32"},
+}
+
+func TestMapScalarTypes(t *testing.T) {
+	tests := []struct {
+		name          string
+		schema        *openapi3.Schema
+		typ           string
+		proto         string
+		protoImport   string
+		thrift        string
+		thriftInclude string
+	}{
+		{name: "string", schema: &openapi3.Schema{}, typ: openapi3.TypeString, proto: "string", thrift: "string"},
+		{name: "date-time", schema: &openapi3.Schema{Format: "date-time"}, typ: openapi3.TypeString, proto: "google.protobuf.Timestamp", protoImport: timestampProtoFile, thrift: "string"},
+		{name: "binary", schema: &openapi3.Schema{Format: "binary"}, typ: openapi3.TypeString, proto: "bytes", thrift: "binary"},
+		{name: "int32", schema: &openapi3.Schema{Format: "int32"}, typ: openapi3.TypeInteger, proto: "int32", thrift: "i32"},
+		{name: "integer of unknown format", schema: &openapi3.Schema{Format: "int8"}, typ: openapi3.TypeInteger, proto: "int64", thrift: "i64"},
+		{name: "float", schema: &openapi3.Schema{Format: "float"}, typ: openapi3.TypeNumber, proto: "float", thrift: "float"},
+		{name: "number", schema: &openapi3.Schema{}, typ: openapi3.TypeNumber, proto: "double", thrift: "double"},
+		{name: "boolean", schema: &openapi3.Schema{}, typ: openapi3.TypeBoolean, proto: "bool", thrift: "bool"},
+		{name: "unknown type", schema: &openapi3.Schema{}, typ: "null", proto: "string", thrift: "string"},
+		{
+			name:   "extension value",
+			schema: &openapi3.Schema{Format: "int64", Extensions: map[string]interface{}{"x-as-string": true}},
+			typ:    openapi3.TypeInteger,
+			proto:  "string",
+			thrift: "string",
+		},
+		{
+			name:   "other extension value",
+			schema: &openapi3.Schema{Format: "int64", Extensions: map[string]interface{}{"x-as-string": false}},
+			typ:    openapi3.TypeInteger,
+			proto:  "int64",
+			thrift: "i64",
+		},
+		{
+			name:          "imported types",
+			schema:        &openapi3.Schema{Format: "decimal"},
+			typ:           openapi3.TypeString,
+			proto:         "google.type.Decimal",
+			protoImport:   "google/type/decimal.proto",
+			thrift:        "common.Decimal",
+			thriftInclude: "common.thrift",
+		},
+		{
+			name:   "proto only mapping",
+			schema: &openapi3.Schema{Format: "int32", Extensions: map[string]interface{}{"x-signed": true}},
+			typ:    openapi3.TypeInteger,
+			proto:  "sint32",
+			thrift: "i32",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := loadSpec(t, emptySpec)
+			option := &ConvertOption{TypeMappings: userMappings}
+
+			protoConverter := NewProtoConverter(spec, option)
+			if got := protoConverter.mapProtoType(tt.schema, tt.typ); got != tt.proto {
+				t.Errorf("mapProtoType() = %q, want %q", got, tt.proto)
+			}
+			if imports := protoConverter.ProtoFile.Imports; (tt.protoImport == "" && len(imports) != 0) || (tt.protoImport != "" && !utils.Contains(imports, tt.protoImport)) {
+				t.Errorf("imports = %v, want %q", imports, tt.protoImport)
+			}
+
+			thriftConverter := NewThriftConverter(spec, option)
+			if got := thriftConverter.mapThriftType(tt.schema, tt.typ); got != tt.thrift {
+				t.Errorf("mapThriftType() = %q, want %q", got, tt.thrift)
+			}
+			if includes := thriftConverter.ThriftFile.Includes; (tt.thriftInclude == "" && len(includes) != 0) || (tt.thriftInclude != "" && !utils.Contains(includes, tt.thriftInclude)) {
+				t.Errorf("includes = %v, want %q", includes, tt.thriftInclude)
+			}
+		})
+	}
+}
+
+func TestApplyPresence(t *testing.T) {
+	scalar := &openapi3.SchemaRef{Value: &openapi3.Schema{Type: &openapi3.Types{openapi3.TypeInteger}}}
+	untyped := &openapi3.SchemaRef{Value: &openapi3.Schema{}}
+	object := &openapi3.SchemaRef{Value: &openapi3.Schema{Type: &openapi3.Types{openapi3.TypeObject}}}
+
+	tests := []struct {
+		name         string
+		field        protobuf.ProtoField
+		schema       *openapi3.SchemaRef
+		required     bool
+		nullable     bool
+		presence     string
+		wantType     string
+		wantOptional bool
+	}{
+		{name: "optional scalar", field: protobuf.ProtoField{Type: "int64"}, schema: scalar, presence: PresenceOptional, wantType: "int64", wantOptional: true},
+		{name: "required scalar", field: protobuf.ProtoField{Type: "int64"}, schema: scalar, required: true, presence: PresenceOptional, wantType: "int64"},
+		{name: "required nullable scalar", field: protobuf.ProtoField{Type: "int64"}, schema: scalar, required: true, nullable: true, presence: PresenceOptional, wantType: "int64", wantOptional: true},
+		{name: "mapped scalar", field: protobuf.ProtoField{Type: "sint32"}, schema: scalar, presence: PresenceOptional, wantType: "sint32", wantOptional: true},
+		{name: "untyped schema", field: protobuf.ProtoField{Type: "string"}, schema: untyped, presence: PresenceOptional, wantType: "string", wantOptional: true},
+		{name: "repeated scalar", field: protobuf.ProtoField{Type: "int64", Repeated: true}, schema: scalar, presence: PresenceOptional, wantType: "int64"},
+		{name: "object", field: protobuf.ProtoField{Type: "Pet"}, schema: object, presence: PresenceOptional, wantType: "Pet"},
+		{name: "scalar mapped to a message", field: protobuf.ProtoField{Type: "google.protobuf.Timestamp"}, schema: scalar, presence: PresenceOptional, wantType: "google.protobuf.Timestamp"},
+		{name: "wrapper", field: protobuf.ProtoField{Type: "int64"}, schema: scalar, presence: PresenceWrapper, wantType: "google.protobuf.Int64Value"},
+		{name: "wrapper of a mapped scalar", field: protobuf.ProtoField{Type: "sint32"}, schema: scalar, presence: PresenceWrapper, wantType: "google.protobuf.Int32Value"},
+		{name: "wrapper of an unsigned scalar", field: protobuf.ProtoField{Type: "fixed64"}, schema: scalar, presence: PresenceWrapper, wantType: "google.protobuf.UInt64Value"},
+		{name: "none", field: protobuf.ProtoField{Type: "int64"}, schema: scalar, presence: PresenceNone, wantType: "int64"},
+		{name: "default", field: protobuf.ProtoField{Type: "int64"}, schema: scalar, wantType: "int64"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewProtoConverter(loadSpec(t, emptySpec), &ConvertOption{PresenceOption: tt.presence})
+			field := tt.field
+			c.applyPresence(&field, tt.schema, tt.required, tt.nullable)
+			if field.Type != tt.wantType || field.Optional != tt.wantOptional {
+				t.Errorf("applyPresence() = %s optional=%v, want %s optional=%v", field.Type, field.Optional, tt.wantType, tt.wantOptional)
+			}
+			if wrapped := tt.wantType != tt.field.Type; wrapped != utils.Contains(c.ProtoFile.Imports, wrappersProtoFile) {
+				t.Errorf("imports = %v, wrapper import expected %v", c.ProtoFile.Imports, wrapped)
+			}
+		})
+	}
+}
@@ -26,14 +26,40 @@ import (
 	"github.com/hertz-contrib/swagger-generate/swagger2idl/utils"
 )
 
+// protoReservedStart and protoReservedEnd bound the field numbers reserved by the protobuf implementation
+const (
+	protoReservedStart = 19000
+	protoReservedEnd   = 19999
+)
+
 // ProtoGenerate is used to handle the encoding context
 type ProtoGenerate struct {
-	dst *strings.Builder // The target for output
+	dst       *strings.Builder    // The target for output
+	fieldLock *protobuf.FieldLock // Field numbers of a previous generation, may be nil
+	usedLock  *protobuf.FieldLock // Field numbers assigned by this generation
+	keepOrder bool                // Keep the order of the declarations instead of sorting them by name
 }
 
 // NewProtoGenerate creates a new ProtoGenerate instance
 func NewProtoGenerate() *ProtoGenerate {
-	return &ProtoGenerate{dst: &strings.Builder{}}
+	return &ProtoGenerate{dst: &strings.Builder{}, usedLock: protobuf.NewFieldLock()}
+}
+
+// SetFieldLock makes the generator keep the field numbers recorded in lock. Fields missing from
+// the lock receive fresh numbers and locked fields that no longer exist are reserved.
+func (e *ProtoGenerate) SetFieldLock(lock *protobuf.FieldLock) {
+	e.fieldLock = lock
+}
+
+// SetKeepOrder makes the generator keep the order of the declarations and fields of the ProtoFile,
+// such as the spec order decided by the converter. By default they are sorted by name.
+func (e *ProtoGenerate) SetKeepOrder(keep bool) {
+	e.keepOrder = keep
+}
+
+// FieldLock returns the field numbers assigned by the last call to Generate
+func (e *ProtoGenerate) FieldLock() *protobuf.FieldLock {
+	return e.usedLock
 }
 
 // Generate converts the ProtoFile structure into Proto file content
@@ -42,6 +68,7 @@ func (e *ProtoGenerate) Generate(fileContent interface{}) (string, error) {
 	if !ok {
 		return "", fmt.Errorf("invalid type: expected *protobuf.ProtoFile")
 	}
+	e.dst = &strings.Builder{}
 	e.dst.WriteString("syntax = \"proto3\";\n\n")
 	e.dst.WriteString(fmt.Sprintf("package %s;\n\n", protoFile.PackageName))
 
@@ -61,31 +88,20 @@ func (e *ProtoGenerate) Generate(fileContent interface{}) (string, error) {
 		e.dst.WriteString("\n")
 	}
 
-	// Sort enums by name
-	sort.Slice(protoFile.Enums, func(i, j int) bool {
-		return protoFile.Enums[i].Name < protoFile.Enums[j].Name
-	})
+	if !e.keepOrder {
+		sortProtoFile(protoFile)
+	}
 
 	// Generate enums
 	for _, enum := range protoFile.Enums {
 		e.encodeEnum(enum, 0)
 	}
 
-	// Sort messages by name
-	sort.Slice(protoFile.Messages, func(i, j int) bool {
-		return protoFile.Messages[i].Name < protoFile.Messages[j].Name
-	})
-
 	if len(protoFile.Messages) > 0 {
 		for _, message := range protoFile.Messages {
-			e.encodeMessage(message, 0)
+			e.encodeMessage(message, "", 0)
 		}
 	}
-	// Sort services by name
-	sort.Slice(protoFile.Services, func(i, j int) bool {
-		return protoFile.Services[i].Name < protoFile.Services[j].Name
-	})
-
 	// Generate services
 	for _, service := range protoFile.Services {
 		if service.Description != "" {
@@ -93,11 +109,6 @@ func (e *ProtoGenerate) Generate(fileContent interface{}) (string, error) {
 		}
 		e.dst.WriteString(fmt.Sprintf("service %s {\n", service.Name))
 
-		// Sort methods by name
-		sort.Slice(service.Methods, func(i, j int) bool {
-			return service.Methods[i].Name < service.Methods[j].Name
-		})
-
 		for _, method := range service.Methods {
 			if method.Description != "" {
 				e.dst.WriteString(fmt.Sprintf("  // %s\n", method.Description))
@@ -124,6 +135,120 @@ func (e *ProtoGenerate) Generate(fileContent interface{}) (string, error) {
 	return e.dst.String(), nil
 }
 
+// GenerateFiles converts a set of ProtoFiles that reference each other into Proto file contents keyed
+// by file name. References to declarations of another file of the set are qualified with the package
+// of that file and the file is imported.
+func (e *ProtoGenerate) GenerateFiles(files []*protobuf.ProtoFile) (map[string]string, error) {
+	owners := make(map[string]*protobuf.ProtoFile)
+	for _, file := range files {
+		for _, message := range file.Messages {
+			owners[message.Name] = file
+		}
+		for _, enum := range file.Enums {
+			owners[enum.Name] = file
+		}
+	}
+
+	contents := make(map[string]string, len(files))
+	for _, file := range files {
+		imports := make(map[string]bool)
+		qualify := func(typeStr string, scopes []map[string]bool) string {
+			return utils.ReplaceTypeNames(typeStr, func(name string) string {
+				first, _, _ := strings.Cut(name, ".")
+				for _, scope := range scopes {
+					if scope[first] {
+						return name
+					}
+				}
+				owner, ok := owners[first]
+				if !ok || owner == file {
+					return name
+				}
+				imports[owner.FileName] = true
+				return owner.PackageName + "." + name
+			})
+		}
+		for _, message := range file.Messages {
+			qualifyMessage(message, nil, qualify)
+		}
+		for _, service := range file.Services {
+			for _, method := range service.Methods {
+				method.Input = qualify(method.Input, nil)
+				method.Output = qualify(method.Output, nil)
+			}
+		}
+		for _, importFile := range utils.SortedKeys(imports) {
+			if !utils.Contains(file.Imports, importFile) {
+				file.Imports = append(file.Imports, importFile)
+			}
+		}
+
+		content, err := e.Generate(file)
+		if err != nil {
+			return nil, fmt.Errorf("error generating %s: %w", file.FileName, err)
+		}
+		contents[file.FileName] = content
+	}
+	return contents, nil
+}
+
+// qualifyMessage rewrites the field types of a message and its nested messages. Names declared inside
+// the enclosing messages shadow the declarations of other files.
+func qualifyMessage(message *protobuf.ProtoMessage, scopes []map[string]bool, qualify func(string, []map[string]bool) string) {
+	scope := make(map[string]bool)
+	for _, nestedMessage := range message.Messages {
+		scope[nestedMessage.Name] = true
+	}
+	for _, nestedEnum := range message.Enums {
+		scope[nestedEnum.Name] = true
+	}
+	scopes = append(scopes, scope)
+
+	for _, field := range message.Fields {
+		field.Type = qualify(field.Type, scopes)
+	}
+	for _, oneOf := range message.OneOfs {
+		for _, field := range oneOf.Fields {
+			field.Type = qualify(field.Type, scopes)
+		}
+	}
+	for _, nestedMessage := range message.Messages {
+		qualifyMessage(nestedMessage, scopes, qualify)
+	}
+}
+
+// sortProtoFile sorts the enums, messages, services and methods of a ProtoFile and the fields of
+// its messages by name
+func sortProtoFile(protoFile *protobuf.ProtoFile) {
+	sort.SliceStable(protoFile.Enums, func(i, j int) bool {
+		return protoFile.Enums[i].Name < protoFile.Enums[j].Name
+	})
+	sort.SliceStable(protoFile.Messages, func(i, j int) bool {
+		return protoFile.Messages[i].Name < protoFile.Messages[j].Name
+	})
+	sort.SliceStable(protoFile.Services, func(i, j int) bool {
+		return protoFile.Services[i].Name < protoFile.Services[j].Name
+	})
+	for _, service := range protoFile.Services {
+		sort.SliceStable(service.Methods, func(i, j int) bool {
+			return service.Methods[i].Name < service.Methods[j].Name
+		})
+	}
+	for _, message := range protoFile.Messages {
+		sortMessageFields(message)
+	}
+}
+
+// sortMessageFields sorts the fields of a message and of its nested messages by name
+func sortMessageFields(message *protobuf.ProtoMessage) {
+	sort.SliceStable(message.Fields, func(i, j int) bool {
+		return message.Fields[i].Name < message.Fields[j].Name
+	})
+	for _, nestedMessage := range message.Messages {
+		sortMessageFields(nestedMessage)
+	}
+}
+
 // encodeEnum encodes enum types
 func (e *ProtoGenerate) encodeEnum(enum *protobuf.ProtoEnum, indentLevel int) {
 	indent := strings.Repeat("  ", indentLevel)
@@ -144,7 +269,12 @@ func (e *ProtoGenerate) encodeEnum(enum *protobuf.ProtoEnum, indentLevel int) {
 }
 
 // encodeMessage recursively encodes messages, including nested messages, enums, and oneofs
-func (e *ProtoGenerate) encodeMessage(message *protobuf.ProtoMessage, indentLevel int) {
+func (e *ProtoGenerate) encodeMessage(message *protobuf.ProtoMessage, parentPath string, indentLevel int) {
+	messagePath := message.Name
+	if parentPath != "" {
+		messagePath = parentPath + "." + message.Name
+	}
+
 	if indentLevel > 0 {
 		e.dst.WriteString("\n")
 	}
@@ -166,13 +296,19 @@ func (e *ProtoGenerate) encodeMessage(message *protobuf.ProtoMessage, indentLeve
 		}
 	}
 
-	// Sort fields by name
-	sort.Slice(message.Fields, func(i, j int) bool {
-		return message.Fields[i].Name < message.Fields[j].Name
-	})
+	numbers := e.assignFieldNumbers(message, messagePath)
 
-	// Initialize field number
-	fieldNumber := 1
+	// Generate reserved numbers and names
+	if reserved := numbers.reservedItems(); len(reserved) > 0 {
+		e.dst.WriteString(fmt.Sprintf("%s  reserved %s;\n", indent, strings.Join(reserved, ", ")))
+	}
+	if len(numbers.reservedNames) > 0 {
+		names := make([]string, 0, len(numbers.reservedNames))
+		for _, name := range numbers.reservedNames {
+			names = append(names, strconv.Quote(name))
+		}
+		e.dst.WriteString(fmt.Sprintf("%s  reserved %s;\n", indent, strings.Join(names, ", ")))
+	}
 
 	// Generate fields
 	for _, field := range message.Fields {
@@ -182,10 +318,11 @@ func (e *ProtoGenerate) encodeMessage(message *protobuf.ProtoMessage, indentLeve
 		repeated := ""
 		if field.Repeated {
 			repeated = "repeated "
+		} else if field.Optional {
+			repeated = "optional "
 		}
 
-		e.dst.WriteString(fmt.Sprintf("%s  %s%s %s = %d", indent, repeated, field.Type, utils.FormatStr(field.Name), fieldNumber))
-		fieldNumber++
+		e.dst.WriteString(fmt.Sprintf("%s  %s%s %s = %d", indent, repeated, field.Type, utils.FormatStr(field.Name), numbers.fields[field]))
 
 		// Generate field-level options
 		if len(field.Options) > 0 {
@@ -206,7 +343,7 @@ func (e *ProtoGenerate) encodeMessage(message *protobuf.ProtoMessage, indentLeve
 
 	// Generate oneofs
 	for _, oneOf := range message.OneOfs {
-		e.encodeOneOf(oneOf, indentLevel+1, &fieldNumber)
+		e.encodeOneOf(oneOf, numbers, indentLevel+1)
 	}
 
 	if len(message.Enums) > 0 {
@@ -218,21 +355,179 @@ func (e *ProtoGenerate) encodeMessage(message *protobuf.ProtoMessage, indentLeve
 	}
 	// Recursively handle nested messages
 	for _, nestedMessage := range message.Messages {
-		e.encodeMessage(nestedMessage, indentLevel+1) // Increase indentation for nested messages
+		e.encodeMessage(nestedMessage, messagePath, indentLevel+1) // Increase indentation for nested messages
 	}
 
 	e.dst.WriteString(fmt.Sprintf("%s}\n\n", indent))
 }
 
+// messageNumbers holds the field numbers and reservations of a message assigned by one generation
+type messageNumbers struct {
+	fields         map[*protobuf.ProtoField]int
+	reserved       []int
+	reservedRanges []protobuf.ReservedRange
+	reservedNames  []string
+}
+
+// isReserved reports whether number is reserved, alone or within a range
+func (n *messageNumbers) isReserved(number int) bool {
+	if number >= protoReservedStart && number <= protoReservedEnd {
+		return true
+	}
+	for _, r := range n.reservedRanges {
+		if r.Contains(number) {
+			return true
+		}
+	}
+	for _, reserved := range n.reserved {
+		if reserved == number {
+			return true
+		}
+	}
+	return false
+}
+
+// reservedItems formats the reserved numbers and ranges of a reserved statement, in ascending order
+func (n *messageNumbers) reservedItems() []string {
+	type item struct {
+		from int
+		text string
+	}
+	items := make([]item, 0, len(n.reserved)+len(n.reservedRanges))
+	for _, number := range n.reserved {
+		items = append(items, item{from: number, text: strconv.Itoa(number)})
+	}
+	for _, r := range n.reservedRanges {
+		items = append(items, item{from: r.From, text: r.String()})
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].from < items[j].from
+	})
+	texts := make([]string, 0, len(items))
+	for _, it := range items {
+		texts = append(texts, it.text)
+	}
+	return texts
+}
+
+// assignFieldNumbers numbers the fields and oneof fields of a message. Explicit numbers are kept,
+// locked numbers are reused and the remaining fields are numbered after the highest used number.
+// The message itself is left untouched, so it can be generated again with another lock.
+func (e *ProtoGenerate) assignFieldNumbers(message *protobuf.ProtoMessage, messagePath string) *messageNumbers {
+	fields := append([]*protobuf.ProtoField{}, message.Fields...)
+	for _, oneOf := range message.OneOfs {
+		fields = append(fields, oneOf.Fields...)
+	}
+
+	numbers := &messageNumbers{
+		fields:        make(map[*protobuf.ProtoField]int, len(fields)),
+		reserved:      append([]int{}, message.Reserved...),
+		reservedNames: append([]string{}, message.ReservedNames...),
+	}
+	used := make(map[int]bool)
+	for _, field := range fields {
+		if field.Number > 0 {
+			numbers.fields[field] = field.Number
+			used[field.Number] = true
+		}
+	}
+
+	locked := e.fieldLock.Lookup(messagePath)
+	present := make(map[string]bool)
+	if locked != nil {
+		numbers.reservedRanges = append(numbers.reservedRanges, locked.ReservedRanges...)
+		for _, number := range locked.Reserved {
+			if !used[number] && !numbers.isReserved(number) {
+				numbers.reserved = append(numbers.reserved, number)
+			}
+		}
+		for _, field := range fields {
+			name := utils.FormatStr(field.Name)
+			present[name] = true
+			if number, ok := locked.Fields[name]; ok && field.Number == 0 && !used[number] && !numbers.isReserved(number) {
+				numbers.fields[field] = number
+				used[number] = true
+			}
+		}
+	}
+
+	// Without a lock, fill numbers from 1; with a lock, append after the highest known number
+	next := 1
+	if locked != nil {
+		for number := range used {
+			if number >= next {
+				next = number + 1
+			}
+		}
+		for _, number := range locked.Fields {
+			if number >= next {
+				next = number + 1
+			}
+		}
+		for _, number := range numbers.reserved {
+			if number >= next {
+				next = number + 1
+			}
+		}
+	}
+	for _, field := range fields {
+		if _, ok := numbers.fields[field]; ok {
+			continue
+		}
+		for used[next] || numbers.isReserved(next) {
+			next++
+		}
+		numbers.fields[field] = next
+		used[next] = true
+	}
+
+	// Reserve locked fields that were removed from the message
+	if locked != nil {
+		reservedNames := make(map[string]bool)
+		for _, name := range numbers.reservedNames {
+			reservedNames[name] = true
+		}
+		for _, name := range locked.ReservedNames {
+			if !reservedNames[name] && !present[name] {
+				numbers.reservedNames = append(numbers.reservedNames, name)
+				reservedNames[name] = true
+			}
+		}
+		for name, number := range locked.Fields {
+			if present[name] {
+				continue
+			}
+			if !used[number] && !numbers.isReserved(number) {
+				numbers.reserved = append(numbers.reserved, number)
+			}
+			if !reservedNames[name] {
+				numbers.reservedNames = append(numbers.reservedNames, name)
+				reservedNames[name] = true
+			}
+		}
+		sort.Ints(numbers.reserved)
+		sort.Strings(numbers.reservedNames)
+	}
+
+	// Record the numbers of this generation
+	ml := e.usedLock.Message(messagePath)
+	for _, field := range fields {
+		ml.Fields[utils.FormatStr(field.Name)] = numbers.fields[field]
+	}
+	ml.Reserved = append([]int{}, numbers.reserved...)
+	ml.ReservedRanges = append([]protobuf.ReservedRange{}, numbers.reservedRanges...)
+	ml.ReservedNames = append([]string{}, numbers.reservedNames...)
+	return numbers
+}
+
 // encodeOneOf encodes oneof types
-func (e *ProtoGenerate) encodeOneOf(oneOf *protobuf.ProtoOneOf, indentLevel int, fieldNumber *int) {
+func (e *ProtoGenerate) encodeOneOf(oneOf *protobuf.ProtoOneOf, numbers *messageNumbers, indentLevel int) {
 	indent := strings.Repeat("  ", indentLevel)
-	e.dst.WriteString(fmt.Sprintf("%soneof %s {\n", indent, oneOf.Name))
+	e.dst.WriteString(fmt.Sprintf("%soneof %s {\n", indent, utils.FormatStr(oneOf.Name)))
 
 	// Generate oneof fields
 	for _, field := range oneOf.Fields {
-		e.dst.WriteString(fmt.Sprintf("%s  %s %s = %d;\n", indent, field.Type, field.Name, *fieldNumber))
-		(*fieldNumber)++
+		e.dst.WriteString(fmt.Sprintf("%s  %s %s = %d;\n", indent, field.Type, utils.FormatStr(field.Name), numbers.fields[field]))
 	}
 
 	e.dst.WriteString(fmt.Sprintf("%s}\n", indent))
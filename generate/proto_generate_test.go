@@ -0,0 +1,211 @@
+/*
+ * Copyright 2024 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package generate
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hertz-contrib/swagger-generate/swagger2idl/protobuf"
+)
+
+// petFile returns a ProtoFile with a message of plain and oneof fields
+func petFile() *protobuf.ProtoFile {
+	return &protobuf.ProtoFile{
+		PackageName: "pet",
+		Messages: []*protobuf.ProtoMessage{{
+			Name: "Pet",
+			Fields: []*protobuf.ProtoField{
+				{Name: "Name", Type: "string"},
+				{Name: "Age", Type: "int64"},
+			},
+			OneOfs: []*protobuf.ProtoOneOf{{
+				Name: "kind-of",
+				Fields: []*protobuf.ProtoField{
+					{Name: "dog-name", Type: "string"},
+				},
+			}},
+		}},
+	}
+}
+
+func TestProtoGenerateFieldLock(t *testing.T) {
+	tests := []struct {
+		name string
+		lock func() *protobuf.FieldLock
+		want []string
+	}{
+		{
+			name: "without lock",
+			lock: func() *protobuf.FieldLock { return nil },
+			want: []string{
+				"string Name = 1;",
+				"int64 Age = 2;",
+				"oneof kind_of {",
+				"string dog_name = 3;",
+			},
+		},
+		{
+			name: "locked numbers and removed fields",
+			lock: func() *protobuf.FieldLock {
+				lock := protobuf.NewFieldLock()
+				ml := lock.Message("Pet")
+				ml.Fields["Name"] = 4
+				ml.Fields["dog_name"] = 2
+				ml.Fields["Color"] = 7
+				return lock
+			},
+			want: []string{
+				"reserved 7;",
+				`reserved "Color";`,
+				"string Name = 4;",
+				"int64 Age = 8;",
+				"string dog_name = 2;",
+			},
+		},
+		{
+			name: "reserved ranges",
+			lock: func() *protobuf.FieldLock {
+				lock := protobuf.NewFieldLock()
+				ml := lock.Message("Pet")
+				ml.Fields["Name"] = 1
+				ml.Reserved = []int{12}
+				ml.ReservedRanges = []protobuf.ReservedRange{
+					{From: 2, To: 10},
+					{From: 100, To: protobuf.MaxFieldNumber},
+				}
+				return lock
+			},
+			want: []string{
+				"reserved 2 to 10, 12, 100 to max;",
+				"string Name = 1;",
+				"int64 Age = 13;",
+				"string dog_name = 14;",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := petFile()
+			var outputs []string
+			// Generating twice must not accumulate reservations in the ProtoFile
+			for i := 0; i < 2; i++ {
+				generator := NewProtoGenerate()
+				generator.SetKeepOrder(true)
+				generator.SetFieldLock(tt.lock())
+				output, err := generator.Generate(file)
+				if err != nil {
+					t.Fatalf("Generate() error = %v", err)
+				}
+				outputs = append(outputs, output)
+			}
+			if outputs[0] != outputs[1] {
+				t.Errorf("second Generate() differs:\n%s\nfirst:\n%s", outputs[1], outputs[0])
+			}
+			for _, line := range tt.want {
+				if !strings.Contains(outputs[0], line) {
+					t.Errorf("Generate() output lacks %q:\n%s", line, outputs[0])
+				}
+			}
+		})
+	}
+}
+
+func TestProtoGenerateUsedLock(t *testing.T) {
+	lock := protobuf.NewFieldLock()
+	ml := lock.Message("Pet")
+	ml.Fields["Color"] = 3
+	ml.ReservedRanges = []protobuf.ReservedRange{{From: 5, To: 6}}
+
+	generator := NewProtoGenerate()
+	generator.SetKeepOrder(true)
+	generator.SetFieldLock(lock)
+	if _, err := generator.Generate(petFile()); err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+
+	used := generator.FieldLock().Lookup("Pet")
+	if used == nil {
+		t.Fatalf("FieldLock() has no entry for Pet")
+	}
+	// New fields follow the highest locked number and skip the reserved range
+	wantFields := map[string]int{"Name": 4, "Age": 7, "dog_name": 8}
+	for name, number := range wantFields {
+		if used.Fields[name] != number {
+			t.Errorf("used number of %s = %d, want %d", name, used.Fields[name], number)
+		}
+	}
+	if len(used.Reserved) != 1 || used.Reserved[0] != 3 {
+		t.Errorf("used reserved = %v, want [3]", used.Reserved)
+	}
+	if len(used.ReservedRanges) != 1 || used.ReservedRanges[0] != (protobuf.ReservedRange{From: 5, To: 6}) {
+		t.Errorf("used reserved ranges = %v, want [{5 6}]", used.ReservedRanges)
+	}
+	if len(used.ReservedNames) != 1 || used.ReservedNames[0] != "Color" {
+		t.Errorf("used reserved names = %v, want [Color]", used.ReservedNames)
+	}
+}
+
+func TestProtoGenerateOrder(t *testing.T) {
+	file := func() *protobuf.ProtoFile {
+		return &protobuf.ProtoFile{
+			PackageName: "pet",
+			Enums:       []*protobuf.ProtoEnum{{Name: "Size"}, {Name: "Color"}},
+			Messages: []*protobuf.ProtoMessage{
+				{Name: "Pet", Fields: []*protobuf.ProtoField{{Name: "Name", Type: "string"}, {Name: "Age", Type: "int64"}}},
+				{Name: "Owner"},
+			},
+			Services: []*protobuf.ProtoService{{
+				Name:    "PetService",
+				Methods: []*protobuf.ProtoMethod{{Name: "List", Input: "Pet", Output: "Pet"}, {Name: "Get", Input: "Pet", Output: "Pet"}},
+			}},
+		}
+	}
+	tests := []struct {
+		name      string
+		keepOrder bool
+		want      []string
+	}{
+		{
+			name: "sorted",
+			want: []string{"enum Color", "enum Size", "message Owner", "message Pet", "int64 Age = 1", "string Name = 2", "rpc Get", "rpc List"},
+		},
+		{
+			name:      "kept",
+			keepOrder: true,
+			want:      []string{"enum Size", "enum Color", "message Pet", "string Name = 1", "int64 Age = 2", "message Owner", "rpc List", "rpc Get"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			generator := NewProtoGenerate()
+			generator.SetKeepOrder(tt.keepOrder)
+			output, err := generator.Generate(file())
+			if err != nil {
+				t.Fatalf("Generate() error = %v", err)
+			}
+			last := -1
+			for _, want := range tt.want {
+				i := strings.Index(output, want)
+				if i <= last {
+					t.Fatalf("Generate() output lacks %q after the previous declaration:\n%s", want, output)
+				}
+				last = i
+			}
+		})
+	}
+}
@@ -3,6 +3,8 @@ package generate
 import (
 	"fmt"
 	"github.com/hertz-contrib/swagger-generate/swagger2idl/utils"
+	"path"
+	"path/filepath"
 	"strconv"
 	"strings"
 
@@ -29,8 +31,8 @@ func (e *ThriftGenerate) Generate(fileContent interface{}) (string, error) {
 	if len(thriftFile.Namespace) == 0 {
 		e.dst.WriteString("namespace go example\n\n")
 	} else {
-		for language, ns := range thriftFile.Namespace {
-			e.dst.WriteString(fmt.Sprintf("namespace %s %s\n", language, ns))
+		for _, language := range utils.SortedKeys(thriftFile.Namespace) {
+			e.dst.WriteString(fmt.Sprintf("namespace %s %s\n", language, thriftFile.Namespace[language]))
 		}
 		e.dst.WriteString("\n")
 	}
@@ -53,6 +55,11 @@ func (e *ThriftGenerate) Generate(fileContent interface{}) (string, error) {
 		e.encodeConstant(constant)
 	}
 
+	// 生成 typedefs
+	for _, typedef := range thriftFile.Typedefs {
+		e.encodeTypedef(typedef)
+	}
+
 	// 生成 structs
 	for _, message := range thriftFile.Structs {
 		e.encodeMessage(message, 0)
@@ -71,6 +78,104 @@ func (e *ThriftGenerate) Generate(fileContent interface{}) (string, error) {
 	return e.dst.String(), nil
 }
 
+// GenerateFiles 将一组相互引用的 ThriftFile 转换为以文件名为键的 Thrift 文件内容，
+// 引用其他文件中的声明时会添加 include 并使用被引用文件的文件名作为前缀。
+// 同一文件 include 的两个不同文件具有相同前缀（如 a/common.thrift 与 b/common.thrift）时返回错误
+func (e *ThriftGenerate) GenerateFiles(files []*thrift.ThriftFile) (map[string]string, error) {
+	owners := make(map[string]*thrift.ThriftFile)
+	for _, file := range files {
+		for _, message := range file.Structs {
+			owners[message.Name] = file
+		}
+		for _, union := range file.Unions {
+			owners[union.Name] = file
+		}
+		for _, enum := range file.Enums {
+			owners[enum.Name] = file
+		}
+		for _, typedef := range file.Typedefs {
+			owners[typedef.Name] = file
+		}
+	}
+
+	contents := make(map[string]string, len(files))
+	for _, file := range files {
+		includes := make(map[string]bool)
+		prefixes := make(map[string]string)
+		for _, include := range file.Includes {
+			prefixes[includePrefix(include)] = include
+		}
+		var collision error
+		qualify := func(typeStr string) string {
+			return utils.ReplaceTypeNames(typeStr, func(name string) string {
+				owner, ok := owners[name]
+				if !ok || owner == file {
+					return name
+				}
+				include := includePath(file.FileName, owner.FileName)
+				prefix := includePrefix(owner.FileName)
+				if other, ok := prefixes[prefix]; ok && other != include && collision == nil {
+					collision = fmt.Errorf("%s includes %s and %s, which share the prefix %s", file.FileName, other, include, prefix)
+				}
+				prefixes[prefix] = include
+				includes[include] = true
+				return prefix + "." + name
+			})
+		}
+		for _, typedef := range file.Typedefs {
+			typedef.Type = qualify(typedef.Type)
+		}
+		for _, message := range file.Structs {
+			for _, field := range message.Fields {
+				field.Type = qualify(field.Type)
+			}
+		}
+		for _, union := range file.Unions {
+			for _, field := range union.Fields {
+				field.Type = qualify(field.Type)
+			}
+		}
+		for _, service := range file.Services {
+			for _, method := range service.Methods {
+				for i, input := range method.Input {
+					method.Input[i] = qualify(input)
+				}
+				method.Output = qualify(method.Output)
+			}
+		}
+		if collision != nil {
+			return nil, collision
+		}
+		for _, include := range utils.SortedKeys(includes) {
+			if !utils.Contains(file.Includes, include) {
+				file.Includes = append(file.Includes, include)
+			}
+		}
+
+		e.dst = &strings.Builder{}
+		content, err := e.Generate(file)
+		if err != nil {
+			return nil, fmt.Errorf("error generating %s: %w", file.FileName, err)
+		}
+		contents[file.FileName] = content
+	}
+	return contents, nil
+}
+
+// includePrefix 返回引用被 include 文件中的声明时使用的前缀，即不含扩展名的文件名
+func includePrefix(fileName string) string {
+	return strings.TrimSuffix(path.Base(fileName), ".thrift")
+}
+
+// includePath 返回从 from 文件引用 to 文件时使用的相对路径，Thrift 的 include 相对于当前文件所在目录解析
+func includePath(from, to string) string {
+	rel, err := filepath.Rel(path.Dir(from), to)
+	if err != nil {
+		return to
+	}
+	return filepath.ToSlash(rel)
+}
+
 // encodeEnum 编码枚举类型
 func (e *ThriftGenerate) encodeEnum(enum *thrift.ThriftEnum, indentLevel int) {
 	indent := strings.Repeat("    ", indentLevel)
@@ -103,9 +208,11 @@ func (e *ThriftGenerate) encodeField(field *thrift.ThriftField, index int, inden
 		fieldType = fmt.Sprintf("list<%s>", field.Type)
 	}
 
-	// 处理可选字段
+	// 处理必填与可选字段
 	optionalFlag := ""
-	if field.Optional {
+	if field.Required {
+		optionalFlag = "required "
+	} else if field.Optional {
 		optionalFlag = "optional "
 	}
 
@@ -127,6 +234,26 @@ func (e *ThriftGenerate) encodeField(field *thrift.ThriftField, index int, inden
 	}
 }
 
+// encodeTypedef 编码 typedef
+func (e *ThriftGenerate) encodeTypedef(typedef *thrift.ThriftTypedef) {
+	if typedef.Description != "" {
+		e.dst.WriteString(fmt.Sprintf("// %s\n", typedef.Description))
+	}
+	e.dst.WriteString(fmt.Sprintf("typedef %s %s", typedef.Type, typedef.Name))
+	if len(typedef.Options) > 0 {
+		e.dst.WriteString(" (\n")
+		for i, option := range typedef.Options {
+			if i > 0 {
+				e.dst.WriteString(",\n")
+			}
+			e.dst.WriteString("    ")
+			e.encodeOption(option)
+		}
+		e.dst.WriteString("\n)")
+	}
+	e.dst.WriteString("\n\n")
+}
+
 // encodeMessage 递归编码 structs，包括嵌套的 structs 和 enums
 func (e *ThriftGenerate) encodeMessage(message *thrift.ThriftStruct, indentLevel int) {
 	indent := strings.Repeat("    ", indentLevel)
@@ -135,9 +262,11 @@ func (e *ThriftGenerate) encodeMessage(message *thrift.ThriftStruct, indentLevel
 	}
 	e.dst.WriteString(fmt.Sprintf("%sstruct %s {\n", indent, message.Name))
 
-	// 字段：遍历字段并分配索引
+	e.encodeReserved(message.Reserved, indentLevel+1)
+
+	// 字段：优先使用转换器分配的 ID，否则按顺序分配索引
 	for i, field := range message.Fields {
-		e.encodeField(field, i+1, indentLevel+1) // `i+1` 分配基于1的索引
+		e.encodeField(field, fieldID(field, i), indentLevel+1)
 	}
 
 	e.dst.WriteString(fmt.Sprintf("%s}", indent))
@@ -159,6 +288,27 @@ func (e *ThriftGenerate) encodeMessage(message *thrift.ThriftStruct, indentLevel
 	e.dst.WriteString("\n")
 }
 
+// encodeReserved 以注释形式输出已删除字段的 ID，Thrift 没有 reserved 语法，锁文件解析时会读取该注释
+func (e *ThriftGenerate) encodeReserved(reserved []int, indentLevel int) {
+	if len(reserved) == 0 {
+		return
+	}
+	indent := strings.Repeat("    ", indentLevel)
+	ids := make([]string, 0, len(reserved))
+	for _, id := range reserved {
+		ids = append(ids, strconv.Itoa(id))
+	}
+	e.dst.WriteString(fmt.Sprintf("%s// reserved: %s\n", indent, strings.Join(ids, ", ")))
+}
+
+// fieldID 返回字段 ID，未分配时回退为基于 1 的位置索引
+func fieldID(field *thrift.ThriftField, index int) int {
+	if field.ID > 0 {
+		return field.ID
+	}
+	return index + 1
+}
+
 // encodeService 编码服务定义
 func (e *ThriftGenerate) encodeService(service *thrift.ThriftService) {
 	if service.Description != "" {
@@ -196,9 +346,11 @@ func (e *ThriftGenerate) encodeUnion(union *thrift.ThriftUnion, indentLevel int)
 	indent := strings.Repeat("    ", indentLevel)
 	e.dst.WriteString(fmt.Sprintf("%sunion %s {\n", indent, union.Name))
 
+	e.encodeReserved(union.Reserved, indentLevel+1)
+
 	// 遍历 union 的字段
 	for i, field := range union.Fields {
-		e.encodeField(field, i+1, indentLevel+1) // `i+1` 用于分配1-based索引
+		e.encodeField(field, fieldID(field, i), indentLevel+1)
 	}
 
 	e.dst.WriteString(fmt.Sprintf("%s}\n\n", indent))
@@ -0,0 +1,112 @@
+/*
+ * Copyright 2024 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package generate
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hertz-contrib/swagger-generate/swagger2idl/thrift"
+)
+
+// thriftStructFile returns a ThriftFile declaring a struct with a field of each of the given types
+func thriftStructFile(fileName, structName string, fieldTypes ...string) *thrift.ThriftFile {
+	message := &thrift.ThriftStruct{Name: structName}
+	for i, fieldType := range fieldTypes {
+		message.Fields = append(message.Fields, &thrift.ThriftField{ID: i + 1, Name: "F" + fieldType, Type: fieldType})
+	}
+	return &thrift.ThriftFile{FileName: fileName, Structs: []*thrift.ThriftStruct{message}}
+}
+
+func TestThriftGenerateFiles(t *testing.T) {
+	tests := []struct {
+		name         string
+		files        func() []*thrift.ThriftFile
+		wantIncludes map[string][]string
+		wantContains map[string]string
+		wantErr      string
+	}{
+		{
+			name: "includes across directories",
+			files: func() []*thrift.ThriftFile {
+				return []*thrift.ThriftFile{
+					thriftStructFile("api.thrift", "Pet", "Owner", "list<Tag>"),
+					thriftStructFile("a/owner.thrift", "Owner", "Tag"),
+					thriftStructFile("b/tag.thrift", "Tag"),
+				}
+			},
+			wantIncludes: map[string][]string{
+				"api.thrift":     {"a/owner.thrift", "b/tag.thrift"},
+				"a/owner.thrift": {"../b/tag.thrift"},
+			},
+			wantContains: map[string]string{
+				"api.thrift":     "2: list<tag.Tag> FlistTag",
+				"a/owner.thrift": "1: tag.Tag FTag",
+			},
+		},
+		{
+			name: "files sharing a name in different directories",
+			files: func() []*thrift.ThriftFile {
+				return []*thrift.ThriftFile{
+					thriftStructFile("api.thrift", "Pet", "Owner", "Tag"),
+					thriftStructFile("a/common.thrift", "Owner"),
+					thriftStructFile("b/common.thrift", "Tag"),
+				}
+			},
+			wantErr: "api.thrift includes a/common.thrift and b/common.thrift, which share the prefix common",
+		},
+		{
+			name: "file sharing a name with an existing include",
+			files: func() []*thrift.ThriftFile {
+				files := []*thrift.ThriftFile{
+					thriftStructFile("api.thrift", "Pet", "Owner"),
+					thriftStructFile("a/common.thrift", "Owner"),
+				}
+				files[0].Includes = []string{"common.thrift"}
+				return files
+			},
+			wantErr: "api.thrift includes common.thrift and a/common.thrift, which share the prefix common",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := tt.files()
+			contents, err := NewThriftGenerate().GenerateFiles(files)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("GenerateFiles() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GenerateFiles() error = %v", err)
+			}
+
+			for _, file := range files {
+				want := tt.wantIncludes[file.FileName]
+				if strings.Join(file.Includes, ",") != strings.Join(want, ",") {
+					t.Errorf("includes of %s = %v, want %v", file.FileName, file.Includes, want)
+				}
+			}
+			for fileName, want := range tt.wantContains {
+				if !strings.Contains(contents[fileName], want) {
+					t.Errorf("%s does not contain %q:\n%s", fileName, want, contents[fileName])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,302 @@
+/*
+ * Copyright 2024 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package idl is the library entry point of swagger2idl. It loads an OpenAPI 3 or Swagger 2.0
+// spec, converts it into the Protobuf or Thrift IR and renders the IDL in a single call:
+//
+//	result, err := idl.ConvertFile("openapi.yaml", idl.DefaultOptions(idl.TargetProto))
+//	if err != nil {
+//		return err
+//	}
+//	fmt.Print(result.Content)
+package idl
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/hertz-contrib/swagger-generate/swagger2idl/converter"
+	"github.com/hertz-contrib/swagger-generate/swagger2idl/generate"
+	"github.com/hertz-contrib/swagger-generate/swagger2idl/parser"
+	"github.com/hertz-contrib/swagger-generate/swagger2idl/protobuf"
+	"github.com/hertz-contrib/swagger-generate/swagger2idl/thrift"
+	"github.com/hertz-contrib/swagger-generate/swagger2idl/utils"
+)
+
+// Target is the IDL a spec is converted into
+type Target string
+
+const (
+	TargetProto  Target = "proto"  // Protobuf (proto3)
+	TargetThrift Target = "thrift" // Thrift
+)
+
+// SplitMode selects how the output is distributed over files
+type SplitMode string
+
+const (
+	SplitNone      SplitMode = ""        // Everything in a single file
+	SplitByService SplitMode = "service" // One file per service plus a common file for shared types
+	SplitBySource  SplitMode = "source"  // One file per document of a multi-document spec
+)
+
+// defaultRootName names the root file of a split spec without a file path
+const defaultRootName = "openapi"
+
+// Options configures a conversion. The embedded ConvertOption holds the options of the converters,
+// its zero value selects lenient requiredness, no proto presence tracking, sorted output, embedded
+// parameter and header fragments and flattened allOf schemas.
+type Options struct {
+	converter.ConvertOption
+
+	Target     Target              // Output IDL, required
+	Split      SplitMode           // File layout of the output
+	ProtoLock  *protobuf.FieldLock // Field numbers of a previous Protobuf generation, may be nil
+	ThriftLock *thrift.FieldLock   // Field IDs of a previous Thrift generation, may be nil
+	Strict     bool                // Fail with a *DiagnosticsError if the conversion reports any diagnostic
+	// FilePath is the path the spec was read from. It resolves references to other files and
+	// names the root file of split output, "-" stands for the working directory.
+	FilePath string
+}
+
+// Result holds the IR and the rendered IDL of a conversion. Only the fields of the selected
+// target are set.
+type Result struct {
+	Target Target
+
+	Proto      *protobuf.ProtoFile   // Protobuf IR of the whole spec
+	ProtoFiles []*protobuf.ProtoFile // Protobuf IR of each file of split output
+	ProtoLock  *protobuf.FieldLock   // Field numbers assigned by the conversion
+
+	Thrift      *thrift.ThriftFile   // Thrift IR of the whole spec
+	ThriftFiles []*thrift.ThriftFile // Thrift IR of each file of split output
+	ThriftLock  *thrift.FieldLock    // Field IDs assigned by the conversion
+
+	Content string            // Rendered IDL of single-file output
+	Files   map[string]string // Rendered IDL of split output keyed by relative file path
+
+	Diagnostics []converter.Diagnostic // Lossy decisions made by the conversion
+}
+
+// DiagnosticsError is returned in strict mode when the conversion reports diagnostics
+type DiagnosticsError struct {
+	Diagnostics []converter.Diagnostic
+}
+
+func (e *DiagnosticsError) Error() string {
+	return fmt.Sprintf("conversion reported %d diagnostic(s) in strict mode", len(e.Diagnostics))
+}
+
+// DefaultOptions returns the options used by the command line for the given target
+func DefaultOptions(target Target) Options {
+	return Options{
+		ConvertOption: converter.ConvertOption{
+			NamingOption:       true,
+			RequirednessOption: converter.RequirednessLenient,
+			PresenceOption:     converter.PresenceNone,
+			OrderOption:        converter.OrderSorted,
+			FragmentOption:     converter.FragmentEmbed,
+			AllOfOption:        converter.AllOfFlatten,
+		},
+		Target: target,
+	}
+}
+
+// ConvertFile converts the spec stored at filePath, or read from stdin if filePath is "-"
+func ConvertFile(filePath string, opts Options) (*Result, error) {
+	data, err := parser.ReadSpecFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	opts.FilePath = filePath
+	return ConvertData(data, opts)
+}
+
+// ConvertData converts a JSON or YAML spec document
+func ConvertData(data []byte, opts Options) (*Result, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	filePath := opts.FilePath
+	if filePath == "" {
+		filePath = parser.StdinPath
+	}
+	spec, err := parser.LoadOpenAPISpecData(data, filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var specOrder *parser.SpecOrder
+	if opts.OrderOption == converter.OrderSpec {
+		specOrder, err = parser.ParseSpecOrder(data, spec)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read spec order: %w", err)
+		}
+	}
+	return convert(spec, specOrder, opts)
+}
+
+// ConvertSpec converts a loaded spec. The declaration order of the document is not known to a
+// loaded spec, so the spec order option falls back to sorted output.
+func ConvertSpec(spec *openapi3.T, opts Options) (*Result, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	return convert(spec, nil, opts)
+}
+
+// validate checks the option values
+func (o *Options) validate() error {
+	if o.Target != TargetProto && o.Target != TargetThrift {
+		return fmt.Errorf("invalid output type: %q", o.Target)
+	}
+	switch o.RequirednessOption {
+	case "", converter.RequirednessLenient, converter.RequirednessStrict:
+	default:
+		return fmt.Errorf("invalid requiredness policy: %q", o.RequirednessOption)
+	}
+	switch o.PresenceOption {
+	case "", converter.PresenceOptional, converter.PresenceWrapper, converter.PresenceNone:
+	default:
+		return fmt.Errorf("invalid presence option: %q", o.PresenceOption)
+	}
+	switch o.OrderOption {
+	case "", converter.OrderSorted, converter.OrderSpec:
+	default:
+		return fmt.Errorf("invalid order option: %q", o.OrderOption)
+	}
+	switch o.FragmentOption {
+	case "", converter.FragmentEmbed, converter.FragmentReference:
+	default:
+		return fmt.Errorf("invalid fragment option: %q", o.FragmentOption)
+	}
+	switch o.AllOfOption {
+	case "", converter.AllOfFlatten, converter.AllOfEmbed:
+	default:
+		return fmt.Errorf("invalid allOf option: %q", o.AllOfOption)
+	}
+	for i := range o.TypeMappings {
+		if err := o.TypeMappings[i].Validate(); err != nil {
+			return fmt.Errorf("invalid type mapping %d: %w", i+1, err)
+		}
+	}
+	switch o.Split {
+	case SplitNone, SplitByService, SplitBySource:
+	default:
+		return fmt.Errorf("invalid split mode: %q", o.Split)
+	}
+	return nil
+}
+
+// rootName returns the name of the root file of split output
+func (o *Options) rootName() string {
+	switch o.FilePath {
+	case "":
+		return defaultRootName
+	case parser.StdinPath:
+		return "stdin"
+	}
+	base := filepath.Base(o.FilePath)
+	return utils.ToSnakeCase(strings.TrimSuffix(base, filepath.Ext(base)))
+}
+
+// convert runs the converter and the generator of the target
+func convert(spec *openapi3.T, specOrder *parser.SpecOrder, opts Options) (*Result, error) {
+	option := opts.ConvertOption
+
+	// The documents of the external references tell apart their schemas of the same name
+	filePath := opts.FilePath
+	if filePath == "" {
+		filePath = parser.StdinPath
+	}
+	schemaSources := parser.ResolveSchemaSources(filePath, spec)
+
+	result := &Result{Target: opts.Target}
+	switch opts.Target {
+	case TargetProto:
+		protoConv := converter.NewProtoConverter(spec, &option)
+		protoConv.SetSpecOrder(specOrder)
+		protoConv.SetSchemaSources(schemaSources)
+		if err := protoConv.Convert(); err != nil {
+			return nil, fmt.Errorf("error during conversion: %w", err)
+		}
+		result.Proto = protoConv.ProtoFile
+		result.Diagnostics = protoConv.Diagnostics()
+
+		protoEngine := generate.NewProtoGenerate()
+		protoEngine.SetKeepOrder(opts.OrderOption == converter.OrderSpec)
+		protoEngine.SetFieldLock(opts.ProtoLock)
+		switch opts.Split {
+		case SplitByService:
+			result.ProtoFiles = protoConv.SplitByService()
+		case SplitBySource:
+			result.ProtoFiles = protoConv.SplitBySource(opts.rootName())
+		}
+		if opts.Split == SplitNone {
+			content, err := protoEngine.Generate(result.Proto)
+			if err != nil {
+				return nil, fmt.Errorf("error generating proto file: %w", err)
+			}
+			result.Content = content
+		} else {
+			files, err := protoEngine.GenerateFiles(result.ProtoFiles)
+			if err != nil {
+				return nil, fmt.Errorf("error generating proto files: %w", err)
+			}
+			result.Files = files
+		}
+		result.ProtoLock = protoEngine.FieldLock()
+	case TargetThrift:
+		thriftConv := converter.NewThriftConverter(spec, &option)
+		thriftConv.SetFieldLock(opts.ThriftLock)
+		thriftConv.SetSpecOrder(specOrder)
+		thriftConv.SetSchemaSources(schemaSources)
+		if err := thriftConv.Convert(); err != nil {
+			return nil, fmt.Errorf("error during conversion: %w", err)
+		}
+		result.Thrift = thriftConv.ThriftFile
+		result.Diagnostics = thriftConv.Diagnostics()
+		result.ThriftLock = thriftConv.FieldLock()
+
+		thriftEngine := generate.NewThriftGenerate()
+		switch opts.Split {
+		case SplitByService:
+			result.ThriftFiles = thriftConv.SplitByService()
+		case SplitBySource:
+			result.ThriftFiles = thriftConv.SplitBySource(opts.rootName())
+		}
+		if opts.Split == SplitNone {
+			content, err := thriftEngine.Generate(result.Thrift)
+			if err != nil {
+				return nil, fmt.Errorf("error generating thrift file: %w", err)
+			}
+			result.Content = content
+		} else {
+			files, err := thriftEngine.GenerateFiles(result.ThriftFiles)
+			if err != nil {
+				return nil, fmt.Errorf("error generating thrift files: %w", err)
+			}
+			result.Files = files
+		}
+	}
+
+	if opts.Strict && len(result.Diagnostics) > 0 {
+		return nil, &DiagnosticsError{Diagnostics: result.Diagnostics}
+	}
+	return result, nil
+}
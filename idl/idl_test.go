@@ -0,0 +1,246 @@
+/*
+ * Copyright 2024 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package idl
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/hertz-contrib/swagger-generate/swagger2idl/parser"
+)
+
+func TestConvertDataStrict(t *testing.T) {
+	tests := []struct {
+		name            string
+		spec            string
+		target          Target
+		strict          bool
+		wantDiagnostics int
+	}{
+		{
+			name: "lossy spec",
+			spec: `
+        free:
+          type: object
+          additionalProperties: true`,
+			target:          TargetProto,
+			wantDiagnostics: 1,
+		},
+		{
+			name: "lossy spec in strict mode",
+			spec: `
+        free:
+          type: object
+          additionalProperties: true`,
+			target:          TargetThrift,
+			strict:          true,
+			wantDiagnostics: 1,
+		},
+		{
+			name: "lossless spec in strict mode",
+			spec: `
+        kind:
+          type: string
+          enum: [a, b]`,
+			target: TargetThrift,
+			strict: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultOptions(tt.target)
+			opts.Strict = tt.strict
+			result, err := ConvertData([]byte(`openapi: 3.0.3
+info: {title: test, version: "1"}
+paths: {}
+components:
+  schemas:
+    Item:
+      type: object
+      properties:`+tt.spec+"\n"), opts)
+
+			var diagnosticsErr *DiagnosticsError
+			if tt.strict && tt.wantDiagnostics > 0 {
+				if !errors.As(err, &diagnosticsErr) {
+					t.Fatalf("ConvertData() error = %v, want a *DiagnosticsError", err)
+				}
+				if len(diagnosticsErr.Diagnostics) != tt.wantDiagnostics {
+					t.Errorf("DiagnosticsError.Diagnostics = %v, want %d", diagnosticsErr.Diagnostics, tt.wantDiagnostics)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ConvertData() error = %v", err)
+			}
+			if len(result.Diagnostics) != tt.wantDiagnostics {
+				t.Errorf("Result.Diagnostics = %v, want %d", result.Diagnostics, tt.wantDiagnostics)
+			}
+			if result.Content == "" {
+				t.Error("Result.Content is empty")
+			}
+		})
+	}
+}
+
+func TestConvertFileExampleStrict(t *testing.T) {
+	for _, target := range []Target{TargetProto, TargetThrift} {
+		opts := DefaultOptions(target)
+		opts.Strict = true
+		if _, err := ConvertFile("../example/openapi.yaml", opts); err != nil {
+			t.Errorf("ConvertFile(%s) error = %v", target, err)
+		}
+	}
+}
+
+// petSpec declares a service with a method responding with a referenced schema
+const petSpec = `openapi: 3.0.3
+info: {title: test, version: "1"}
+paths:
+  /pets:
+    get:
+      operationId: listPets
+      tags: [pet]
+      responses:
+        "200":
+          description: ok
+          content:
+            application/json:
+              schema: {$ref: '#/components/schemas/Pet'}
+components:
+  schemas:
+    Pet:
+      type: object
+      properties:
+        name: {type: string}
+`
+
+func TestConvertData(t *testing.T) {
+	tests := []struct {
+		name        string
+		target      Target
+		split       SplitMode
+		wantTypes   []string
+		wantContent string
+		wantFiles   int
+		wantErr     string
+	}{
+		{
+			name:        "proto",
+			target:      TargetProto,
+			wantTypes:   []string{"ListPetsResponse", "Pet"},
+			wantContent: "message Pet {",
+		},
+		{
+			name:        "thrift",
+			target:      TargetThrift,
+			wantTypes:   []string{"ListPetsResponse", "Pet"},
+			wantContent: "struct Pet {",
+		},
+		{
+			name:      "proto split by service",
+			target:    TargetProto,
+			split:     SplitByService,
+			wantTypes: []string{"ListPetsResponse", "Pet"},
+			wantFiles: 2,
+		},
+		{
+			name:      "thrift split by service",
+			target:    TargetThrift,
+			split:     SplitByService,
+			wantTypes: []string{"ListPetsResponse", "Pet"},
+			wantFiles: 2,
+		},
+		{
+			name:    "unknown target",
+			target:  "avro",
+			wantErr: "avro",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultOptions(tt.target)
+			opts.Split = tt.split
+			result, err := ConvertData([]byte(petSpec), opts)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ConvertData() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ConvertData() error = %v", err)
+			}
+
+			var types []string
+			switch tt.target {
+			case TargetProto:
+				if result.Thrift != nil {
+					t.Error("Result.Thrift is set for a proto conversion")
+				}
+				for _, message := range result.Proto.Messages {
+					types = append(types, message.Name)
+				}
+			case TargetThrift:
+				if result.Proto != nil {
+					t.Error("Result.Proto is set for a thrift conversion")
+				}
+				for _, message := range result.Thrift.Structs {
+					types = append(types, message.Name)
+				}
+			}
+			for _, want := range tt.wantTypes {
+				if !containsString(types, want) {
+					t.Errorf("IR types = %v, lack %s", types, want)
+				}
+			}
+			if !strings.Contains(result.Content, tt.wantContent) {
+				t.Errorf("Result.Content lacks %q:\n%s", tt.wantContent, result.Content)
+			}
+			if len(result.Files) != tt.wantFiles {
+				t.Errorf("Result.Files = %d files, want %d", len(result.Files), tt.wantFiles)
+			}
+		})
+	}
+}
+
+func TestConvertSpec(t *testing.T) {
+	spec, err := parser.LoadOpenAPISpecData([]byte(petSpec), parser.StdinPath)
+	if err != nil {
+		t.Fatalf("LoadOpenAPISpecData() error = %v", err)
+	}
+	result, err := ConvertSpec(spec, DefaultOptions(TargetProto))
+	if err != nil {
+		t.Fatalf("ConvertSpec() error = %v", err)
+	}
+	if len(result.Proto.Services) != 1 || result.Proto.Services[0].Methods[0].Name != "ListPets" {
+		t.Errorf("Result.Proto.Services = %+v, want a service with ListPets", result.Proto.Services)
+	}
+	if !strings.Contains(result.Content, "rpc ListPets(google.protobuf.Empty) returns (ListPetsResponse)") {
+		t.Errorf("Result.Content lacks the ListPets method:\n%s", result.Content)
+	}
+}
+
+// containsString reports whether list holds s
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -1,12 +1,15 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"os"
 	"path/filepath"
 
 	"github.com/hertz-contrib/swagger-generate/swagger2idl/converter"
-	"github.com/hertz-contrib/swagger-generate/swagger2idl/generate"
+	"github.com/hertz-contrib/swagger-generate/swagger2idl/idl"
 	"github.com/hertz-contrib/swagger-generate/swagger2idl/parser"
 	"github.com/urfave/cli/v2"
 )
@@ -14,12 +17,31 @@ import (
 const defaultProtoFilename = "output.proto"
 const defaultThriftFilename = "output.thrift"
 
+// stdoutPath is the output path that writes the generated IDL to stdout
+const stdoutPath = "-"
+
+// stdinRootName names the output of a spec read from stdin
+const stdinRootName = "stdin"
+
 var (
 	outputType    string
 	outputFile    string
 	openapiOption bool
 	apiOption     bool
 	namingOption  bool
+	lockFile      string
+	requiredness  string
+	presence      string
+	order         string
+	fragments     string
+	discriminator bool
+	allOf         string
+	split         bool
+	splitRefs     bool
+	outDir        string
+	strict        bool
+	typeMapping   string
+	typeMappings  []parser.TypeMapping
 )
 
 func main() {
@@ -37,7 +59,7 @@ func main() {
 			&cli.StringFlag{
 				Name:        "output",
 				Aliases:     []string{"o"},
-				Usage:       "Specify output file path. If not provided, defaults to output.proto or output.thrift based on the output type.",
+				Usage:       "Specify output file path, or '-' to write to stdout. If not provided, defaults to output.proto or output.thrift based on the output type.",
 				Destination: &outputFile,
 			},
 			&cli.BoolFlag{
@@ -59,6 +81,77 @@ func main() {
 				Value:       true,
 				Destination: &namingOption,
 			},
+			&cli.StringFlag{
+				Name:        "lock",
+				Aliases:     []string{"l"},
+				Usage:       "Keep field numbers stable using a previously generated IDL file or a JSON lock file. JSON lock files are updated after generation.",
+				Destination: &lockFile,
+			},
+			&cli.StringFlag{
+				Name:        "requiredness",
+				Aliases:     []string{"r"},
+				Usage:       "Thrift requiredness policy: 'lenient' (required fields use default requiredness) or 'strict' (required fields are marked required). Other fields are always optional.",
+				Value:       converter.RequirednessLenient,
+				Destination: &requiredness,
+			},
+			&cli.StringFlag{
+				Name:        "presence",
+				Aliases:     []string{"p"},
+				Usage:       "Proto presence tracking for non-required or nullable scalars: 'none', 'optional' (proto3 optional) or 'wrapper' (google.protobuf wrapper types).",
+				Value:       converter.PresenceNone,
+				Destination: &presence,
+			},
+			&cli.StringFlag{
+				Name:        "order",
+				Usage:       "Declaration order of the output: 'sorted' (by name) or 'spec' (as declared in the OpenAPI document).",
+				Value:       converter.OrderSorted,
+				Destination: &order,
+			},
+			&cli.StringFlag{
+				Name:        "fragments",
+				Usage:       "Use of parameters and headers referenced from components: 'embed' (fields are added to each message) or 'reference' (a message per group of parameters used together and per header, referenced by a field of each message).",
+				Value:       converter.FragmentEmbed,
+				Destination: &fragments,
+			},
+			&cli.BoolFlag{
+				Name:        "discriminator-enum",
+				Usage:       "Declare an enum of the discriminator values of each oneOf or anyOf with a discriminator.",
+				Destination: &discriminator,
+			},
+			&cli.StringFlag{
+				Name:        "allof",
+				Usage:       "Conversion of allOf schemas: 'flatten' (all parts are merged into one message) or 'embed' (inline parts are merged, referenced parts are kept as fields of their type).",
+				Value:       converter.AllOfFlatten,
+				Destination: &allOf,
+			},
+			&cli.BoolFlag{
+				Name:        "split",
+				Aliases:     []string{"s"},
+				Usage:       "Write one IDL file per service plus a common file for shared types. The output path is used as the output directory and --type is required.",
+				Destination: &split,
+			},
+			&cli.BoolFlag{
+				Name:        "split-refs",
+				Usage:       "Write one IDL file per document of a multi-document spec, mirroring the paths of the referenced files. The output path is used as the output directory and --type is required.",
+				Destination: &splitRefs,
+			},
+			&cli.StringFlag{
+				Name:        "out-dir",
+				Aliases:     []string{"d"},
+				Usage:       "Convert several files, globs or directories of specs concurrently, writing each output under this directory at the path of its spec relative to the directory, the glob prefix before the first wildcard or the working directory. --type is required.",
+				Destination: &outDir,
+			},
+			&cli.BoolFlag{
+				Name:        "strict",
+				Usage:       "Fail if the conversion reports any warning or error about constructs that are dropped or converted with a loss of information.",
+				Destination: &strict,
+			},
+			&cli.StringFlag{
+				Name:        "type-mapping",
+				Aliases:     []string{"m"},
+				Usage:       "Map scalar schemas onto custom IDL types using a JSON or YAML list of mappings keyed by type, format and extension. The mappings take precedence over the default ones.",
+				Destination: &typeMapping,
+			},
 		},
 		Action: func(c *cli.Context) error {
 			// Get remaining non-flag arguments (e.g., file paths)
@@ -69,10 +162,58 @@ func main() {
 				log.Fatal("Please provide the path to the OpenAPI file.")
 			}
 
-			openapiFile := args[0]
+			// Validate the conversion options
+			if requiredness != converter.RequirednessLenient && requiredness != converter.RequirednessStrict {
+				log.Fatalf("Invalid requiredness policy: %s", requiredness)
+			}
+			if presence != converter.PresenceOptional && presence != converter.PresenceWrapper && presence != converter.PresenceNone {
+				log.Fatalf("Invalid presence option: %s", presence)
+			}
+			if order != converter.OrderSorted && order != converter.OrderSpec {
+				log.Fatalf("Invalid order option: %s", order)
+			}
+			if fragments != converter.FragmentEmbed && fragments != converter.FragmentReference {
+				log.Fatalf("Invalid fragments option: %s", fragments)
+			}
+			if allOf != converter.AllOfFlatten && allOf != converter.AllOfEmbed {
+				log.Fatalf("Invalid allof option: %s", allOf)
+			}
+			if split && splitRefs {
+				log.Fatal("--split and --split-refs cannot be used together.")
+			}
+
+			inputs, batch, err := expandInputs(args)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if typeMapping != "" {
+				if typeMappings, err = parser.LoadTypeMappings(typeMapping); err != nil {
+					log.Fatal(err)
+				}
+			}
+			if batch || outDir != "" {
+				return runBatch(inputs)
+			}
+
+			openapiFile := inputs[0].path
+
+			if outputFile == stdoutPath {
+				if split || splitRefs {
+					log.Fatal("Split output cannot be written to stdout.")
+				}
+				if outputType == "" {
+					log.Fatal("Please use --type to specify the output type when writing to stdout.")
+				}
+			}
+			if (split || splitRefs) && outputType == "" {
+				log.Fatal("Please use --type to specify the output type when splitting the output.")
+			}
+			if (split || splitRefs) && outputFile == "" {
+				outputFile = "."
+			}
 
 			// Automatically determine output type based on file extension if not provided
-			if outputType == "" && outputFile != "" {
+			if outputType == "" && outputFile != "" && outputFile != stdoutPath {
 				ext := filepath.Ext(outputFile)
 				switch ext {
 				case ".proto":
@@ -95,70 +236,139 @@ func main() {
 				}
 			}
 
-			// Load the OpenAPI specification
-			spec, err := parser.LoadOpenAPISpec(openapiFile)
+			outputs, diagnostics, err := convertSpec(openapiFile, outputFile, lockFile)
+			printDiagnostics(openapiFile, diagnostics)
 			if err != nil {
-				log.Fatalf("Failed to load OpenAPI file: %v", err)
+				log.Fatal(err)
 			}
-
-			// Initialize ConvertOption with command-line flag values
-			converterOption := &converter.ConvertOption{
-				OpenapiOption: openapiOption,
-				ApiOption:     apiOption,
-				NamingOption:  namingOption,
+			if err = writeOutputs(outputs); err != nil {
+				log.Fatal(err)
 			}
 
-			var idlContent string
-			var file *os.File
-			var errFile error
+			return nil
+		},
+	}
 
-			switch outputType {
-			case "proto":
-				protoConv := converter.NewProtoConverter(spec, converterOption)
+	// Run the app
+	err := app.Run(os.Args)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
 
-				if err = protoConv.Convert(); err != nil {
-					log.Fatalf("Error during conversion: %v", err)
-				}
-				protoEngine := generate.NewProtoGenerate()
+// convertSpec converts the OpenAPI file into IDL of the selected output type and returns the
+// contents of the output files keyed by path along with the diagnostics of the conversion. When
+// splitting, outputFile is the output directory.
+func convertSpec(openapiFile, outputFile, lockFile string) (map[string]string, []converter.Diagnostic, error) {
+	// Initialize the options with command-line flag values
+	options := idl.Options{
+		ConvertOption: converter.ConvertOption{
+			OpenapiOption:      openapiOption,
+			ApiOption:          apiOption,
+			NamingOption:       namingOption,
+			RequirednessOption: requiredness,
+			PresenceOption:     presence,
+			OrderOption:        order,
+			FragmentOption:     fragments,
+			DiscriminatorEnum:  discriminator,
+			AllOfOption:        allOf,
+			TypeMappings:       typeMappings,
+		},
+		Target: idl.Target(outputType),
+		Strict: strict,
+	}
+	if split {
+		options.Split = idl.SplitByService
+	} else if splitRefs {
+		options.Split = idl.SplitBySource
+	}
 
-				idlContent, err = protoEngine.Generate(protoConv.GetIdl())
+	// A lock is either a JSON lock file or IDL generated previously for the same output type
+	jsonLock := filepath.Ext(lockFile) == ".json"
+	if lockFile != "" && !jsonLock && filepath.Ext(lockFile) != "."+outputType {
+		return nil, nil, fmt.Errorf("lock file %s must be a .json lock file or a .%s file generated previously", lockFile, outputType)
+	}
+	if lockFile != "" {
+		var err error
+		switch options.Target {
+		case idl.TargetProto:
+			options.ProtoLock, err = parser.LoadProtoFieldLock(lockFile)
+		case idl.TargetThrift:
+			options.ThriftLock, err = parser.LoadThriftFieldLock(lockFile)
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to load lock file: %w", err)
+		}
+	}
 
-				file, errFile = os.Create(outputFile)
-			case "thrift":
-				thriftConv := converter.NewThriftConverter(spec, converterOption)
+	// Load the OpenAPI specification, from stdin when the file is "-"
+	result, err := idl.ConvertFile(openapiFile, options)
+	if err != nil {
+		var diagnosticsErr *idl.DiagnosticsError
+		if errors.As(err, &diagnosticsErr) {
+			return nil, diagnosticsErr.Diagnostics, err
+		}
+		return nil, nil, fmt.Errorf("failed to convert OpenAPI file: %w", err)
+	}
 
-				if err = thriftConv.Convert(); err != nil {
-					log.Fatalf("Error during conversion: %v", err)
-				}
-				thriftEngine := generate.NewThriftGenerate()
+	// Output file contents keyed by path
+	outputs := make(map[string]string)
+	if options.Split == idl.SplitNone {
+		outputs[outputFile] = result.Content
+	}
+	for name, content := range result.Files {
+		outputs[filepath.Join(outputFile, name)] = content
+	}
 
-				idlContent, err = thriftEngine.Generate(thriftConv.GetIdl())
+	// JSON lock files are rewritten, IDL lock files are the previous output itself
+	if jsonLock {
+		var lock interface{} = result.ProtoLock
+		if options.Target == idl.TargetThrift {
+			lock = result.ThriftLock
+		}
+		if outputs[lockFile], err = lockFileContent(lock); err != nil {
+			return nil, result.Diagnostics, fmt.Errorf("failed to write lock file: %w", err)
+		}
+	}
 
-				file, errFile = os.Create(outputFile)
-			default:
-				log.Fatalf("Invalid output type: %s", outputType)
-			}
+	return outputs, result.Diagnostics, nil
+}
 
-			if errFile != nil {
-				log.Fatalf("Failed to create file: %v", errFile)
-			}
-			defer func() {
-				if err := file.Close(); err != nil {
-					log.Printf("Error closing file: %v", err)
-				}
-			}()
+// printDiagnostics reports the diagnostics of the conversion of a spec on stderr
+func printDiagnostics(openapiFile string, diagnostics []converter.Diagnostic) {
+	if openapiFile == parser.StdinPath {
+		openapiFile = stdinRootName
+	}
+	for _, diagnostic := range diagnostics {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", openapiFile, diagnostic)
+	}
+}
 
-			if _, err = file.WriteString(idlContent); err != nil {
-				log.Fatalf("Error writing to file: %v", err)
+// writeOutputs writes the output files, creating their directories as needed. The "-" path is
+// written to stdout.
+func writeOutputs(outputs map[string]string) error {
+	for path, content := range outputs {
+		if path == stdoutPath {
+			if _, err := os.Stdout.WriteString(content); err != nil {
+				return fmt.Errorf("error writing to stdout: %w", err)
 			}
-
-			return nil
-		},
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return fmt.Errorf("failed to create output directory: %w", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			return fmt.Errorf("error writing to file: %w", err)
+		}
 	}
+	return nil
+}
 
-	// Run the app
-	err := app.Run(os.Args)
+// lockFileContent encodes the field numbers of a generation as a JSON lock file
+func lockFileContent(lock interface{}) (string, error) {
+	data, err := json.MarshalIndent(lock, "", "  ")
 	if err != nil {
-		log.Fatal(err)
+		return "", err
 	}
+	return string(data) + "\n", nil
 }
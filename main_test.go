@@ -0,0 +1,107 @@
+package main
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+// withStdin runs f with data as the standard input
+func withStdin(t *testing.T, data string, f func()) {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		w.WriteString(data)
+		w.Close()
+	}()
+	stdin := os.Stdin
+	os.Stdin = r
+	defer func() {
+		os.Stdin = stdin
+		r.Close()
+	}()
+	f()
+}
+
+// captureStdout returns what f writes to the standard output
+func captureStdout(t *testing.T, f func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	f()
+	os.Stdout = stdout
+	w.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestConvertSpecStdin(t *testing.T) {
+	tests := []struct {
+		name   string
+		spec   string
+		target string
+		want   []string
+	}{
+		{
+			name: "YAML to proto",
+			spec: `openapi: 3.0.3
+info: {title: test, version: "1"}
+paths: {}
+components:
+  schemas:
+    Pet:
+      type: object
+      properties:
+        name: {type: string}
+`,
+			target: "proto",
+			want:   []string{"message Pet {", "string name = 1;"},
+		},
+		{
+			name:   "JSON to thrift",
+			spec:   `{"openapi": "3.0.3", "info": {"title": "test", "version": "1"}, "paths": {}, "components": {"schemas": {"Pet": {"type": "object", "properties": {"name": {"type": "string"}}}}}}`,
+			target: "thrift",
+			want:   []string{"struct Pet {", "1: optional string name"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outputType = tt.target
+			defer func() { outputType = "" }()
+
+			var outputs map[string]string
+			var err error
+			withStdin(t, tt.spec, func() {
+				outputs, _, err = convertSpec("-", stdoutPath, "")
+			})
+			if err != nil {
+				t.Fatalf("convertSpec() error = %v", err)
+			}
+			if len(outputs) != 1 {
+				t.Fatalf("outputs = %v, want stdout only", outputs)
+			}
+
+			var writeErr error
+			output := captureStdout(t, func() { writeErr = writeOutputs(outputs) })
+			if writeErr != nil {
+				t.Fatalf("writeOutputs() error = %v", writeErr)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(output, want) {
+					t.Errorf("stdout lacks %q:\n%s", want, output)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,380 @@
+/*
+ * Copyright 2024 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// openAPI31Fields lists the OpenAPI 3.1 and JSON Schema 2020-12 keywords that kin-openapi keeps as
+// extensions. The validation of an OpenAPI 3.1 document accepts them.
+var openAPI31Fields = []string{
+	"$defs", "$schema", "$id", "$anchor", "$comment", "$dynamicAnchor", "$dynamicRef",
+	"prefixItems", "contains", "minContains", "maxContains", "patternProperties", "propertyNames",
+	"unevaluatedItems", "unevaluatedProperties", "dependentRequired", "dependentSchemas",
+	"if", "then", "else", "contentEncoding", "contentMediaType", "contentSchema",
+	"jsonSchemaDialect", "webhooks", "pathItems", "summary", "identifier",
+}
+
+// schemaKeywords lists the keywords that tell a schema apart from a map of named schemas at the
+// top of a referenced document
+var schemaKeywords = []string{"$ref", "type", "properties", "items", "allOf", "oneOf", "anyOf", "enum", "const"}
+
+// isOpenAPI31 reports whether the document declares an `openapi: 3.1.x` version at its top level
+func isOpenAPI31(data []byte) bool {
+	var header struct {
+		OpenAPI string `json:"openapi"`
+	}
+	if err := yaml.Unmarshal(data, &header); err != nil {
+		return false
+	}
+	return strings.HasPrefix(strings.TrimSpace(header.OpenAPI), "3.1")
+}
+
+// normalizeOpenAPI31 rewrites the JSON Schema 2020-12 constructs of an OpenAPI 3.1 document into
+// their OpenAPI 3.0 equivalent, keeping the declaration order of the document:
+//
+//   - `type: [T, "null"]` and `oneOf`/`anyOf` branches of type null become `nullable: true`
+//   - `const: v` becomes `enum: [v]`
+//   - numeric `exclusiveMinimum`/`exclusiveMaximum` become a bound with a boolean flag
+//   - `examples` becomes `example`, holding the first example
+//   - `prefixItems` of the same schema become `items`
+func normalizeOpenAPI31(data []byte) ([]byte, error) {
+	var document yaml.Node
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("failed to decode OpenAPI 3.1 document: %v", err)
+	}
+	if len(document.Content) == 0 {
+		return data, nil
+	}
+
+	root := resolveAlias(document.Content[0])
+	switch {
+	case mappingValue(root, "openapi") != nil || mappingValue(root, "components") != nil || mappingValue(root, "paths") != nil:
+		if mappingValue(root, "paths") == nil {
+			// Paths are optional in OpenAPI 3.1
+			setMappingValue(root, "paths", &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"})
+		}
+		normalizeDocument31(root)
+	case hasAnyKey(root, schemaKeywords):
+		normalizeSchema31(root)
+	default:
+		// A referenced document holding named schemas
+		for _, name := range mappingKeys(root) {
+			normalizeSchema31(mappingValue(root, name))
+		}
+	}
+
+	normalized, err := yaml.Marshal(&document)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode OpenAPI 3.1 document: %v", err)
+	}
+	return normalized, nil
+}
+
+// normalizeDocument31 normalizes the schemas found in a part of a document that is not a schema
+func normalizeDocument31(node *yaml.Node) {
+	node = resolveAlias(node)
+	if node == nil {
+		return
+	}
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i].Value, node.Content[i+1]
+			switch {
+			case strings.HasPrefix(key, "x-") || key == "example" || key == "examples":
+				// Extensions and examples hold arbitrary values
+			case key == "schema":
+				normalizeSchema31(value)
+			case key == "schemas" || key == "$defs":
+				for _, name := range mappingKeys(value) {
+					normalizeSchema31(mappingValue(value, name))
+				}
+			default:
+				normalizeDocument31(value)
+			}
+		}
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			normalizeDocument31(item)
+		}
+	}
+}
+
+// normalizeSchema31 normalizes a schema and the schemas nested in it
+func normalizeSchema31(node *yaml.Node) {
+	node = resolveAlias(node)
+	if node == nil {
+		return
+	}
+	// The `true` schema accepts anything, as the empty schema does
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!bool" && node.Value == "true" {
+		*node = yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		return
+	}
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+
+	normalizeType31(node)
+	for _, key := range []string{"oneOf", "anyOf"} {
+		normalizeNullBranches31(node, key)
+	}
+
+	for _, key := range []string{"exclusiveMinimum", "exclusiveMaximum"} {
+		bound := mappingValue(node, key)
+		if bound == nil || bound.Kind != yaml.ScalarNode || (bound.Tag != "!!int" && bound.Tag != "!!float") {
+			continue
+		}
+		limit := "minimum"
+		if key == "exclusiveMaximum" {
+			limit = "maximum"
+		}
+		setMappingValue(node, limit, &yaml.Node{Kind: yaml.ScalarNode, Tag: bound.Tag, Value: bound.Value})
+		setMappingValue(node, key, boolNode(true))
+	}
+
+	if value := mappingValue(node, "const"); value != nil {
+		if mappingValue(node, "enum") == nil {
+			setMappingValue(node, "enum", &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Content: []*yaml.Node{value}})
+		}
+		if mappingValue(node, "type") == nil && value.Kind == yaml.ScalarNode {
+			switch value.Tag {
+			case "!!str":
+				setMappingValue(node, "type", stringNode("string"))
+			case "!!int":
+				setMappingValue(node, "type", stringNode("integer"))
+			case "!!float":
+				setMappingValue(node, "type", stringNode("number"))
+			case "!!bool":
+				setMappingValue(node, "type", stringNode("boolean"))
+			case "!!null":
+				setMappingValue(node, "nullable", boolNode(true))
+			}
+		}
+		deleteMappingKey(node, "const")
+	}
+
+	if examples := mappingValue(node, "examples"); examples != nil && examples.Kind == yaml.SequenceNode {
+		if mappingValue(node, "example") == nil && len(examples.Content) > 0 {
+			setMappingValue(node, "example", examples.Content[0])
+		}
+		deleteMappingKey(node, "examples")
+	}
+
+	normalizePrefixItems31(node)
+
+	for _, key := range []string{"properties", "patternProperties", "$defs", "dependentSchemas"} {
+		children := mappingValue(node, key)
+		for _, name := range mappingKeys(children) {
+			normalizeSchema31(mappingValue(children, name))
+		}
+	}
+	for _, key := range []string{"items", "not", "contains", "if", "then", "else", "propertyNames", "unevaluatedItems", "unevaluatedProperties"} {
+		normalizeSchema31(mappingValue(node, key))
+	}
+	// Boolean additionalProperties are understood as they are
+	if additional := mappingValue(node, "additionalProperties"); additional != nil && additional.Kind == yaml.MappingNode {
+		normalizeSchema31(additional)
+	}
+	for _, key := range []string{"allOf", "oneOf", "anyOf", "prefixItems"} {
+		if list := mappingValue(node, key); list != nil && list.Kind == yaml.SequenceNode {
+			for _, item := range list.Content {
+				normalizeSchema31(item)
+			}
+		}
+	}
+}
+
+// normalizeType31 turns the null type of a type list into `nullable: true`
+func normalizeType31(node *yaml.Node) {
+	typeNode := mappingValue(node, "type")
+	if typeNode == nil {
+		return
+	}
+	switch typeNode.Kind {
+	case yaml.ScalarNode:
+		if typeNode.Value == "null" {
+			deleteMappingKey(node, "type")
+			setMappingValue(node, "nullable", boolNode(true))
+		}
+	case yaml.SequenceNode:
+		var types []*yaml.Node
+		for _, item := range typeNode.Content {
+			if resolveAlias(item).Value != "null" {
+				types = append(types, item)
+			}
+		}
+		if len(types) == len(typeNode.Content) {
+			return
+		}
+		setMappingValue(node, "nullable", boolNode(true))
+		switch len(types) {
+		case 0:
+			deleteMappingKey(node, "type")
+		case 1:
+			setMappingValue(node, "type", types[0])
+		default:
+			setMappingValue(node, "type", &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Content: types})
+		}
+	}
+}
+
+// normalizeNullBranches31 turns the null branches of a oneOf or anyOf list into `nullable: true`.
+// A single remaining branch becomes an allOf part, as a reference does not take sibling keywords
+// in OpenAPI 3.0.
+func normalizeNullBranches31(node *yaml.Node, key string) {
+	list := mappingValue(node, key)
+	if list == nil || list.Kind != yaml.SequenceNode {
+		return
+	}
+	var branches []*yaml.Node
+	for _, branch := range list.Content {
+		if !isNullSchema31(branch) {
+			branches = append(branches, branch)
+		}
+	}
+	if len(branches) == len(list.Content) {
+		return
+	}
+
+	setMappingValue(node, "nullable", boolNode(true))
+	switch {
+	case len(branches) == 0:
+		deleteMappingKey(node, key)
+	case len(branches) == 1 && mappingValue(node, "allOf") == nil:
+		deleteMappingKey(node, key)
+		setMappingValue(node, "allOf", &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Content: branches})
+	default:
+		list.Content = branches
+	}
+}
+
+// isNullSchema31 reports whether a schema only accepts null, leaving out its annotations
+func isNullSchema31(node *yaml.Node) bool {
+	node = resolveAlias(node)
+	typeNode := mappingValue(node, "type")
+	if typeNode == nil {
+		return false
+	}
+	isNull := typeNode.Value == "null"
+	if typeNode.Kind == yaml.SequenceNode {
+		isNull = len(typeNode.Content) == 1 && resolveAlias(typeNode.Content[0]).Value == "null"
+	}
+	if !isNull {
+		return false
+	}
+	for _, key := range mappingKeys(node) {
+		switch key {
+		case "type", "title", "description", "$comment":
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// normalizePrefixItems31 turns the prefixItems of an array into its items when they are all the
+// same schema. Tuples of different schemas keep their prefixItems, along with string items if the
+// array declares no items.
+func normalizePrefixItems31(node *yaml.Node) {
+	prefixItems := mappingValue(node, "prefixItems")
+	if prefixItems == nil || prefixItems.Kind != yaml.SequenceNode || len(prefixItems.Content) == 0 {
+		return
+	}
+	items := mappingValue(node, "items")
+	if items != nil && items.Kind != yaml.MappingNode {
+		// `items: false` closes the tuple, the items are left to prefixItems
+		deleteMappingKey(node, "items")
+		items = nil
+	}
+
+	first := prefixItems.Content[0]
+	same := items == nil || nodesEqual(items, first)
+	for _, item := range prefixItems.Content[1:] {
+		same = same && nodesEqual(item, first)
+	}
+	switch {
+	case same:
+		if items == nil {
+			setMappingValue(node, "items", first)
+		}
+		deleteMappingKey(node, "prefixItems")
+	case items == nil:
+		setMappingValue(node, "items", &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Content: []*yaml.Node{stringNode("type"), stringNode("string")}})
+	}
+}
+
+// nodesEqual reports whether two YAML nodes hold the same value
+func nodesEqual(a, b *yaml.Node) bool {
+	a, b = resolveAlias(a), resolveAlias(b)
+	if a == nil || b == nil {
+		return a == b
+	}
+	if a.Kind != b.Kind || a.ShortTag() != b.ShortTag() || a.Value != b.Value || len(a.Content) != len(b.Content) {
+		return false
+	}
+	for i := range a.Content {
+		if !nodesEqual(a.Content[i], b.Content[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// hasAnyKey reports whether a YAML mapping node holds any of the keys
+func hasAnyKey(node *yaml.Node, keys []string) bool {
+	for _, key := range keys {
+		if mappingValue(node, key) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// setMappingValue sets the value of key in a YAML mapping node, appending the key if it is absent
+func setMappingValue(node *yaml.Node, key string, value *yaml.Node) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			node.Content[i+1] = value
+			return
+		}
+	}
+	node.Content = append(node.Content, stringNode(key), value)
+}
+
+// deleteMappingKey removes key and its value from a YAML mapping node
+func deleteMappingKey(node *yaml.Node, key string) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			node.Content = append(node.Content[:i], node.Content[i+2:]...)
+			return
+		}
+	}
+}
+
+func stringNode(value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+}
+
+func boolNode(value bool) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: fmt.Sprint(value)}
+}
@@ -0,0 +1,193 @@
+/*
+ * Copyright 2024 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package parser
+
+import (
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestIsOpenAPI31(t *testing.T) {
+	tests := []struct {
+		data string
+		want bool
+	}{
+		{data: "openapi: 3.1.0", want: true},
+		{data: `{"openapi": "3.1.1"}`, want: true},
+		{data: "openapi: 3.0.3", want: false},
+		{data: "swagger: '2.0'", want: false},
+		{data: "type: object", want: false},
+		{data: "openapi: [", want: false},
+	}
+	for _, tt := range tests {
+		if got := isOpenAPI31([]byte(tt.data)); got != tt.want {
+			t.Errorf("isOpenAPI31(%q) = %v, want %v", tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeOpenAPI31(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{
+			name: "nullable type list",
+			data: "type: [string, 'null']",
+			want: "type: string\nnullable: true",
+		},
+		{
+			name: "several non-null types",
+			data: "type: [string, integer, 'null']",
+			want: "type: [string, integer]\nnullable: true",
+		},
+		{
+			name: "null type",
+			data: "type: 'null'",
+			want: "nullable: true",
+		},
+		{
+			name: "null branch of a reference",
+			data: "oneOf: [{$ref: '#/components/schemas/Pet'}, {type: 'null', description: none}]",
+			want: "nullable: true\nallOf: [{$ref: '#/components/schemas/Pet'}]",
+		},
+		{
+			name: "null branch among several",
+			data: "anyOf: [{type: string}, {type: integer}, {type: ['null']}]",
+			want: "anyOf: [{type: string}, {type: integer}]\nnullable: true",
+		},
+		{
+			name: "const",
+			data: "const: dog",
+			want: "enum: [dog]\ntype: string",
+		},
+		{
+			name: "const with enum and type",
+			data: "type: integer\nenum: [1, 2]\nconst: 1",
+			want: "type: integer\nenum: [1, 2]",
+		},
+		{
+			name: "exclusive bounds",
+			data: "type: number\nexclusiveMinimum: 0\nexclusiveMaximum: 1.5",
+			want: "type: number\nexclusiveMinimum: true\nexclusiveMaximum: true\nminimum: 0\nmaximum: 1.5",
+		},
+		{
+			name: "examples",
+			data: "type: string\nexamples: [a, b]",
+			want: "type: string\nexample: a",
+		},
+		{
+			name: "prefixItems of one schema",
+			data: "type: array\nprefixItems: [{type: string}, {type: string}]",
+			want: "type: array\nitems: {type: string}",
+		},
+		{
+			name: "tuple of different schemas",
+			data: "type: array\nprefixItems: [{type: string}, {type: integer}]\nitems: false",
+			want: "type: array\nprefixItems: [{type: string}, {type: integer}]\nitems: {type: string}",
+		},
+		{
+			name: "nested schemas",
+			data: `type: object
+properties:
+  any: true
+  tags:
+    type: array
+    items: {type: [string, 'null']}
+additionalProperties: false
+$defs:
+  Kind: {const: 1}`,
+			want: `type: object
+properties:
+  any: {}
+  tags:
+    type: array
+    items: {type: string, nullable: true}
+additionalProperties: false
+$defs:
+  Kind: {enum: [1], type: integer}`,
+		},
+		{
+			name: "document",
+			data: `openapi: 3.1.0
+info: {title: test, version: "1", x-meta: {type: 'null'}}
+components:
+  schemas:
+    Pet:
+      type: [object, 'null']
+      example: {type: 'null'}`,
+			want: `openapi: 3.1.0
+info: {title: test, version: "1", x-meta: {type: 'null'}}
+components:
+  schemas:
+    Pet:
+      type: object
+      example: {type: 'null'}
+      nullable: true
+paths: {}`,
+		},
+		{
+			name: "document of named schemas",
+			data: "User: {type: [string, 'null']}\nOrder: {const: true}",
+			want: "User: {type: string, nullable: true}\nOrder: {enum: [true], type: boolean}",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			normalized, err := normalizeOpenAPI31([]byte(tt.data))
+			if err != nil {
+				t.Fatalf("normalizeOpenAPI31() error = %v", err)
+			}
+			var got, want interface{}
+			if err := yaml.Unmarshal(normalized, &got); err != nil {
+				t.Fatalf("failed to decode the normalized document: %v", err)
+			}
+			if err := yaml.Unmarshal([]byte(tt.want), &want); err != nil {
+				t.Fatalf("failed to decode the expected document: %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("normalizeOpenAPI31() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestLoadOpenAPI31Spec(t *testing.T) {
+	spec, err := LoadOpenAPISpecData([]byte(`openapi: 3.1.0
+info: {title: test, version: "1"}
+components:
+  schemas:
+    Pet:
+      type: object
+      properties:
+        name: {type: [string, 'null']}
+        kind: {const: dog}
+`), "openapi.yaml")
+	if err != nil {
+		t.Fatalf("LoadOpenAPISpecData() error = %v", err)
+	}
+	pet := spec.Components.Schemas["Pet"].Value
+	if name := pet.Properties["name"].Value; !name.Type.Is("string") || !name.Nullable {
+		t.Errorf("name = %v nullable=%v, want a nullable string", name.Type, name.Nullable)
+	}
+	if kind := pet.Properties["kind"].Value; !reflect.DeepEqual(kind.Enum, []interface{}{"dog"}) {
+		t.Errorf("kind enum = %v, want [dog]", kind.Enum)
+	}
+}
@@ -0,0 +1,291 @@
+/*
+ * Copyright 2024 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package parser
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"gopkg.in/yaml.v3"
+)
+
+// httpMethods lists the keys of a path item that declare operations
+var httpMethods = map[string]bool{
+	"get": true, "put": true, "post": true, "delete": true,
+	"options": true, "head": true, "patch": true, "trace": true,
+}
+
+// SpecOrder records the declaration order of a spec, which kin-openapi loses by loading into maps
+type SpecOrder struct {
+	Paths      []string                      // Path templates in declaration order
+	Operations map[string][]string           // Upper-case HTTP methods of each path in declaration order
+	Schemas    []string                      // Component schema names in declaration order
+	Properties map[*openapi3.Schema][]string // Property names of each schema in declaration order
+}
+
+// LoadSpecOrder reads the declaration order of the spec file that spec was loaded from
+func LoadSpecOrder(filePath string, spec *openapi3.T) (*SpecOrder, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spec file: %v", err)
+	}
+	return ParseSpecOrder(data, spec)
+}
+
+// ParseSpecOrder walks the raw JSON or YAML document alongside the loaded spec and records
+// the declaration order of paths, operations, component schemas and schema properties
+func ParseSpecOrder(data []byte, spec *openapi3.T) (*SpecOrder, error) {
+	// The spec was loaded from the normalized OpenAPI 3.1 document
+	if isOpenAPI31(data) {
+		normalized, err := normalizeOpenAPI31(data)
+		if err != nil {
+			return nil, err
+		}
+		data = normalized
+	}
+
+	var document yaml.Node
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("failed to decode spec: %v", err)
+	}
+
+	order := &SpecOrder{
+		Operations: map[string][]string{},
+		Properties: map[*openapi3.Schema][]string{},
+	}
+	if len(document.Content) == 0 {
+		return order, nil
+	}
+	root := document.Content[0]
+
+	// Component schemas, named definitions in Swagger 2.0 documents
+	schemasNode := mappingValue(mappingValue(root, "components"), "schemas")
+	if schemasNode == nil {
+		schemasNode = mappingValue(root, "definitions")
+	}
+	for _, name := range mappingKeys(schemasNode) {
+		order.Schemas = append(order.Schemas, name)
+		if spec.Components != nil {
+			order.walkSchema(mappingValue(schemasNode, name), spec.Components.Schemas[name])
+		}
+	}
+
+	pathsNode := mappingValue(root, "paths")
+	for _, path := range mappingKeys(pathsNode) {
+		order.Paths = append(order.Paths, path)
+		pathNode := mappingValue(pathsNode, path)
+
+		var pathItem *openapi3.PathItem
+		if spec.Paths != nil {
+			pathItem = spec.Paths.Value(path)
+		}
+		for _, method := range mappingKeys(pathNode) {
+			if !httpMethods[strings.ToLower(method)] {
+				continue
+			}
+			method = strings.ToUpper(method)
+			order.Operations[path] = append(order.Operations[path], method)
+			if pathItem != nil {
+				order.walkOperation(mappingValue(pathNode, strings.ToLower(method)), pathItem.GetOperation(method))
+			}
+		}
+	}
+
+	return order, nil
+}
+
+// PathOrder returns the declared path order, or nil if it is unknown
+func (o *SpecOrder) PathOrder() []string {
+	if o == nil {
+		return nil
+	}
+	return o.Paths
+}
+
+// OperationOrder returns the declared method order of a path, or nil if it is unknown
+func (o *SpecOrder) OperationOrder(path string) []string {
+	if o == nil {
+		return nil
+	}
+	return o.Operations[path]
+}
+
+// SchemaOrder returns the declared component schema order, or nil if it is unknown
+func (o *SpecOrder) SchemaOrder() []string {
+	if o == nil {
+		return nil
+	}
+	return o.Schemas
+}
+
+// PropertyOrder returns the declared property order of a schema, or nil if it is unknown
+func (o *SpecOrder) PropertyOrder(schema *openapi3.Schema) []string {
+	if o == nil || schema == nil {
+		return nil
+	}
+	return o.Properties[schema]
+}
+
+// MergePropertyOrder records the property order of a schema merged from parts, listing the
+// declared properties of each part in turn
+func (o *SpecOrder) MergePropertyOrder(schema *openapi3.Schema, parts ...*openapi3.Schema) {
+	if o == nil || schema == nil {
+		return
+	}
+	var names []string
+	seen := make(map[string]bool)
+	for _, part := range parts {
+		for _, name := range o.PropertyOrder(part) {
+			if !seen[name] {
+				names = append(names, name)
+				seen[name] = true
+			}
+		}
+	}
+	if len(names) > 0 {
+		o.Properties[schema] = names
+	}
+}
+
+// walkOperation records the property order of the schemas declared inline in an operation
+func (o *SpecOrder) walkOperation(node *yaml.Node, operation *openapi3.Operation) {
+	if node == nil || operation == nil {
+		return
+	}
+
+	if paramsNode := mappingValue(node, "parameters"); paramsNode != nil && paramsNode.Kind == yaml.SequenceNode {
+		for i, paramNode := range paramsNode.Content {
+			if i >= len(operation.Parameters) {
+				break
+			}
+			param := operation.Parameters[i]
+			if param == nil || param.Ref != "" || param.Value == nil {
+				continue
+			}
+			o.walkSchema(mappingValue(paramNode, "schema"), param.Value.Schema)
+		}
+	}
+
+	if body := operation.RequestBody; body != nil && body.Ref == "" && body.Value != nil {
+		o.walkContent(mappingValue(mappingValue(node, "requestBody"), "content"), body.Value.Content)
+	}
+
+	if operation.Responses != nil {
+		responsesNode := mappingValue(node, "responses")
+		for _, status := range mappingKeys(responsesNode) {
+			response := operation.Responses.Value(status)
+			if response == nil || response.Ref != "" || response.Value == nil {
+				continue
+			}
+			responseNode := mappingValue(responsesNode, status)
+			o.walkContent(mappingValue(responseNode, "content"), response.Value.Content)
+
+			headersNode := mappingValue(responseNode, "headers")
+			for _, name := range mappingKeys(headersNode) {
+				header := response.Value.Headers[name]
+				if header == nil || header.Ref != "" || header.Value == nil {
+					continue
+				}
+				o.walkSchema(mappingValue(mappingValue(headersNode, name), "schema"), header.Value.Schema)
+			}
+		}
+	}
+}
+
+// walkContent records the property order of the schemas of each media type
+func (o *SpecOrder) walkContent(node *yaml.Node, content openapi3.Content) {
+	for _, mediaTypeStr := range mappingKeys(node) {
+		mediaType := content[mediaTypeStr]
+		if mediaType == nil {
+			continue
+		}
+		o.walkSchema(mappingValue(mappingValue(node, mediaTypeStr), "schema"), mediaType.Schema)
+	}
+}
+
+// walkSchema records the property order of a schema and of the schemas nested in it
+func (o *SpecOrder) walkSchema(node *yaml.Node, schemaRef *openapi3.SchemaRef) {
+	node = resolveAlias(node)
+	if node == nil || node.Kind != yaml.MappingNode || schemaRef == nil || schemaRef.Value == nil {
+		return
+	}
+	// Referenced schemas are recorded where they are declared
+	if mappingValue(node, "$ref") != nil {
+		return
+	}
+	schema := schemaRef.Value
+
+	propertiesNode := mappingValue(node, "properties")
+	if names := mappingKeys(propertiesNode); len(names) > 0 {
+		o.Properties[schema] = names
+		for _, name := range names {
+			o.walkSchema(mappingValue(propertiesNode, name), schema.Properties[name])
+		}
+	}
+
+	o.walkSchema(mappingValue(node, "items"), schema.Items)
+	o.walkSchema(mappingValue(node, "not"), schema.Not)
+	o.walkSchema(mappingValue(node, "additionalProperties"), schema.AdditionalProperties.Schema)
+	for key, schemas := range map[string]openapi3.SchemaRefs{"allOf": schema.AllOf, "oneOf": schema.OneOf, "anyOf": schema.AnyOf} {
+		listNode := resolveAlias(mappingValue(node, key))
+		if listNode == nil || listNode.Kind != yaml.SequenceNode {
+			continue
+		}
+		for i, itemNode := range listNode.Content {
+			if i < len(schemas) {
+				o.walkSchema(itemNode, schemas[i])
+			}
+		}
+	}
+}
+
+// resolveAlias follows YAML aliases to the node they point to
+func resolveAlias(node *yaml.Node) *yaml.Node {
+	for node != nil && node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	return node
+}
+
+// mappingValue returns the value of key in a YAML mapping node, or nil if it is absent
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	node = resolveAlias(node)
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return resolveAlias(node.Content[i+1])
+		}
+	}
+	return nil
+}
+
+// mappingKeys returns the keys of a YAML mapping node in declaration order
+func mappingKeys(node *yaml.Node) []string {
+	node = resolveAlias(node)
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	keys := make([]string, 0, len(node.Content)/2)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		keys = append(keys, node.Content[i].Value)
+	}
+	return keys
+}
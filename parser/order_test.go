@@ -0,0 +1,259 @@
+/*
+ * Copyright 2024 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package parser
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+func TestParseSpecOrder(t *testing.T) {
+	type propertyOrder struct {
+		schema func(spec *openapi3.T) *openapi3.Schema
+		want   []string
+	}
+	componentSchema := func(name string) func(spec *openapi3.T) *openapi3.Schema {
+		return func(spec *openapi3.T) *openapi3.Schema {
+			return spec.Components.Schemas[name].Value
+		}
+	}
+
+	tests := []struct {
+		name       string
+		data       string
+		paths      []string
+		operations map[string][]string
+		schemas    []string
+		properties []propertyOrder
+	}{
+		{
+			name: "yaml declaration order",
+			data: `openapi: 3.0.3
+info: {title: test, version: "1"}
+paths:
+  /zoo:
+    post:
+      responses: {"200": {description: ok}}
+    get:
+      responses: {"200": {description: ok}}
+  /bar:
+    delete:
+      responses: {"200": {description: ok}}
+    parameters: []
+components:
+  schemas:
+    Zebra:
+      type: object
+      properties:
+        stripes: {type: integer}
+        name: {type: string}
+    Ant:
+      type: object
+      properties:
+        legs: {type: integer}
+        colony:
+          type: object
+          properties:
+            size: {type: integer}
+            queen: {type: string}
+`,
+			paths:      []string{"/zoo", "/bar"},
+			operations: map[string][]string{"/zoo": {"POST", "GET"}, "/bar": {"DELETE"}},
+			schemas:    []string{"Zebra", "Ant"},
+			properties: []propertyOrder{
+				{schema: componentSchema("Zebra"), want: []string{"stripes", "name"}},
+				{schema: componentSchema("Ant"), want: []string{"legs", "colony"}},
+				{
+					schema: func(spec *openapi3.T) *openapi3.Schema {
+						return spec.Components.Schemas["Ant"].Value.Properties["colony"].Value
+					},
+					want: []string{"size", "queen"},
+				},
+			},
+		},
+		{
+			name: "json declaration order",
+			data: `{
+  "openapi": "3.0.3",
+  "info": {"title": "test", "version": "1"},
+  "paths": {},
+  "components": {"schemas": {
+    "B": {"type": "object", "properties": {"y": {"type": "string"}, "x": {"type": "string"}}},
+    "A": {"type": "string"}
+  }}
+}`,
+			operations: map[string][]string{},
+			schemas:    []string{"B", "A"},
+			properties: []propertyOrder{
+				{schema: componentSchema("B"), want: []string{"y", "x"}},
+				{schema: componentSchema("A"), want: nil},
+			},
+		},
+		{
+			name: "inline operation schemas",
+			data: `openapi: 3.0.3
+info: {title: test, version: "1"}
+paths:
+  /pets:
+    post:
+      parameters:
+        - name: filter
+          in: query
+          schema:
+            type: object
+            properties: {z: {type: string}, a: {type: string}}
+      requestBody:
+        content:
+          application/json:
+            schema:
+              type: object
+              properties: {name: {type: string}, age: {type: integer}}
+      responses:
+        "200":
+          description: ok
+          headers:
+            X-Page:
+              schema:
+                type: object
+                properties: {total: {type: integer}, next: {type: string}}
+          content:
+            application/json:
+              schema:
+                type: array
+                items:
+                  type: object
+                  properties: {id: {type: string}, created: {type: string}}
+`,
+			paths:      []string{"/pets"},
+			operations: map[string][]string{"/pets": {"POST"}},
+			properties: []propertyOrder{
+				{
+					schema: func(spec *openapi3.T) *openapi3.Schema {
+						return spec.Paths.Value("/pets").Post.Parameters[0].Value.Schema.Value
+					},
+					want: []string{"z", "a"},
+				},
+				{
+					schema: func(spec *openapi3.T) *openapi3.Schema {
+						return spec.Paths.Value("/pets").Post.RequestBody.Value.Content["application/json"].Schema.Value
+					},
+					want: []string{"name", "age"},
+				},
+				{
+					schema: func(spec *openapi3.T) *openapi3.Schema {
+						return spec.Paths.Value("/pets").Post.Responses.Value("200").Value.Headers["X-Page"].Value.Schema.Value
+					},
+					want: []string{"total", "next"},
+				},
+				{
+					schema: func(spec *openapi3.T) *openapi3.Schema {
+						return spec.Paths.Value("/pets").Post.Responses.Value("200").Value.Content["application/json"].Schema.Value.Items.Value
+					},
+					want: []string{"id", "created"},
+				},
+			},
+		},
+		{
+			name: "composition, aliases and references",
+			data: `openapi: 3.0.3
+info: {title: test, version: "1"}
+paths: {}
+components:
+  schemas:
+    Base: &base
+      type: object
+      properties: {second: {type: string}, first: {type: string}}
+    Copy: *base
+    Pet:
+      allOf:
+        - $ref: '#/components/schemas/Base'
+        - type: object
+          properties: {tail: {type: string}, head: {type: string}}
+    Bag:
+      type: object
+      additionalProperties:
+        type: object
+        properties: {k: {type: string}, j: {type: string}}
+`,
+			operations: map[string][]string{},
+			schemas:    []string{"Base", "Copy", "Pet", "Bag"},
+			properties: []propertyOrder{
+				{schema: componentSchema("Base"), want: []string{"second", "first"}},
+				{schema: componentSchema("Copy"), want: []string{"second", "first"}},
+				{
+					schema: func(spec *openapi3.T) *openapi3.Schema {
+						return spec.Components.Schemas["Pet"].Value.AllOf[1].Value
+					},
+					want: []string{"tail", "head"},
+				},
+				{
+					schema: func(spec *openapi3.T) *openapi3.Schema {
+						return spec.Components.Schemas["Bag"].Value.AdditionalProperties.Schema.Value
+					},
+					want: []string{"k", "j"},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec, err := LoadOpenAPISpecData([]byte(tt.data), "openapi.yaml")
+			if err != nil {
+				t.Fatalf("LoadOpenAPISpecData() error = %v", err)
+			}
+			order, err := ParseSpecOrder([]byte(tt.data), spec)
+			if err != nil {
+				t.Fatalf("ParseSpecOrder() error = %v", err)
+			}
+			if !reflect.DeepEqual(order.PathOrder(), tt.paths) {
+				t.Errorf("PathOrder() = %v, want %v", order.PathOrder(), tt.paths)
+			}
+			if !reflect.DeepEqual(order.Operations, tt.operations) {
+				t.Errorf("Operations = %v, want %v", order.Operations, tt.operations)
+			}
+			if !reflect.DeepEqual(order.SchemaOrder(), tt.schemas) {
+				t.Errorf("SchemaOrder() = %v, want %v", order.SchemaOrder(), tt.schemas)
+			}
+			for i, property := range tt.properties {
+				if got := order.PropertyOrder(property.schema(spec)); !reflect.DeepEqual(got, property.want) {
+					t.Errorf("PropertyOrder() of schema %d = %v, want %v", i, got, property.want)
+				}
+			}
+		})
+	}
+}
+
+func TestMergePropertyOrder(t *testing.T) {
+	first, second, merged := &openapi3.Schema{}, &openapi3.Schema{}, &openapi3.Schema{}
+	order := &SpecOrder{Properties: map[*openapi3.Schema][]string{
+		first:  {"b", "a"},
+		second: {"a", "c"},
+	}}
+	order.MergePropertyOrder(merged, first, second)
+	if got, want := order.PropertyOrder(merged), []string{"b", "a", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("PropertyOrder() of the merged schema = %v, want %v", got, want)
+	}
+
+	// A nil order knows no declaration order
+	var unknown *SpecOrder
+	unknown.MergePropertyOrder(merged, first)
+	if unknown.PathOrder() != nil || unknown.OperationOrder("/") != nil || unknown.SchemaOrder() != nil || unknown.PropertyOrder(merged) != nil {
+		t.Errorf("a nil SpecOrder returned a declaration order")
+	}
+}
@@ -17,28 +17,177 @@
 package parser
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
+	"net/url"
 	"os"
+	"path/filepath"
+	"strings"
 
+	"github.com/getkin/kin-openapi/openapi2"
+	"github.com/getkin/kin-openapi/openapi2conv"
 	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/invopop/yaml"
 )
 
-// LoadOpenAPISpec parses an OpenAPI spec from a file and returns it.
+const swaggerVersion2 = "2.0"
+
+// StdinPath is the path that stands for the standard input
+const StdinPath = "-"
+
+// LoadOpenAPISpec parses an OpenAPI 3 or Swagger 2.0 spec from a file and returns it.
+// Swagger 2.0 and OpenAPI 3.1 documents are converted into their OpenAPI 3.0 equivalent.
 func LoadOpenAPISpec(filePath string) (*openapi3.T, error) {
-	loader := openapi3.NewLoader()
+	data, err := ReadSpecFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return LoadOpenAPISpecData(data, filePath)
+}
+
+// ReadSpecFile reads a spec document from a file, or from the standard input if filePath is StdinPath
+func ReadSpecFile(filePath string) ([]byte, error) {
+	if filePath == StdinPath {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read spec from stdin: %v", err)
+		}
+		return data, nil
+	}
 
 	if _, err := os.Stat(filePath); os.IsNotExist(err) {
 		return nil, fmt.Errorf("file %s does not exist", filePath)
 	}
 
-	spec, err := loader.LoadFromFile(filePath)
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spec file: %v", err)
+	}
+	return data, nil
+}
+
+// LoadOpenAPISpecData parses an OpenAPI 3 or Swagger 2.0 spec from JSON or YAML data. References to
+// other files are resolved relative to filePath, or to the working directory for StdinPath.
+func LoadOpenAPISpecData(data []byte, filePath string) (*openapi3.T, error) {
+	loader := openapi3.NewLoader()
+	// Local multi-document specs reference schemas of other files, remote documents are never fetched
+	loader.IsExternalRefsAllowed = true
+	loader.ReadFromURIFunc = openapi3.URIMapCache(readLocalFile)
+
+	if isJSON(data) {
+		if !json.Valid(data) {
+			return nil, fmt.Errorf("failed to load OpenAPI spec: invalid JSON document")
+		}
+	} else {
+		var document interface{}
+		if err := yaml.Unmarshal(data, &document); err != nil {
+			return nil, fmt.Errorf("failed to load OpenAPI spec: invalid YAML document: %v", err)
+		}
+	}
+
+	location, err := specLocation(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var validationOpts []openapi3.ValidationOption
+	if isOpenAPI31(data) {
+		if data, err = normalizeOpenAPI31(data); err != nil {
+			return nil, err
+		}
+		// The documents referenced by an OpenAPI 3.1 spec use JSON Schema 2020-12 as well
+		readFromURI := loader.ReadFromURIFunc
+		loader.ReadFromURIFunc = func(loader *openapi3.Loader, location *url.URL) ([]byte, error) {
+			data, err := readFromURI(loader, location)
+			if err != nil {
+				return nil, err
+			}
+			return normalizeOpenAPI31(data)
+		}
+		validationOpts = append(validationOpts, openapi3.AllowExtraSiblingFields(openAPI31Fields...))
+	}
+
+	var spec *openapi3.T
+	if isSwagger2(data) {
+		spec, err = loadSwaggerSpec(loader, data, location)
+	} else {
+		spec, err = loader.LoadFromDataWithPath(data, location)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to load OpenAPI spec: %v", err)
 	}
 
-	if err := spec.Validate(loader.Context); err != nil {
+	if err := spec.Validate(loader.Context, validationOpts...); err != nil {
 		return nil, fmt.Errorf("failed to validate OpenAPI spec: %v", err)
 	}
 
 	return spec, nil
 }
+
+// readLocalFile reads the document of an external reference from a local file, references to
+// remote documents are rejected
+func readLocalFile(loader *openapi3.Loader, location *url.URL) ([]byte, error) {
+	data, err := openapi3.ReadFromFile(loader, location)
+	if err == openapi3.ErrURINotSupported {
+		return nil, fmt.Errorf("reference to %s is not allowed, only local files are read", location.Redacted())
+	}
+	return data, err
+}
+
+// specLocation returns the URL references of the spec at filePath are resolved against
+func specLocation(filePath string) (*url.URL, error) {
+	if filePath == StdinPath {
+		filePath = "stdin"
+	}
+	absPath, err := filepath.Abs(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return &url.URL{Path: filepath.ToSlash(absPath)}, nil
+}
+
+// isJSON reports whether the document is JSON rather than YAML, JSON documents start with a brace
+func isJSON(data []byte) bool {
+	trimmed := bytes.TrimLeft(data, " \t\r\n")
+	return len(trimmed) > 0 && trimmed[0] == '{'
+}
+
+// IsSpecDocument reports whether the document declares an `openapi` or `swagger` version at its top
+// level, as opposed to a document only holding schemas referenced by a spec
+func IsSpecDocument(data []byte) bool {
+	var header struct {
+		OpenAPI string `json:"openapi"`
+		Swagger string `json:"swagger"`
+	}
+	if err := yaml.Unmarshal(data, &header); err != nil {
+		return false
+	}
+	return header.OpenAPI != "" || header.Swagger != ""
+}
+
+// isSwagger2 reports whether the document declares `swagger: "2.0"` at its top level
+func isSwagger2(data []byte) bool {
+	var header struct {
+		Swagger string `json:"swagger"`
+	}
+	if err := yaml.Unmarshal(data, &header); err != nil {
+		return false
+	}
+	return strings.TrimSpace(header.Swagger) == swaggerVersion2
+}
+
+// loadSwaggerSpec decodes a Swagger 2.0 document and converts it into an OpenAPI 3 document
+func loadSwaggerSpec(loader *openapi3.Loader, data []byte, location *url.URL) (*openapi3.T, error) {
+	var doc2 openapi2.T
+	if err := yaml.Unmarshal(data, &doc2); err != nil {
+		return nil, fmt.Errorf("failed to decode Swagger 2.0 spec: %v", err)
+	}
+
+	spec, err := openapi2conv.ToV3WithLoader(&doc2, loader, location)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert Swagger 2.0 spec to OpenAPI 3: %v", err)
+	}
+	return spec, nil
+}
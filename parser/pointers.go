@@ -0,0 +1,230 @@
+/*
+ * Copyright 2024 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package parser
+
+import (
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/hertz-contrib/swagger-generate/swagger2idl/utils"
+)
+
+// rootPointer is the JSON pointer of the whole spec
+const rootPointer = "#"
+
+// SpecPointers records the JSON pointer of the nodes of a loaded spec, so that the converters can
+// report where a construct they cannot represent is declared. A node reached through a reference
+// is located at the target of the reference.
+type SpecPointers struct {
+	pointers map[interface{}]string
+}
+
+// ResolveSpecPointers walks the spec and records the JSON pointer of its schemas, operations,
+// parameters, request bodies, responses and headers
+func ResolveSpecPointers(spec *openapi3.T) *SpecPointers {
+	w := &pointerWalker{pointers: &SpecPointers{pointers: map[interface{}]string{}}}
+	if spec == nil {
+		return w.pointers
+	}
+
+	// Components are walked first so that shared nodes are located at their declaration
+	if spec.Components != nil {
+		for _, name := range utils.SortedKeys(spec.Components.Schemas) {
+			w.schema(spec.Components.Schemas[name], JoinPointer("#/components/schemas", name))
+		}
+		for _, name := range utils.SortedKeys(spec.Components.Parameters) {
+			w.parameter(spec.Components.Parameters[name], JoinPointer("#/components/parameters", name))
+		}
+		for _, name := range utils.SortedKeys(spec.Components.RequestBodies) {
+			w.requestBody(spec.Components.RequestBodies[name], JoinPointer("#/components/requestBodies", name))
+		}
+		for _, name := range utils.SortedKeys(spec.Components.Responses) {
+			w.response(spec.Components.Responses[name], JoinPointer("#/components/responses", name))
+		}
+		for _, name := range utils.SortedKeys(spec.Components.Headers) {
+			w.header(spec.Components.Headers[name], JoinPointer("#/components/headers", name))
+		}
+	}
+
+	if spec.Paths != nil {
+		for _, p := range utils.SortedKeys(spec.Paths.Map()) {
+			pathItem := spec.Paths.Value(p)
+			pointer := JoinPointer("#/paths", p)
+			for i, param := range pathItem.Parameters {
+				w.parameter(param, JoinPointer(pointer, "parameters", strconv.Itoa(i)))
+			}
+			for _, method := range utils.SortedKeys(pathItem.Operations()) {
+				w.operation(pathItem.GetOperation(method), JoinPointer(pointer, strings.ToLower(method)))
+			}
+		}
+	}
+
+	return w.pointers
+}
+
+// Lookup returns the JSON pointer of a node of the spec, or "#" if the node is unknown
+func (p *SpecPointers) Lookup(node interface{}) string {
+	if p != nil && node != nil {
+		if pointer, ok := p.pointers[node]; ok {
+			return pointer
+		}
+	}
+	return rootPointer
+}
+
+// JoinPointer appends the escaped reference tokens to a JSON pointer
+func JoinPointer(pointer string, tokens ...string) string {
+	var sb strings.Builder
+	sb.WriteString(pointer)
+	for _, token := range tokens {
+		sb.WriteByte('/')
+		sb.WriteString(strings.NewReplacer("~", "~0", "/", "~1").Replace(token))
+	}
+	return sb.String()
+}
+
+// pointerWalker records the pointer of each node the first time it is reached
+type pointerWalker struct {
+	pointers *SpecPointers
+}
+
+// visit records the pointer of a node and reports whether the node was reached for the first time
+func (w *pointerWalker) visit(node interface{}, pointer string) bool {
+	if _, ok := w.pointers.pointers[node]; ok {
+		return false
+	}
+	w.pointers.pointers[node] = pointer
+	return true
+}
+
+// target returns the pointer of a node reached through ref from the node at pointer. References
+// are relative to the document they appear in, which is kept as the file part of the pointer.
+func (w *pointerWalker) target(ref, pointer string) string {
+	if ref == "" {
+		return pointer
+	}
+	doc, _, _ := strings.Cut(pointer, "#")
+	file, fragment, _ := strings.Cut(ref, "#")
+	if file == "" {
+		file = doc
+	} else if !strings.Contains(file, "://") {
+		file = path.Clean(path.Join(path.Dir(doc), file))
+	}
+	return file + "#" + fragment
+}
+
+func (w *pointerWalker) operation(operation *openapi3.Operation, pointer string) {
+	if operation == nil || !w.visit(operation, pointer) {
+		return
+	}
+	for i, param := range operation.Parameters {
+		w.parameter(param, JoinPointer(pointer, "parameters", strconv.Itoa(i)))
+	}
+	w.requestBody(operation.RequestBody, JoinPointer(pointer, "requestBody"))
+	if operation.Responses != nil {
+		for _, status := range utils.SortedKeys(operation.Responses.Map()) {
+			w.response(operation.Responses.Value(status), JoinPointer(pointer, "responses", status))
+		}
+	}
+}
+
+func (w *pointerWalker) parameter(param *openapi3.ParameterRef, pointer string) {
+	if param == nil || param.Value == nil {
+		return
+	}
+	pointer = w.target(param.Ref, pointer)
+	if !w.visit(param.Value, pointer) {
+		return
+	}
+	w.schema(param.Value.Schema, JoinPointer(pointer, "schema"))
+	w.content(param.Value.Content, JoinPointer(pointer, "content"))
+}
+
+func (w *pointerWalker) requestBody(body *openapi3.RequestBodyRef, pointer string) {
+	if body == nil || body.Value == nil {
+		return
+	}
+	pointer = w.target(body.Ref, pointer)
+	if !w.visit(body.Value, pointer) {
+		return
+	}
+	w.content(body.Value.Content, JoinPointer(pointer, "content"))
+}
+
+func (w *pointerWalker) response(response *openapi3.ResponseRef, pointer string) {
+	if response == nil || response.Value == nil {
+		return
+	}
+	pointer = w.target(response.Ref, pointer)
+	if !w.visit(response.Value, pointer) {
+		return
+	}
+	w.content(response.Value.Content, JoinPointer(pointer, "content"))
+	for _, name := range utils.SortedKeys(response.Value.Headers) {
+		w.header(response.Value.Headers[name], JoinPointer(pointer, "headers", name))
+	}
+}
+
+func (w *pointerWalker) header(header *openapi3.HeaderRef, pointer string) {
+	if header == nil || header.Value == nil {
+		return
+	}
+	pointer = w.target(header.Ref, pointer)
+	if !w.visit(header.Value, pointer) {
+		return
+	}
+	w.schema(header.Value.Schema, JoinPointer(pointer, "schema"))
+	w.content(header.Value.Content, JoinPointer(pointer, "content"))
+}
+
+func (w *pointerWalker) content(content openapi3.Content, pointer string) {
+	for _, mediaType := range utils.SortedKeys(content) {
+		if content[mediaType] != nil {
+			w.schema(content[mediaType].Schema, JoinPointer(pointer, mediaType, "schema"))
+		}
+	}
+}
+
+// schema records the pointer of a schema and of the schemas nested in it
+func (w *pointerWalker) schema(schemaRef *openapi3.SchemaRef, pointer string) {
+	if schemaRef == nil || schemaRef.Value == nil {
+		return
+	}
+	pointer = w.target(schemaRef.Ref, pointer)
+	schema := schemaRef.Value
+	if !w.visit(schema, pointer) {
+		return
+	}
+
+	for _, name := range utils.SortedKeys(schema.Properties) {
+		w.schema(schema.Properties[name], JoinPointer(pointer, "properties", name))
+	}
+	w.schema(schema.Items, JoinPointer(pointer, "items"))
+	w.schema(schema.Not, JoinPointer(pointer, "not"))
+	w.schema(schema.AdditionalProperties.Schema, JoinPointer(pointer, "additionalProperties"))
+	for i, nested := range schema.AllOf {
+		w.schema(nested, JoinPointer(pointer, "allOf", strconv.Itoa(i)))
+	}
+	for i, nested := range schema.OneOf {
+		w.schema(nested, JoinPointer(pointer, "oneOf", strconv.Itoa(i)))
+	}
+	for i, nested := range schema.AnyOf {
+		w.schema(nested, JoinPointer(pointer, "anyOf", strconv.Itoa(i)))
+	}
+}
@@ -0,0 +1,155 @@
+/*
+ * Copyright 2024 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/hertz-contrib/swagger-generate/swagger2idl/protobuf"
+)
+
+var (
+	protoStringLiteral = regexp.MustCompile(`"(?:[^"\\]|\\.)*"`)
+	protoScopeDecl     = regexp.MustCompile(`^(message|enum|oneof|service)\s+(\w+)\s*\{`)
+	protoFieldDecl     = regexp.MustCompile(`^(?:repeated\s+|optional\s+)?(?:map\s*<[^>]*>|[\w.]+)\s+(\w+)\s*=\s*(\d+)`)
+	protoReservedDecl  = regexp.MustCompile(`^reserved\s+(.+);`)
+	protoOptionDecl    = regexp.MustCompile(`^option\b`)
+)
+
+// LoadProtoFieldLock reads the field numbers of a previously generated Proto file or of a
+// JSON lock file. A missing file yields an empty lock.
+func LoadProtoFieldLock(filePath string) (*protobuf.FieldLock, error) {
+	data, err := os.ReadFile(filePath)
+	if os.IsNotExist(err) {
+		return protobuf.NewFieldLock(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lock file: %v", err)
+	}
+
+	if filepath.Ext(filePath) == ".proto" {
+		return parseProtoFieldLock(string(data)), nil
+	}
+
+	lock := protobuf.NewFieldLock()
+	if err := json.Unmarshal(data, lock); err != nil {
+		return nil, fmt.Errorf("failed to decode lock file %s: %v", filePath, err)
+	}
+	return lock, nil
+}
+
+// parseProtoFieldLock scans Proto source for message fields and reserved statements
+func parseProtoFieldLock(source string) *protobuf.FieldLock {
+	lock := protobuf.NewFieldLock()
+
+	type scope struct {
+		kind string
+		name string
+	}
+	var scopes []scope
+
+	// messagePath returns the qualified name of the innermost message, or "" outside messages
+	messagePath := func() string {
+		var names []string
+		for _, s := range scopes {
+			if s.kind == "message" {
+				names = append(names, s.name)
+			} else if s.kind != "oneof" {
+				return ""
+			}
+		}
+		return strings.Join(names, ".")
+	}
+
+	for _, line := range strings.Split(source, "\n") {
+		if idx := strings.Index(line, "//"); idx >= 0 {
+			line = line[:idx]
+		}
+		line = strings.TrimSpace(protoStringLiteral.ReplaceAllStringFunc(line, func(s string) string {
+			// keep the literal but drop braces so they do not affect scope tracking
+			return strings.NewReplacer("{", "", "}", "").Replace(s)
+		}))
+		if line == "" {
+			continue
+		}
+
+		if m := protoScopeDecl.FindStringSubmatch(line); m != nil {
+			scopes = append(scopes, scope{kind: m[1], name: m[2]})
+			line = line[len(m[0]):]
+		} else if path := messagePath(); path != "" && len(scopes) > 0 {
+			if m := protoReservedDecl.FindStringSubmatch(line); m != nil {
+				ml := lock.Message(path)
+				for _, item := range strings.Split(m[1], ",") {
+					item = strings.TrimSpace(item)
+					if n, err := strconv.Atoi(item); err == nil {
+						ml.Reserved = append(ml.Reserved, n)
+					} else if r, ok := parseProtoReservedRange(item); ok {
+						ml.ReservedRanges = append(ml.ReservedRanges, r)
+					} else if unquoted, err := strconv.Unquote(item); err == nil {
+						ml.ReservedNames = append(ml.ReservedNames, unquoted)
+					}
+				}
+			} else if m := protoFieldDecl.FindStringSubmatch(line); m != nil && !protoOptionDecl.MatchString(line) {
+				if n, err := strconv.Atoi(m[2]); err == nil {
+					lock.Message(path).Fields[m[1]] = n
+				}
+			}
+		}
+
+		// Track remaining braces, e.g. option blocks and closing scopes
+		for _, ch := range line {
+			switch ch {
+			case '{':
+				scopes = append(scopes, scope{kind: "block"})
+			case '}':
+				if len(scopes) > 0 {
+					scopes = scopes[:len(scopes)-1]
+				}
+			}
+		}
+	}
+
+	return lock
+}
+
+// parseProtoReservedRange parses a reserved range such as `9 to 11` or `100 to max`
+func parseProtoReservedRange(item string) (protobuf.ReservedRange, bool) {
+	fields := strings.Fields(item)
+	if len(fields) != 3 || fields[1] != "to" {
+		return protobuf.ReservedRange{}, false
+	}
+	from, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return protobuf.ReservedRange{}, false
+	}
+	to := protobuf.MaxFieldNumber
+	if fields[2] != "max" {
+		if to, err = strconv.Atoi(fields[2]); err != nil {
+			return protobuf.ReservedRange{}, false
+		}
+	}
+	if to < from {
+		return protobuf.ReservedRange{}, false
+	}
+	return protobuf.ReservedRange{From: from, To: to}, true
+}
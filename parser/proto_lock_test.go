@@ -0,0 +1,174 @@
+/*
+ * Copyright 2024 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package parser
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/hertz-contrib/swagger-generate/swagger2idl/protobuf"
+)
+
+func TestParseProtoFieldLock(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   map[string]*protobuf.MessageLock
+	}{
+		{
+			name: "fields",
+			source: `message Pet {
+  string Name = 1;
+  optional int64 Age = 2;
+  repeated string Tags = 3;
+  map<string, int32> Scores = 4;
+}`,
+			want: map[string]*protobuf.MessageLock{
+				"Pet": {Fields: map[string]int{"Name": 1, "Age": 2, "Tags": 3, "Scores": 4}},
+			},
+		},
+		{
+			name: "nested messages and oneofs",
+			source: `message Pet {
+  message Owner {
+    string Name = 1;
+  }
+  oneof kind {
+    string Dog = 2;
+    string Cat = 3;
+  }
+  Owner Owner = 1;
+}`,
+			want: map[string]*protobuf.MessageLock{
+				"Pet":       {Fields: map[string]int{"Owner": 1, "Dog": 2, "Cat": 3}},
+				"Pet.Owner": {Fields: map[string]int{"Name": 1}},
+			},
+		},
+		{
+			name: "reserved numbers, ranges and names",
+			source: `message Pet {
+  reserved 2, 9 to 11, 100 to max;
+  reserved "Old", "Older";
+  string Name = 1;
+}`,
+			want: map[string]*protobuf.MessageLock{
+				"Pet": {
+					Fields:   map[string]int{"Name": 1},
+					Reserved: []int{2},
+					ReservedRanges: []protobuf.ReservedRange{
+						{From: 9, To: 11},
+						{From: 100, To: protobuf.MaxFieldNumber},
+					},
+					ReservedNames: []string{"Old", "Older"},
+				},
+			},
+		},
+		{
+			name: "options, comments and string literals",
+			source: `message Pet {
+  option (openapi.schema) = {
+    title: "pet { }"
+  };
+  // string Hidden = 7;
+  string Name = 1 [
+    (api.query) = "name"
+  ];
+}`,
+			want: map[string]*protobuf.MessageLock{
+				"Pet": {Fields: map[string]int{"Name": 1}},
+			},
+		},
+		{
+			name: "enums and services",
+			source: `enum Kind {
+  DOG = 0;
+}
+
+service PetService {
+  rpc GetPet(GetPetRequest) returns (Pet);
+}`,
+			want: map[string]*protobuf.MessageLock{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseProtoFieldLock(tt.source)
+			if !reflect.DeepEqual(got.Messages, tt.want) {
+				gotJSON, _ := json.Marshal(got.Messages)
+				wantJSON, _ := json.Marshal(tt.want)
+				t.Errorf("parseProtoFieldLock() = %s, want %s", gotJSON, wantJSON)
+			}
+		})
+	}
+}
+
+func TestLoadProtoFieldLock(t *testing.T) {
+	dir := t.TempDir()
+
+	lock, err := LoadProtoFieldLock(filepath.Join(dir, "missing.json"))
+	if err != nil {
+		t.Fatalf("LoadProtoFieldLock() of a missing file: %v", err)
+	}
+	if len(lock.Messages) != 0 {
+		t.Errorf("LoadProtoFieldLock() of a missing file = %v, want an empty lock", lock.Messages)
+	}
+
+	want := protobuf.NewFieldLock()
+	ml := want.Message("Pet")
+	ml.Fields["Name"] = 1
+	ml.Reserved = []int{2}
+	ml.ReservedRanges = []protobuf.ReservedRange{{From: 9, To: 11}}
+	ml.ReservedNames = []string{"Old"}
+	data, err := json.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jsonPath := filepath.Join(dir, "lock.json")
+	if err := os.WriteFile(jsonPath, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := LoadProtoFieldLock(jsonPath)
+	if err != nil {
+		t.Fatalf("LoadProtoFieldLock() of a JSON lock: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("LoadProtoFieldLock() = %+v, want %+v", got.Messages["Pet"], want.Messages["Pet"])
+	}
+
+	protoPath := filepath.Join(dir, "old.proto")
+	if err := os.WriteFile(protoPath, []byte("message Pet {\n  string Name = 1;\n}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err = LoadProtoFieldLock(protoPath)
+	if err != nil {
+		t.Fatalf("LoadProtoFieldLock() of a Proto file: %v", err)
+	}
+	if number := got.Lookup("Pet").Fields["Name"]; number != 1 {
+		t.Errorf("field number of Pet.Name = %d, want 1", number)
+	}
+
+	badPath := filepath.Join(dir, "bad.json")
+	if err := os.WriteFile(badPath, []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadProtoFieldLock(badPath); err == nil {
+		t.Errorf("LoadProtoFieldLock() of an invalid JSON lock succeeded")
+	}
+}
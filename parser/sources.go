@@ -0,0 +1,193 @@
+/*
+ * Copyright 2024 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package parser
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/hertz-contrib/swagger-generate/swagger2idl/utils"
+)
+
+// SchemaSources records which document declares each referenced schema of a multi-document spec.
+// kin-openapi keeps references relative to the document they appear in, so the declaring
+// document has to be tracked while walking the spec from its root document.
+type SchemaSources struct {
+	refs map[*openapi3.SchemaRef]string // Declaring document of each external reference
+}
+
+// ResolveSchemaSources walks the spec loaded from filePath and resolves the document of every
+// schema reference that points into another local file
+func ResolveSchemaSources(filePath string, spec *openapi3.T) *SchemaSources {
+	root := path.Base(filepath.ToSlash(filePath))
+	w := &sourceWalker{
+		root:    root,
+		sources: &SchemaSources{refs: map[*openapi3.SchemaRef]string{}},
+		visited: map[*openapi3.Schema]bool{},
+	}
+
+	if spec.Components != nil {
+		for _, name := range utils.SortedKeys(spec.Components.Schemas) {
+			w.schema(spec.Components.Schemas[name], root)
+		}
+		for _, name := range utils.SortedKeys(spec.Components.Parameters) {
+			w.parameter(spec.Components.Parameters[name], root)
+		}
+		for _, name := range utils.SortedKeys(spec.Components.RequestBodies) {
+			w.requestBody(spec.Components.RequestBodies[name], root)
+		}
+		for _, name := range utils.SortedKeys(spec.Components.Responses) {
+			w.response(spec.Components.Responses[name], root)
+		}
+		for _, name := range utils.SortedKeys(spec.Components.Headers) {
+			w.header(spec.Components.Headers[name], root)
+		}
+	}
+
+	if spec.Paths != nil {
+		for _, p := range utils.SortedKeys(spec.Paths.Map()) {
+			pathItem := spec.Paths.Value(p)
+			for _, param := range pathItem.Parameters {
+				w.parameter(param, root)
+			}
+			for _, method := range utils.SortedKeys(pathItem.Operations()) {
+				w.operation(pathItem.GetOperation(method), root)
+			}
+		}
+	}
+
+	return w.sources
+}
+
+// Lookup returns the path of the document declaring the referenced schema, relative to the
+// directory of the root document, or an empty string if the reference stays in the root document
+func (s *SchemaSources) Lookup(schemaRef *openapi3.SchemaRef) string {
+	if s == nil || schemaRef == nil {
+		return ""
+	}
+	return s.refs[schemaRef]
+}
+
+// sourceWalker tracks the document that the walked part of the spec was loaded from
+type sourceWalker struct {
+	root    string // Name of the root document
+	sources *SchemaSources
+	visited map[*openapi3.Schema]bool
+}
+
+// document returns the document a reference made from doc points to
+func (w *sourceWalker) document(ref, doc string) string {
+	file, _, _ := strings.Cut(ref, "#")
+	if file == "" || strings.Contains(file, "://") {
+		return doc
+	}
+	return path.Clean(path.Join(path.Dir(doc), file))
+}
+
+func (w *sourceWalker) operation(operation *openapi3.Operation, doc string) {
+	if operation == nil {
+		return
+	}
+	for _, param := range operation.Parameters {
+		w.parameter(param, doc)
+	}
+	w.requestBody(operation.RequestBody, doc)
+	if operation.Responses != nil {
+		for _, status := range utils.SortedKeys(operation.Responses.Map()) {
+			w.response(operation.Responses.Value(status), doc)
+		}
+	}
+}
+
+func (w *sourceWalker) parameter(param *openapi3.ParameterRef, doc string) {
+	if param == nil || param.Value == nil {
+		return
+	}
+	doc = w.document(param.Ref, doc)
+	w.schema(param.Value.Schema, doc)
+	w.content(param.Value.Content, doc)
+}
+
+func (w *sourceWalker) requestBody(body *openapi3.RequestBodyRef, doc string) {
+	if body == nil || body.Value == nil {
+		return
+	}
+	w.content(body.Value.Content, w.document(body.Ref, doc))
+}
+
+func (w *sourceWalker) response(response *openapi3.ResponseRef, doc string) {
+	if response == nil || response.Value == nil {
+		return
+	}
+	doc = w.document(response.Ref, doc)
+	w.content(response.Value.Content, doc)
+	for _, name := range utils.SortedKeys(response.Value.Headers) {
+		w.header(response.Value.Headers[name], doc)
+	}
+}
+
+func (w *sourceWalker) header(header *openapi3.HeaderRef, doc string) {
+	if header == nil || header.Value == nil {
+		return
+	}
+	doc = w.document(header.Ref, doc)
+	w.schema(header.Value.Schema, doc)
+	w.content(header.Value.Content, doc)
+}
+
+func (w *sourceWalker) content(content openapi3.Content, doc string) {
+	for _, mediaType := range utils.SortedKeys(content) {
+		w.schema(content[mediaType].Schema, doc)
+	}
+}
+
+// schema records the document of a schema reference and walks the schemas nested in it
+func (w *sourceWalker) schema(schemaRef *openapi3.SchemaRef, doc string) {
+	if schemaRef == nil {
+		return
+	}
+	if schemaRef.Ref != "" {
+		doc = w.document(schemaRef.Ref, doc)
+		if doc != w.root {
+			w.sources.refs[schemaRef] = doc
+		}
+	}
+
+	schema := schemaRef.Value
+	if schema == nil || w.visited[schema] {
+		return
+	}
+	w.visited[schema] = true
+
+	for _, name := range utils.SortedKeys(schema.Properties) {
+		w.schema(schema.Properties[name], doc)
+	}
+	w.schema(schema.Items, doc)
+	w.schema(schema.Not, doc)
+	w.schema(schema.AdditionalProperties.Schema, doc)
+	for _, nested := range schema.AllOf {
+		w.schema(nested, doc)
+	}
+	for _, nested := range schema.OneOf {
+		w.schema(nested, doc)
+	}
+	for _, nested := range schema.AnyOf {
+		w.schema(nested, doc)
+	}
+}
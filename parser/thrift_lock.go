@@ -0,0 +1,122 @@
+/*
+ * Copyright 2024 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/hertz-contrib/swagger-generate/swagger2idl/thrift"
+)
+
+var (
+	thriftStructDecl   = regexp.MustCompile(`^(?:struct|union|exception)\s+(\w+)\s*\{`)
+	thriftFieldDecl    = regexp.MustCompile(`^(-?\d+)\s*:\s*(?:required\s+|optional\s+)?(.+?)\s+(\w+)\s*(?:\(|=|;|,|$)`)
+	thriftReservedNote = regexp.MustCompile(`^//\s*reserved:\s*(.+)$`)
+)
+
+// LoadThriftFieldLock reads the field IDs of a previously generated Thrift file or of a
+// JSON lock file. A missing file yields an empty lock.
+func LoadThriftFieldLock(filePath string) (*thrift.FieldLock, error) {
+	data, err := os.ReadFile(filePath)
+	if os.IsNotExist(err) {
+		return thrift.NewFieldLock(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lock file: %v", err)
+	}
+
+	if filepath.Ext(filePath) == ".thrift" {
+		return parseThriftFieldLock(string(data)), nil
+	}
+
+	lock := thrift.NewFieldLock()
+	if err := json.Unmarshal(data, lock); err != nil {
+		return nil, fmt.Errorf("failed to decode lock file %s: %v", filePath, err)
+	}
+	return lock, nil
+}
+
+// parseThriftFieldLock scans Thrift source for struct and union fields and reserved notes
+func parseThriftFieldLock(source string) *thrift.FieldLock {
+	lock := thrift.NewFieldLock()
+
+	var current string // name of the struct being scanned
+	depth := 0         // brace depth relative to the current struct
+
+	for _, line := range strings.Split(source, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		if current != "" && depth == 1 {
+			if m := thriftReservedNote.FindStringSubmatch(line); m != nil {
+				sl := lock.Struct(current)
+				for _, item := range strings.Split(m[1], ",") {
+					if id, err := strconv.Atoi(strings.TrimSpace(item)); err == nil {
+						sl.Reserved = append(sl.Reserved, id)
+					}
+				}
+				continue
+			}
+		}
+
+		if idx := strings.Index(line, "//"); idx >= 0 {
+			line = strings.TrimSpace(line[:idx])
+		}
+		line = protoStringLiteral.ReplaceAllString(line, `""`)
+
+		if current == "" {
+			if m := thriftStructDecl.FindStringSubmatch(line); m != nil {
+				current = m[1]
+				lock.Struct(current)
+				line = line[len(m[0]):]
+				depth = 1
+			}
+		} else if depth == 1 {
+			if m := thriftFieldDecl.FindStringSubmatch(line); m != nil {
+				if id, err := strconv.Atoi(m[1]); err == nil {
+					lock.Struct(current).Fields[m[3]] = id
+				}
+			}
+		}
+
+		if current == "" {
+			continue
+		}
+		for _, ch := range line {
+			switch ch {
+			case '{':
+				depth++
+			case '}':
+				depth--
+			}
+		}
+		if depth <= 0 {
+			current = ""
+			depth = 0
+		}
+	}
+
+	return lock
+}
@@ -0,0 +1,165 @@
+/*
+ * Copyright 2024 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package parser
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/hertz-contrib/swagger-generate/swagger2idl/thrift"
+)
+
+func TestParseThriftFieldLock(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   map[string]*thrift.StructLock
+	}{
+		{
+			name: "struct fields",
+			source: `struct Pet {
+    1: required string Name
+    2: optional i64 Age
+    3: list<string> Tags
+    4: map<string, i32> Scores
+}`,
+			want: map[string]*thrift.StructLock{
+				"Pet": {Fields: map[string]int{"Name": 1, "Age": 2, "Tags": 3, "Scores": 4}},
+			},
+		},
+		{
+			name: "unions and exceptions",
+			source: `union Kind {
+    1: Dog Dog
+    2: Cat Cat
+}
+
+exception NotFound {
+    1: string Message
+}`,
+			want: map[string]*thrift.StructLock{
+				"Kind":     {Fields: map[string]int{"Dog": 1, "Cat": 2}},
+				"NotFound": {Fields: map[string]int{"Message": 1}},
+			},
+		},
+		{
+			name: "reserved notes",
+			source: `struct Pet {
+    // reserved: 2, 5
+    1: string Name
+}`,
+			want: map[string]*thrift.StructLock{
+				"Pet": {Fields: map[string]int{"Name": 1}, Reserved: []int{2, 5}},
+			},
+		},
+		{
+			name: "annotations, comments and string literals",
+			source: `struct Pet {
+    // 7: string Hidden
+    1: string Name (
+        api.query = "name }"
+    )
+} (
+    openapi.schema = '{}'
+)
+
+struct Owner {
+    1: string Name
+}`,
+			want: map[string]*thrift.StructLock{
+				"Pet":   {Fields: map[string]int{"Name": 1}},
+				"Owner": {Fields: map[string]int{"Name": 1}},
+			},
+		},
+		{
+			name: "enums and services",
+			source: `enum Kind {
+    DOG = 1
+}
+
+service PetService {
+    Pet GetPet(1: GetPetRequest req)
+}`,
+			want: map[string]*thrift.StructLock{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseThriftFieldLock(tt.source)
+			if !reflect.DeepEqual(got.Structs, tt.want) {
+				gotJSON, _ := json.Marshal(got.Structs)
+				wantJSON, _ := json.Marshal(tt.want)
+				t.Errorf("parseThriftFieldLock() = %s, want %s", gotJSON, wantJSON)
+			}
+		})
+	}
+}
+
+func TestLoadThriftFieldLock(t *testing.T) {
+	dir := t.TempDir()
+
+	lock, err := LoadThriftFieldLock(filepath.Join(dir, "missing.json"))
+	if err != nil {
+		t.Fatalf("LoadThriftFieldLock() of a missing file: %v", err)
+	}
+	if len(lock.Structs) != 0 {
+		t.Errorf("LoadThriftFieldLock() of a missing file = %v, want an empty lock", lock.Structs)
+	}
+
+	want := thrift.NewFieldLock()
+	sl := want.Struct("Pet")
+	sl.Fields["Name"] = 1
+	sl.Reserved = []int{2}
+	data, err := json.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jsonPath := filepath.Join(dir, "lock.json")
+	if err := os.WriteFile(jsonPath, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := LoadThriftFieldLock(jsonPath)
+	if err != nil {
+		t.Fatalf("LoadThriftFieldLock() of a JSON lock: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("LoadThriftFieldLock() = %+v, want %+v", got.Structs["Pet"], want.Structs["Pet"])
+	}
+
+	thriftPath := filepath.Join(dir, "old.thrift")
+	if err := os.WriteFile(thriftPath, []byte("struct Pet {\n    3: string Name\n}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err = LoadThriftFieldLock(thriftPath)
+	if err != nil {
+		t.Fatalf("LoadThriftFieldLock() of a Thrift file: %v", err)
+	}
+	if id := got.Lookup("Pet").Fields["Name"]; id != 3 {
+		t.Errorf("field ID of Pet.Name = %d, want 3", id)
+	}
+
+	badPath := filepath.Join(dir, "bad.json")
+	if err := os.WriteFile(badPath, []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadThriftFieldLock(badPath); err == nil {
+		t.Errorf("LoadThriftFieldLock() of an invalid JSON lock succeeded")
+	}
+}
@@ -0,0 +1,93 @@
+/*
+ * Copyright 2024 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package parser
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/invopop/yaml"
+)
+
+// TypeMapping maps the scalar schemas of a type, optionally narrowed down by a format and by an
+// extension, onto the IDL types of the targets it sets
+type TypeMapping struct {
+	Type string `json:"type"` // Schema type: string, integer, number or boolean
+	// Format narrows the mapping down to the schemas of this format, any format matches if empty
+	Format string `json:"format,omitempty"`
+	// Extension narrows the mapping down to the schemas declaring an extension, given as `x-name`
+	// or as `x-name=value` to match its value as well
+	Extension string `json:"extension,omitempty"`
+
+	Proto         string `json:"proto,omitempty"`         // Proto type, the mapping does not apply to Proto if empty
+	ProtoImport   string `json:"protoImport,omitempty"`   // File imported by Proto files using the type
+	Thrift        string `json:"thrift,omitempty"`        // Thrift type, the mapping does not apply to Thrift if empty
+	ThriftInclude string `json:"thriftInclude,omitempty"` // File included by Thrift files using the type
+}
+
+// LoadTypeMappings reads a JSON or YAML list of type mappings
+func LoadTypeMappings(filePath string) ([]TypeMapping, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read type mapping file: %v", err)
+	}
+
+	var mappings []TypeMapping
+	if err := yaml.Unmarshal(data, &mappings); err != nil {
+		return nil, fmt.Errorf("failed to decode type mapping file %s: %v", filePath, err)
+	}
+	for i := range mappings {
+		if err := mappings[i].Validate(); err != nil {
+			return nil, fmt.Errorf("invalid type mapping %d of %s: %w", i+1, filePath, err)
+		}
+	}
+	return mappings, nil
+}
+
+// Validate checks that the mapping matches scalar schemas and sets a type for a target
+func (m *TypeMapping) Validate() error {
+	switch m.Type {
+	case openapi3.TypeString, openapi3.TypeInteger, openapi3.TypeNumber, openapi3.TypeBoolean:
+	default:
+		return fmt.Errorf("type must be string, integer, number or boolean, got %q", m.Type)
+	}
+	if m.Extension != "" && !strings.HasPrefix(m.Extension, "x-") {
+		return fmt.Errorf("extension %q does not start with x-", m.Extension)
+	}
+	if m.Proto == "" && m.Thrift == "" {
+		return fmt.Errorf("neither a proto nor a thrift type is set")
+	}
+	return nil
+}
+
+// Matches reports whether the mapping applies to a schema converted as the given type
+func (m *TypeMapping) Matches(schema *openapi3.Schema, typ string) bool {
+	if m.Type != typ || (m.Format != "" && m.Format != schema.Format) {
+		return false
+	}
+	if m.Extension == "" {
+		return true
+	}
+	name, value, hasValue := strings.Cut(m.Extension, "=")
+	extension, ok := schema.Extensions[name]
+	if !ok {
+		return false
+	}
+	return !hasValue || fmt.Sprint(extension) == value
+}
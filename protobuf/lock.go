@@ -0,0 +1,84 @@
+/*
+ * Copyright 2024 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package protobuf
+
+import "fmt"
+
+// MaxFieldNumber is the highest Proto field number, written as `max` in reserved ranges
+const MaxFieldNumber = 536870911
+
+// FieldLock records the field numbers assigned in a previous generation, keyed by the
+// fully qualified message name (e.g. `Outer.Inner`)
+type FieldLock struct {
+	Messages map[string]*MessageLock `json:"messages"`
+}
+
+// MessageLock records the field numbers of a single Proto message
+type MessageLock struct {
+	Fields         map[string]int  `json:"fields"`                    // Field name to field number
+	Reserved       []int           `json:"reserved,omitempty"`        // Reserved field numbers
+	ReservedRanges []ReservedRange `json:"reserved_ranges,omitempty"` // Reserved field number ranges
+	ReservedNames  []string        `json:"reserved_names,omitempty"`  // Reserved field names
+}
+
+// ReservedRange is an inclusive range of reserved field numbers, `reserved From to To`
+type ReservedRange struct {
+	From int `json:"from"`
+	To   int `json:"to"`
+}
+
+// Contains reports whether number lies in the range
+func (r ReservedRange) Contains(number int) bool {
+	return number >= r.From && number <= r.To
+}
+
+// String formats the range as in a reserved statement
+func (r ReservedRange) String() string {
+	if r.To == MaxFieldNumber {
+		return fmt.Sprintf("%d to max", r.From)
+	}
+	return fmt.Sprintf("%d to %d", r.From, r.To)
+}
+
+// NewFieldLock creates an empty FieldLock
+func NewFieldLock() *FieldLock {
+	return &FieldLock{Messages: map[string]*MessageLock{}}
+}
+
+// Message returns the lock of the named message, creating it if it does not exist
+func (l *FieldLock) Message(name string) *MessageLock {
+	if l.Messages == nil {
+		l.Messages = map[string]*MessageLock{}
+	}
+	ml, ok := l.Messages[name]
+	if !ok {
+		ml = &MessageLock{Fields: map[string]int{}}
+		l.Messages[name] = ml
+	}
+	if ml.Fields == nil {
+		ml.Fields = map[string]int{}
+	}
+	return ml
+}
+
+// Lookup returns the lock of the named message, or nil if the message was not locked
+func (l *FieldLock) Lookup(name string) *MessageLock {
+	if l == nil || l.Messages == nil {
+		return nil
+	}
+	return l.Messages[name]
+}
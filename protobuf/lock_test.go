@@ -0,0 +1,66 @@
+/*
+ * Copyright 2024 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package protobuf
+
+import "testing"
+
+func TestFieldLockMessage(t *testing.T) {
+	lock := &FieldLock{}
+	if lock.Lookup("Pet") != nil {
+		t.Fatalf("Lookup of an empty lock = %v, want nil", lock.Lookup("Pet"))
+	}
+
+	ml := lock.Message("Pet")
+	ml.Fields["Name"] = 1
+	if got := lock.Message("Pet"); got != ml {
+		t.Errorf("Message returned a new lock for an existing message")
+	}
+	if got := lock.Lookup("Pet"); got != ml {
+		t.Errorf("Lookup = %v, want %v", got, ml)
+	}
+
+	var nilLock *FieldLock
+	if nilLock.Lookup("Pet") != nil {
+		t.Errorf("Lookup of a nil lock is not nil")
+	}
+}
+
+func TestReservedRange(t *testing.T) {
+	tests := []struct {
+		name     string
+		r        ReservedRange
+		number   int
+		contains bool
+		str      string
+	}{
+		{name: "inside", r: ReservedRange{From: 9, To: 11}, number: 10, contains: true, str: "9 to 11"},
+		{name: "lower bound", r: ReservedRange{From: 9, To: 11}, number: 9, contains: true, str: "9 to 11"},
+		{name: "upper bound", r: ReservedRange{From: 9, To: 11}, number: 11, contains: true, str: "9 to 11"},
+		{name: "outside", r: ReservedRange{From: 9, To: 11}, number: 12, contains: false, str: "9 to 11"},
+		{name: "max", r: ReservedRange{From: 100, To: MaxFieldNumber}, number: 1000, contains: true, str: "100 to max"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.r.Contains(tt.number); got != tt.contains {
+				t.Errorf("Contains(%d) = %v, want %v", tt.number, got, tt.contains)
+			}
+			if got := tt.r.String(); got != tt.str {
+				t.Errorf("String() = %q, want %q", got, tt.str)
+			}
+		})
+	}
+}
@@ -18,6 +18,7 @@ package protobuf
 
 // ProtoFile represents a complete Proto file
 type ProtoFile struct {
+	FileName    string          // Output file name, used to import the file from other files of a set
 	PackageName string          // The package name of the Proto file
 	Messages    []*ProtoMessage // List of Proto messages
 	Services    []*ProtoService // List of Proto services
@@ -28,13 +29,15 @@ type ProtoFile struct {
 
 // ProtoMessage represents a Proto message
 type ProtoMessage struct {
-	Name        string
-	Description string          // Description for the Proto message
-	Fields      []*ProtoField   // List of fields in the Proto message
-	Messages    []*ProtoMessage // Nested Proto messages
-	Enums       []*ProtoEnum    // Enums within the Proto message
-	OneOfs      []*ProtoOneOf   // OneOfs within the Proto message
-	Options     []*Option       // Options specific to this Proto message
+	Name          string
+	Description   string          // Description for the Proto message
+	Fields        []*ProtoField   // List of fields in the Proto message
+	Messages      []*ProtoMessage // Nested Proto messages
+	Enums         []*ProtoEnum    // Enums within the Proto message
+	OneOfs        []*ProtoOneOf   // OneOfs within the Proto message
+	Options       []*Option       // Options specific to this Proto message
+	Reserved      []int           // Field numbers that must not be reused
+	ReservedNames []string        // Field names that must not be reused
 }
 
 // ProtoField represents a field in a Proto message
@@ -42,7 +45,9 @@ type ProtoField struct {
 	Name        string
 	Type        string
 	Description string
+	Number      int       // Field number, assigned by the generator when zero
 	Repeated    bool      // Indicates if the field is repeated (array)
+	Optional    bool      // Indicates if the field tracks presence (proto3 optional)
 	Options     []*Option // Additional options for this field
 }
 
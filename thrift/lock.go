@@ -0,0 +1,60 @@
+/*
+ * Copyright 2024 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package thrift
+
+// MaxFieldID is the highest Thrift field ID, field IDs are 16-bit signed integers
+const MaxFieldID = 32767
+
+// FieldLock records the field IDs assigned in a previous generation, keyed by struct or union name
+type FieldLock struct {
+	Structs map[string]*StructLock `json:"structs"`
+}
+
+// StructLock records the field IDs of a single Thrift struct or union
+type StructLock struct {
+	Fields   map[string]int `json:"fields"`             // Field name to field ID
+	Reserved []int          `json:"reserved,omitempty"` // IDs of removed fields
+}
+
+// NewFieldLock creates an empty FieldLock
+func NewFieldLock() *FieldLock {
+	return &FieldLock{Structs: map[string]*StructLock{}}
+}
+
+// Struct returns the lock of the named struct, creating it if it does not exist
+func (l *FieldLock) Struct(name string) *StructLock {
+	if l.Structs == nil {
+		l.Structs = map[string]*StructLock{}
+	}
+	sl, ok := l.Structs[name]
+	if !ok {
+		sl = &StructLock{Fields: map[string]int{}}
+		l.Structs[name] = sl
+	}
+	if sl.Fields == nil {
+		sl.Fields = map[string]int{}
+	}
+	return sl
+}
+
+// Lookup returns the lock of the named struct, or nil if the struct was not locked
+func (l *FieldLock) Lookup(name string) *StructLock {
+	if l == nil || l.Structs == nil {
+		return nil
+	}
+	return l.Structs[name]
+}
@@ -0,0 +1,46 @@
+/*
+ * Copyright 2024 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package thrift
+
+import "testing"
+
+func TestFieldLockStruct(t *testing.T) {
+	lock := &FieldLock{}
+	if lock.Lookup("Pet") != nil {
+		t.Fatalf("Lookup of an empty lock = %v, want nil", lock.Lookup("Pet"))
+	}
+
+	sl := lock.Struct("Pet")
+	sl.Fields["Name"] = 1
+	if got := lock.Struct("Pet"); got != sl {
+		t.Errorf("Struct returned a new lock for an existing struct")
+	}
+	if got := lock.Lookup("Pet"); got != sl {
+		t.Errorf("Lookup = %v, want %v", got, sl)
+	}
+
+	// A decoded lock may hold a struct without fields
+	lock.Structs["Empty"] = &StructLock{}
+	if lock.Struct("Empty").Fields == nil {
+		t.Errorf("Struct did not initialize the fields of a decoded lock")
+	}
+
+	var nilLock *FieldLock
+	if nilLock.Lookup("Pet") != nil {
+		t.Errorf("Lookup of a nil lock is not nil")
+	}
+}
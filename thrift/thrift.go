@@ -2,8 +2,10 @@ package thrift
 
 // ThriftFile represents a complete Thrift file
 type ThriftFile struct {
+	FileName  string            // Output file name, used to include the file from other files of a set
 	Namespace map[string]string // Namespace for the Thrift file
 	Includes  []string          // List of included Thrift files
+	Typedefs  []*ThriftTypedef  // List of Thrift typedefs
 	Structs   []*ThriftStruct   // List of Thrift structs
 	Unions    []*ThriftUnion    // List of Thrift unions
 	Enums     []*ThriftEnum     // List of Thrift enums
@@ -11,20 +13,30 @@ type ThriftFile struct {
 	Services  []*ThriftService  // List of Thrift services
 }
 
+// ThriftTypedef represents a Thrift typedef, naming a container type
+type ThriftTypedef struct {
+	Name        string    // Name of the typedef
+	Description string    // Description of the typedef
+	Type        string    // Aliased type (Thrift types)
+	Options     []*Option // Options specific to this typedef
+}
+
 // ThriftStruct represents a Thrift struct
 type ThriftStruct struct {
 	Name        string         // Name of the struct
 	Description string         // Description of the struct
 	Fields      []*ThriftField // List of fields in the struct
 	Options     []*Option      // Options specific to this struct
+	Reserved    []int          // Field IDs of removed fields that must not be reused
 }
 
 // ThriftField represents a field in a Thrift struct or union
 type ThriftField struct {
-	ID          int       // Field ID for Thrift
+	ID          int       // Field ID for Thrift, assigned by the converter
 	Name        string    // Name of the field
 	Description string    // Description of the field
 	Type        string    // Type of the field (Thrift types)
+	Required    bool      // Indicates if the field is required
 	Optional    bool      // Indicates if the field is optional
 	Repeated    bool      // Indicates if the field is repeated (list)
 	Options     []*Option // Additional options for this field
@@ -32,9 +44,10 @@ type ThriftField struct {
 
 // ThriftUnion represents a Thrift union (similar to a struct but only one field can be set at a time)
 type ThriftUnion struct {
-	Name    string         // Name of the union
-	Fields  []*ThriftField // List of fields in the union
-	Options []*Option      // Options specific to this union
+	Name     string         // Name of the union
+	Fields   []*ThriftField // List of fields in the union
+	Options  []*Option      // Options specific to this union
+	Reserved []int          // Field IDs of removed fields that must not be reused
 }
 
 // ThriftEnum represents a Thrift enum
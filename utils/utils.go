@@ -19,8 +19,11 @@ package utils
 import (
 	"fmt"
 	"github.com/iancoleman/strcase"
+	"math"
 	"reflect"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"unicode"
 
@@ -224,6 +227,66 @@ func isZeroValue(v reflect.Value) bool {
 	}
 }
 
+// SortedKeys returns the keys of a string-keyed map in ascending order
+func SortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Contains reports whether value is present in values
+func Contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+var typeIdentifier = regexp.MustCompile(`[A-Za-z_][\w.]*`)
+
+// ReplaceTypeNames rewrites every type name of a possibly generic type expression such as
+// `map<string, Foo>` with the result of replace
+func ReplaceTypeNames(typeStr string, replace func(name string) string) string {
+	return typeIdentifier.ReplaceAllStringFunc(typeStr, replace)
+}
+
+// TypeNames returns the type names used by a possibly generic type expression
+func TypeNames(typeStr string) []string {
+	return typeIdentifier.FindAllString(typeStr, -1)
+}
+
+// GetIntExtension returns the integer value of the first of the given extensions that is set. A
+// value that is not an integer, such as 1.5 or "abc", is an error naming the extension.
+func GetIntExtension(extensions map[string]interface{}, names ...string) (int, bool, error) {
+	for _, name := range names {
+		value, ok := extensions[name]
+		if !ok {
+			continue
+		}
+		switch v := value.(type) {
+		case int:
+			return v, true, nil
+		case int64:
+			return int(v), true, nil
+		case float64:
+			if v == math.Trunc(v) {
+				return int(v), true, nil
+			}
+		case string:
+			if n, err := strconv.Atoi(v); err == nil {
+				return n, true, nil
+			}
+		}
+		return 0, false, fmt.Errorf("%s must be an integer, got %v", name, value)
+	}
+	return 0, false, nil
+}
+
 func GetMethodName(operation *openapi3.Operation, path, method string) string {
 	if operation.OperationID != "" {
 		return operation.OperationID
@@ -0,0 +1,56 @@
+/*
+ * Copyright 2024 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package utils
+
+import "testing"
+
+func TestGetIntExtension(t *testing.T) {
+	tests := []struct {
+		name       string
+		extensions map[string]interface{}
+		want       int
+		wantOK     bool
+		wantErr    string
+	}{
+		{name: "unset", extensions: map[string]interface{}{}},
+		{name: "int", extensions: map[string]interface{}{"x-id": 3}, want: 3, wantOK: true},
+		{name: "integral float", extensions: map[string]interface{}{"x-id": 3.0}, want: 3, wantOK: true},
+		{name: "numeric string", extensions: map[string]interface{}{"x-id": "7"}, want: 7, wantOK: true},
+		{name: "precedence", extensions: map[string]interface{}{"x-id": 1, "x-other-id": 2}, want: 1, wantOK: true},
+		{name: "fallback", extensions: map[string]interface{}{"x-other-id": 2}, want: 2, wantOK: true},
+		{name: "fractional float", extensions: map[string]interface{}{"x-id": 1.5}, wantErr: "x-id must be an integer, got 1.5"},
+		{name: "non-numeric string", extensions: map[string]interface{}{"x-id": "abc"}, wantErr: "x-id must be an integer, got abc"},
+		{name: "boolean", extensions: map[string]interface{}{"x-id": true}, wantErr: "x-id must be an integer, got true"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok, err := GetIntExtension(tt.extensions, "x-id", "x-other-id")
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("GetIntExtension() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetIntExtension() error = %v", err)
+			}
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("GetIntExtension() = %d, %v, want %d, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}